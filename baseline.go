@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// changedColumn lists, with --baseline, the fields of each document whose
+// cells differ from the baseline's.
+const changedColumn = "__changed__"
+
+// baseline holds the non-null cells of a previous export of a collection, by
+// document path and then column, as the export's writer formats them: CSV
+// cells for CSV and TSV, JSON-encoded values for JSON Lines.
+type baseline map[string]map[string]string
+
+// isMetadataColumn reports whether col is one the writer adds rather than a
+// field, so it isn't compared with --baseline.
+func isMetadataColumn(col string) bool {
+	switch col {
+	case "__path__", "__collection__", "__create_time__", "__update_time__", "__fs_types__", changedColumn:
+		return true
+	}
+	return isParentColumn(col)
+}
+
+// baselineFile returns the path of the collection's file in the --baseline
+// directory: where a previous export with the same naming options wrote it.
+func baselineFile(displayPath string, cfg exportConfig) string {
+	return filepath.Join(cfg.baseline, filepath.FromSlash(outputName(displayPath, cfg))+outputExt(cfg.format))
+}
+
+// loadBaseline reads the collection's baseline file. A collection without one
+// gets an empty baseline, so all of its documents count as new.
+func loadBaseline(displayPath string, cfg exportConfig) (baseline, error) {
+	filePath := baselineFile(displayPath, cfg)
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		printWarn("No baseline file %s for %q; all of its documents are written as changed", filePath, displayPath)
+		return baseline{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening baseline: %w", err)
+	}
+	defer f.Close()
+
+	var base baseline
+	if cfg.format == "jsonl" {
+		base, err = readJSONLBaseline(f)
+	} else {
+		base, err = readCSVBaseline(f, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline %s: %w", filePath, err)
+	}
+	printInfoFor(displayPath, "Comparing with %s (%s documents)", filePath, fmtInt(len(base)))
+	return base, nil
+}
+
+// readCSVBaseline reads a CSV or TSV export, mapping each row's cells to the
+// columns of its header. Cells equal to --null-value are nulls.
+func readCSVBaseline(r io.Reader, cfg exportConfig) (baseline, error) {
+	var next func() ([]string, error)
+	if cfg.format == "tsv" {
+		br := bufio.NewReader(r)
+		next = func() ([]string, error) { return nextTSVRow(br) }
+	} else {
+		cr := csv.NewReader(r)
+		if cfg.delimiter != 0 {
+			cr.Comma = cfg.delimiter
+		}
+		cr.FieldsPerRecord = -1
+		next = cr.Read
+	}
+	header, err := next()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	header[0] = strings.TrimPrefix(header[0], utf8BOM)
+	pathIdx := slices.Index(header, "__path__")
+	if pathIdx < 0 {
+		return nil, fmt.Errorf("no __path__ column")
+	}
+
+	base := make(baseline)
+	for {
+		row, err := next()
+		if err == io.EOF {
+			return base, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pathIdx >= len(row) {
+			continue
+		}
+		cells := make(map[string]string, len(row))
+		for i, col := range header {
+			if i < len(row) && !isMetadataColumn(col) && row[i] != cfg.nullValue {
+				cells[col] = row[i]
+			}
+		}
+		base[row[pathIdx]] = cells
+	}
+}
+
+// readJSONLBaseline reads a JSON Lines export, with or without --pretty-json.
+// Values are encoded again with sorted keys and numbers in Go's form, so they
+// compare equal to what the jsonlWriter writes even if the baseline was
+// written by another tool.
+func readJSONLBaseline(r io.Reader) (baseline, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	base := make(baseline)
+	for {
+		var obj map[string]any
+		if err := dec.Decode(&obj); err == io.EOF {
+			return base, nil
+		} else if err != nil {
+			return nil, err
+		}
+		path, _ := obj["__path__"].(string)
+		if path == "" {
+			return nil, fmt.Errorf("object without a __path__")
+		}
+		cells := make(map[string]string, len(obj))
+		for k, v := range obj {
+			if v == nil || isMetadataColumn(k) {
+				continue
+			}
+			b, err := marshalSorted(jsonNumbers(v))
+			if err != nil {
+				return nil, err
+			}
+			cells[k] = string(b)
+		}
+		base[path] = cells
+	}
+}
+
+// jsonNumbers replaces the json.Numbers in v with the int64 or float64 they
+// hold, as Firestore would store them.
+func jsonNumbers(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return n
+		}
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+		return val
+	case map[string]any:
+		for k, elem := range val {
+			val[k] = jsonNumbers(elem)
+		}
+	case []any:
+		for i, elem := range val {
+			val[i] = jsonNumbers(elem)
+		}
+	}
+	return v
+}
+
+// baselineWriter writes the documents of a --baseline export. Each document
+// keeps only the fields whose cells differ from its baseline row, so unchanged
+// ones are empty in CSV and left out of JSON Lines, and gets a changedColumn
+// with the sorted names of the changed fields, including those that are gone.
+// A document the baseline doesn't have lists all of its non-null fields.
+type baselineWriter struct {
+	recordWriter
+	base baseline
+	cell func(v any) string // formats v as the wrapped writer does
+	null string             // the cell of a null value
+}
+
+// withBaseline wraps rw, a *csvWriter or *jsonlWriter, in a baselineWriter when
+// --baseline is set.
+func withBaseline(rw recordWriter, filePath, displayPath string, cfg exportConfig) (recordWriter, string, error) {
+	if cfg.baseline == "" {
+		return rw, filePath, nil
+	}
+	base, err := loadBaseline(displayPath, cfg)
+	if err != nil {
+		rw.close()
+		return nil, "", err
+	}
+	bw := &baselineWriter{recordWriter: rw, base: base}
+	switch w := rw.(type) {
+	case *csvWriter:
+		bw.cell = func(v any) string {
+			s, _ := w.vf.truncate(w.vf.formatValue(v))
+			return s
+		}
+		bw.null = w.vf.nullValue
+	case *jsonlWriter:
+		bw.cell = func(v any) string {
+			b, _ := marshalSorted(w.vf.convertForJSON(v))
+			return string(b)
+		}
+		bw.null = "null"
+	}
+	return bw, filePath, nil
+}
+
+func (bw *baselineWriter) write(doc docRecord) error {
+	prev := bw.base[doc.path]
+	data := make(map[string]any, len(doc.data)+1)
+	var changed []string
+	for k, v := range doc.data {
+		cell, ok := "", v != nil
+		if ok {
+			cell = bw.cell(v)
+			ok = cell != bw.null
+		}
+		if old, had := prev[k]; had == ok && (!ok || old == cell) {
+			continue
+		}
+		data[k] = v
+		changed = append(changed, k)
+	}
+	for k := range prev {
+		if _, ok := doc.data[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	slices.Sort(changed)
+	names := make([]any, len(changed))
+	for i, k := range changed {
+		names[i] = k
+	}
+	data[changedColumn] = names
+	doc.data = data
+	return bw.recordWriter.write(doc)
+}
+
+// validateBaseline rejects options that --baseline can't honor.
+func validateBaseline(cfg exportConfig) error {
+	switch {
+	case cfg.format == "parquet":
+		return fmt.Errorf("--baseline compares CSV, TSV and JSON Lines files; it can't be used with --format parquet")
+	case isGCSURL(cfg.baseline):
+		return fmt.Errorf("--baseline needs a local directory")
+	case filepath.Clean(cfg.baseline) == filepath.Clean(cfg.output):
+		return fmt.Errorf("--baseline can't be the --output directory, whose files the export replaces")
+	case cfg.splitDocuments, cfg.singleFile, cfg.maxFileSize > 0:
+		return fmt.Errorf("--baseline reads one file per collection; it can't be combined with --split-documents, --single-file or --max-file-size")
+	case cfg.append, cfg.resume:
+		return fmt.Errorf("--baseline can't be combined with --append or --resume")
+	case cfg.compression != "":
+		return fmt.Errorf("--baseline reads uncompressed files; drop --compression")
+	case cfg.bigQuery:
+		return fmt.Errorf("--baseline can't be combined with --bigquery-json")
+	}
+	info, err := os.Stat(cfg.baseline)
+	if err != nil {
+		return fmt.Errorf("invalid --baseline: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid --baseline %s: not a directory", cfg.baseline)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSVBaseline(t *testing.T) {
+	in := utf8BOM + "__path__,__create_time__,__update_time__,age,name,__fs_types__\n" +
+		"users/a,t1,t2,30,Ada,{}\n" +
+		"users/b,t1,t2,,Bob,{}\n"
+	got, err := readCSVBaseline(strings.NewReader(in), exportConfig{})
+	if err != nil {
+		t.Fatalf("readCSVBaseline() error = %v", err)
+	}
+	want := baseline{
+		"users/a": {"age": "30", "name": "Ada"},
+		"users/b": {"name": "Bob"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readCSVBaseline() = %v, want %v", got, want)
+	}
+
+	tsv := "__path__\tnote\nusers/a\tline 1\\nline 2\n"
+	got, err = readCSVBaseline(strings.NewReader(tsv), exportConfig{format: "tsv"})
+	if err != nil {
+		t.Fatalf("readCSVBaseline(tsv) error = %v", err)
+	}
+	if want := (baseline{"users/a": {"note": "line 1\nline 2"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("readCSVBaseline(tsv) = %v, want %v", got, want)
+	}
+
+	if _, err := readCSVBaseline(strings.NewReader("name\nAda\n"), exportConfig{}); err == nil {
+		t.Error("readCSVBaseline(no __path__) error = nil, want error")
+	}
+}
+
+func TestReadJSONLBaseline(t *testing.T) {
+	in := `{"__path__":"users/a","address":{"zip":"1","city":"X"},"age":30,"gone":null}` + "\n" +
+		"{\n  \"__path__\": \"users/b\",\n  \"score\": 2.50\n}\n"
+	got, err := readJSONLBaseline(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("readJSONLBaseline() error = %v", err)
+	}
+	want := baseline{
+		"users/a": {"address": `{"city":"X","zip":"1"}`, "age": "30"},
+		"users/b": {"score": "2.5"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readJSONLBaseline() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteCollection_Baseline(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "before")
+	if err := os.Mkdir(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "users.csv"), []byte("__path__,age,name,nick\nusers/a,30,Ada,ada\nusers/b,40,Bob,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	docs := []docRecord{
+		{path: "users/a", data: map[string]any{"age": int64(31), "name": "Ada"}},
+		{path: "users/b", data: map[string]any{"age": int64(40), "name": "Bob", "nick": nil}},
+		{path: "users/c", data: map[string]any{"name": "Cy"}},
+	}
+	fieldSet := map[string]struct{}{"age": {}, "name": {}, "nick": {}}
+	cfg := exportConfig{output: filepath.Join(dir, "after"), baseline: base}
+
+	filePath, err := writeCollectionCSV(docs, fieldSet, "users", cfg)
+	if err != nil {
+		t.Fatalf("writeCollectionCSV() error = %v", err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "__path__,age,name,nick,__changed__\n" +
+		"users/a,31,,,\"[\"\"age\"\",\"\"nick\"\"]\"\n" +
+		"users/b,,,,[]\n" +
+		"users/c,,Cy,,\"[\"\"name\"\"]\"\n"
+	if string(got) != want {
+		t.Errorf("content =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteCollectionJSONL_Baseline(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "users.jsonl"), []byte(`{"__path__":"users/a","tags":["x"],"n":1}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	docs := []docRecord{{path: "users/a", data: map[string]any{"tags": []any{"x"}, "n": int64(2)}}}
+	cfg := exportConfig{output: t.TempDir(), format: "jsonl", baseline: dir}
+
+	filePath, err := writeCollectionJSONL(docs, "users", cfg)
+	if err != nil {
+		t.Fatalf("writeCollectionJSONL() error = %v", err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"__changed__":["n"],"__path__":"users/a","n":2}` + "\n"; string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestValidateBaseline(t *testing.T) {
+	base := exportConfig{output: "out", baseline: t.TempDir()}
+	if err := validateBaseline(base); err != nil {
+		t.Errorf("validateBaseline() error = %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := map[string]func(*exportConfig){
+		"--format parquet":  func(c *exportConfig) { c.format = "parquet" },
+		"gs:// baseline":    func(c *exportConfig) { c.baseline = "gs://bucket/before" },
+		"same as --output":  func(c *exportConfig) { c.output = c.baseline },
+		"--split-documents": func(c *exportConfig) { c.splitDocuments = true },
+		"--single-file":     func(c *exportConfig) { c.singleFile = true },
+		"--max-file-size":   func(c *exportConfig) { c.maxFileSize = 1024 },
+		"--append":          func(c *exportConfig) { c.append = true },
+		"--resume":          func(c *exportConfig) { c.resume = true },
+		"--compression":     func(c *exportConfig) { c.compression = "gzip" },
+		"--bigquery-json":   func(c *exportConfig) { c.bigQuery = true },
+		"missing directory": func(c *exportConfig) { c.baseline = filepath.Join(c.baseline, "missing") },
+		"a file":            func(c *exportConfig) { c.baseline = file },
+	}
+	for name, mutate := range invalid {
+		cfg := base
+		mutate(&cfg)
+		if err := validateBaseline(cfg); err == nil {
+			t.Errorf("validateBaseline(%s) error = nil, want error", name)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+// bigQueryTimeFormat is the timestamp layout of --bigquery-json. BigQuery
+// keeps microseconds, so finer digits are dropped rather than rejected.
+const bigQueryTimeFormat = "2006-01-02T15:04:05.999999Z07:00"
+
+// bigQuerySchemaExt is the extension of the table schema written next to each
+// --bigquery-json file, for bq mk --table and bq load --schema.
+const bigQuerySchemaExt = ".bqschema.json"
+
+// bigQueryNameLen is the longest column name BigQuery accepts.
+const bigQueryNameLen = 300
+
+// bigQueryReservedPrefixes are the column name prefixes BigQuery reserves,
+// compared case-insensitively.
+var bigQueryReservedPrefixes = []string{"_table_", "_file_", "_partition", "_row_timestamp", "__root__", "_colidentifier"}
+
+// bigQueryName returns name as a BigQuery column name: letters, digits and
+// underscores, not starting with a digit or a reserved prefix. Every other
+// character becomes an underscore, so the dotted --flatten column
+// address.city is address_city.
+func bigQueryName(name string) string {
+	var out strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '_', r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			out.WriteRune(r)
+		default:
+			out.WriteByte('_')
+		}
+	}
+	s := out.String()
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	lower := strings.ToLower(s)
+	for _, prefix := range bigQueryReservedPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			s = "_" + s
+			break
+		}
+	}
+	if len(s) > bigQueryNameLen {
+		s = s[:bigQueryNameLen]
+	}
+	return s
+}
+
+// bigQueryRecord is the last step of shapeRecord with --bigquery-json. It
+// renames the fields of data, at every level, with bigQueryName, and replaces
+// the floats BigQuery can't load: NaN becomes null and infinities the largest
+// float of their sign. BigQuery names are case-insensitive, so fields that
+// end up with names differing only in case collide; they are handled per
+// --on-collision like the other columns, and at the top level the __path__
+// style columns count as taken.
+func bigQueryRecord(data map[string]any, cfg exportConfig) (map[string]any, error) {
+	taken := make(map[string]string)
+	for _, col := range reservedColumns(cfg) {
+		taken[strings.ToLower(col)] = "the " + col + " column"
+	}
+	return bigQueryMap("", data, taken, cfg)
+}
+
+func bigQueryMap(prefix string, data map[string]any, taken map[string]string, cfg exportConfig) (map[string]any, error) {
+	out := make(map[string]any, len(data))
+	for _, k := range sortedKeys(data) {
+		name := bigQueryName(k)
+		source := fmt.Sprintf("field %q", prefix+k)
+		if prev, ok := taken[strings.ToLower(name)]; ok {
+			if cfg.onCollision != onCollisionSuffix {
+				return nil, fmt.Errorf("%s and %s both map to BigQuery column %q; rename one or use --on-collision suffix", prev, source, prefix+name)
+			}
+			base := name
+			for i := 2; ok; i++ {
+				name = fmt.Sprintf("%s_%d", base, i)
+				_, ok = taken[strings.ToLower(name)]
+			}
+		}
+		taken[strings.ToLower(name)] = source
+		v, err := bigQueryValue(prefix+k, data[k], cfg)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = v
+	}
+	return out, nil
+}
+
+func bigQueryValue(path string, v any, cfg exportConfig) (any, error) {
+	switch val := v.(type) {
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			nonFiniteWarning.Do(func() {
+				printWarn("BigQuery can't load NaN or infinite floats; --bigquery-json writes NaN as null and infinities as ±%g", math.MaxFloat64)
+			})
+			switch {
+			case math.IsNaN(val):
+				return nil, nil
+			case val > 0:
+				return math.MaxFloat64, nil
+			default:
+				return -math.MaxFloat64, nil
+			}
+		}
+		return val, nil
+	case map[string]any:
+		return bigQueryMap(path+".", val, make(map[string]string), cfg)
+	case []any:
+		out := make([]any, len(val))
+		for i, elem := range val {
+			var err error
+			if out[i], err = bigQueryValue(path, elem, cfg); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// nonFiniteWarning reports, once per run, that --bigquery-json replaced NaN
+// or infinite floats.
+var nonFiniteWarning sync.Once
+
+// bigQueryField is a column of a BigQuery JSON table schema.
+type bigQueryField struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Mode   string          `json:"mode"`
+	Fields []bigQueryField `json:"fields,omitempty"`
+}
+
+// bigQueryColumn collects the values seen for one column, or one field of a
+// RECORD column, to infer its BigQuery type.
+type bigQueryColumn struct {
+	types    map[string]bool
+	fields   map[string]*bigQueryColumn // of a RECORD
+	single   bool                       // a non-null value that isn't an array was seen
+	repeated bool                       // an array was seen
+	// mixed is set by arrays holding nulls or arrays, which a REPEATED
+	// column can't.
+	mixed bool
+}
+
+func newBigQueryColumn() *bigQueryColumn {
+	return &bigQueryColumn{types: make(map[string]bool), fields: make(map[string]*bigQueryColumn)}
+}
+
+// bigQuerySchema infers the table schema of a --bigquery-json file from its
+// records, which bigQueryRecord has already renamed.
+type bigQuerySchema struct {
+	columns *bigQueryColumn
+}
+
+func newBigQuerySchema() *bigQuerySchema {
+	return &bigQuerySchema{columns: newBigQueryColumn()}
+}
+
+// add records the values of one record.
+func (s *bigQuerySchema) add(data map[string]any) {
+	s.columns.addFields(data)
+}
+
+func (c *bigQueryColumn) addFields(data map[string]any) {
+	for k, v := range data {
+		field, ok := c.fields[k]
+		if !ok {
+			field = newBigQueryColumn()
+			c.fields[k] = field
+		}
+		field.add(v)
+	}
+}
+
+func (c *bigQueryColumn) add(v any) {
+	switch val := v.(type) {
+	case nil:
+	case []any:
+		c.repeated = true
+		for _, elem := range val {
+			switch elem.(type) {
+			case nil, []any:
+				c.mixed = true
+			default:
+				c.addValue(elem)
+			}
+		}
+	default:
+		c.single = true
+		c.addValue(v)
+	}
+}
+
+func (c *bigQueryColumn) addValue(v any) {
+	switch val := v.(type) {
+	case map[string]any:
+		c.types["RECORD"] = true
+		c.addFields(val)
+	case *latlng.LatLng:
+		// Written as {"lat": ..., "lng": ...} by convertForJSON.
+		c.types["RECORD"] = true
+		c.addFields(map[string]any{"lat": val.GetLatitude(), "lng": val.GetLongitude()})
+	case bool:
+		c.types["BOOLEAN"] = true
+	case int64:
+		c.types["INTEGER"] = true
+	case float64:
+		c.types["FLOAT"] = true
+	case time.Time:
+		c.types["TIMESTAMP"] = true
+	case []byte:
+		// Base64, which BigQuery decodes into BYTES.
+		c.types["BYTES"] = true
+	default:
+		// Strings, references, and values convertForJSON formats as text.
+		c.types["STRING"] = true
+	}
+}
+
+// field returns the schema of the column. A column BigQuery can't type, with
+// values of several types or arrays it can't hold, is JSON; one that was only
+// ever null is a STRING.
+func (c *bigQueryColumn) field(name string) bigQueryField {
+	f := bigQueryField{Name: name, Type: "STRING", Mode: "NULLABLE"}
+	if c.repeated {
+		f.Mode = "REPEATED"
+	}
+	types := make([]string, 0, len(c.types))
+	for t := range c.types {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	switch {
+	case c.mixed || (c.repeated && c.single):
+		return bigQueryField{Name: name, Type: "JSON", Mode: "NULLABLE"}
+	case len(types) == 0:
+	case slices.Equal(types, []string{"FLOAT", "INTEGER"}):
+		f.Type = "FLOAT"
+	case len(types) > 1:
+		return bigQueryField{Name: name, Type: "JSON", Mode: "NULLABLE"}
+	case types[0] == "RECORD":
+		if len(c.fields) == 0 {
+			// Only empty maps: BigQuery has no RECORD without fields.
+			return bigQueryField{Name: name, Type: "JSON", Mode: "NULLABLE"}
+		}
+		f.Type = "RECORD"
+		for _, k := range sortedKeys(c.fields) {
+			f.Fields = append(f.Fields, c.fields[k].field(k))
+		}
+	default:
+		f.Type = types[0]
+	}
+	return f
+}
+
+// fields returns the table schema: the columns the JSON Lines writer adds,
+// then the data columns in name order.
+func (s *bigQuerySchema) fields(cfg exportConfig) []bigQueryField {
+	fields := []bigQueryField{{Name: "__path__", Type: "STRING", Mode: "NULLABLE"}}
+	for _, col := range parentColumns(cfg.parentLevels) {
+		fields = append(fields, bigQueryField{Name: col, Type: "STRING", Mode: "NULLABLE"})
+	}
+	if cfg.includeTimestamps {
+		fields = append(fields,
+			bigQueryField{Name: "__create_time__", Type: "TIMESTAMP", Mode: "NULLABLE"},
+			bigQueryField{Name: "__update_time__", Type: "TIMESTAMP", Mode: "NULLABLE"})
+	}
+	for _, k := range sortedKeys(s.columns.fields) {
+		fields = append(fields, s.columns.fields[k].field(k))
+	}
+	return fields
+}
+
+// inferBigQuerySchema builds the table schema of a collection's records.
+func inferBigQuerySchema(docs []docRecord) *bigQuerySchema {
+	s := newBigQuerySchema()
+	for _, doc := range docs {
+		s.add(doc.data)
+	}
+	return s
+}
+
+// writeBigQuerySchema writes the table schema next to the collection's output
+// file and returns its path. Like --emit-schema files it is never compressed.
+func writeBigQuerySchema(s *bigQuerySchema, displayPath string, cfg exportConfig) (string, error) {
+	b, err := json.MarshalIndent(s.fields(cfg), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding BigQuery schema: %w", err)
+	}
+	f, filePath, err := openOutputFile(displayPath, bigQuerySchemaExt, cfg)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return "", fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("closing %s: %w", filePath, err)
+	}
+	return filePath, nil
+}
+
+// validateBigQuery rejects options that --bigquery-json can't honor.
+func validateBigQuery(cfg exportConfig) error {
+	switch {
+	case cfg.prettyJSON:
+		return fmt.Errorf("BigQuery loads one object per line; --bigquery-json can't be combined with --pretty-json")
+	case cfg.splitDocuments:
+		return fmt.Errorf("--bigquery-json writes JSON Lines; it can't be combined with --split-documents")
+	case cfg.output == stdoutOutput:
+		return fmt.Errorf("--bigquery-json writes schema files; it can't be combined with --output -")
+	case cfg.append, cfg.resume:
+		// The schema would only cover the documents of this run.
+		return fmt.Errorf("--bigquery-json can't be combined with --append or --resume")
+	}
+	return nil
+}
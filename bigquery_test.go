@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+func TestBigQueryName(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"userId", "userId"},
+		{"address.city", "address_city"},
+		{"first name", "first_name"},
+		{"2fa", "_2fa"},
+		{"", "_"},
+		{"émoji✓", "_moji_"},
+		{"_TABLE_suffix", "__TABLE_suffix"},
+		{"_partitiontime", "__partitiontime"},
+		{"__path__", "__path__"},
+		{strings.Repeat("a", 301), strings.Repeat("a", 300)},
+	}
+	for _, tt := range tests {
+		if got := bigQueryName(tt.name); got != tt.want {
+			t.Errorf("bigQueryName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShapeRecord_BigQuery(t *testing.T) {
+	cfg := exportConfig{flatten: true, bigQuery: true}
+	data := map[string]any{
+		"first name": "Ada",
+		"address":    map[string]any{"zip-code": "123"},
+		"items":      []any{map[string]any{"unit price": 2.5}},
+		"ratio":      math.NaN(),
+		"max":        math.Inf(1),
+		"min":        math.Inf(-1),
+		"scores":     []any{1.5, math.Inf(1)},
+	}
+	got, err := shapeRecord(data, cfg)
+	if err != nil {
+		t.Fatalf("shapeRecord() error = %v", err)
+	}
+	want := map[string]any{
+		"first_name":       "Ada",
+		"address_zip_code": "123",
+		"items":            []any{map[string]any{"unit_price": 2.5}},
+		"ratio":            nil,
+		"max":              math.MaxFloat64,
+		"min":              -math.MaxFloat64,
+		"scores":           []any{1.5, math.MaxFloat64},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord() = %v, want %v", got, want)
+	}
+}
+
+func TestBigQueryRecord_Collision(t *testing.T) {
+	data := map[string]any{"a-b": 1, "a_b": 2, "Name": "x", "name": "y"}
+	if _, err := bigQueryRecord(data, exportConfig{}); err == nil || !strings.Contains(err.Error(), "BigQuery column") {
+		t.Errorf("bigQueryRecord() error = %v, want a collision", err)
+	}
+
+	got, err := bigQueryRecord(data, exportConfig{onCollision: onCollisionSuffix})
+	if err != nil {
+		t.Fatalf("bigQueryRecord(suffix) error = %v", err)
+	}
+	// Keys are taken in sorted order, so the suffixes are stable.
+	want := map[string]any{"Name": "x", "name_2": "y", "a_b": 1, "a_b_2": 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bigQueryRecord(suffix) = %v, want %v", got, want)
+	}
+
+	// The columns the writer adds are taken too.
+	if _, err := bigQueryRecord(map[string]any{"__PATH__": 1}, exportConfig{}); err == nil {
+		t.Error("bigQueryRecord(__PATH__) error = nil, want a collision with __path__")
+	}
+}
+
+func TestBigQuerySchema(t *testing.T) {
+	s := newBigQuerySchema()
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	s.add(map[string]any{
+		"name":    "Ada",
+		"age":     int64(36),
+		"score":   int64(1),
+		"active":  true,
+		"joined":  ts,
+		"avatar":  []byte("png"),
+		"tags":    []any{"a", "b"},
+		"address": map[string]any{"city": "London", "zip": nil},
+		"items":   []any{map[string]any{"sku": "x"}},
+		"loc":     &latlng.LatLng{Latitude: 1, Longitude: 2},
+		"nothing": nil,
+		"matrix":  []any{[]any{int64(1)}},
+		"mixed":   "x",
+		"empty":   map[string]any{},
+		"either":  []any{"a"},
+	})
+	s.add(map[string]any{
+		"score":  2.5,
+		"mixed":  int64(1),
+		"items":  []any{map[string]any{"qty": int64(2)}},
+		"either": "b",
+	})
+
+	got := s.fields(exportConfig{includeTimestamps: true})
+	want := []bigQueryField{
+		{Name: "__path__", Type: "STRING", Mode: "NULLABLE"},
+		{Name: "__create_time__", Type: "TIMESTAMP", Mode: "NULLABLE"},
+		{Name: "__update_time__", Type: "TIMESTAMP", Mode: "NULLABLE"},
+		{Name: "active", Type: "BOOLEAN", Mode: "NULLABLE"},
+		{Name: "address", Type: "RECORD", Mode: "NULLABLE", Fields: []bigQueryField{
+			{Name: "city", Type: "STRING", Mode: "NULLABLE"},
+			{Name: "zip", Type: "STRING", Mode: "NULLABLE"},
+		}},
+		{Name: "age", Type: "INTEGER", Mode: "NULLABLE"},
+		{Name: "avatar", Type: "BYTES", Mode: "NULLABLE"},
+		{Name: "either", Type: "JSON", Mode: "NULLABLE"},
+		{Name: "empty", Type: "JSON", Mode: "NULLABLE"},
+		{Name: "items", Type: "RECORD", Mode: "REPEATED", Fields: []bigQueryField{
+			{Name: "qty", Type: "INTEGER", Mode: "NULLABLE"},
+			{Name: "sku", Type: "STRING", Mode: "NULLABLE"},
+		}},
+		{Name: "joined", Type: "TIMESTAMP", Mode: "NULLABLE"},
+		{Name: "loc", Type: "RECORD", Mode: "NULLABLE", Fields: []bigQueryField{
+			{Name: "lat", Type: "FLOAT", Mode: "NULLABLE"},
+			{Name: "lng", Type: "FLOAT", Mode: "NULLABLE"},
+		}},
+		{Name: "matrix", Type: "JSON", Mode: "NULLABLE"},
+		{Name: "mixed", Type: "JSON", Mode: "NULLABLE"},
+		{Name: "name", Type: "STRING", Mode: "NULLABLE"},
+		{Name: "nothing", Type: "STRING", Mode: "NULLABLE"},
+		{Name: "score", Type: "FLOAT", Mode: "NULLABLE"},
+		{Name: "tags", Type: "STRING", Mode: "REPEATED"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("fields() =\n%s", gotJSON)
+	}
+}
+
+func TestWriteCollection_BigQuery(t *testing.T) {
+	cfg := exportConfig{output: t.TempDir(), format: "jsonl", bigQuery: true, timeFormat: bigQueryTimeFormat}
+	rec, err := shapeRecord(map[string]any{
+		"created at": time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.UTC),
+		"n":          int64(1),
+	}, cfg)
+	if err != nil {
+		t.Fatalf("shapeRecord() error = %v", err)
+	}
+	docs := []docRecord{{path: "users/a", data: rec}}
+	fieldSet := map[string]struct{}{"created_at": {}, "n": {}}
+
+	filePath, err := writeCollection(docs, fieldSet, "users", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"__path__":"users/a","created_at":"2024-01-15T10:30:00.123456Z","n":1}` + "\n"; string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+
+	schemaPath, err := writeBigQuerySchema(inferBigQuerySchema(docs), "users", cfg)
+	if err != nil {
+		t.Fatalf("writeBigQuerySchema() error = %v", err)
+	}
+	if !strings.HasSuffix(schemaPath, "users.bqschema.json") {
+		t.Errorf("schema path = %s, want users.bqschema.json", schemaPath)
+	}
+	b, err := os.ReadFile(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields []bigQueryField
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("schema is not a JSON array of fields: %v", err)
+	}
+	want := []bigQueryField{
+		{Name: "__path__", Type: "STRING", Mode: "NULLABLE"},
+		{Name: "created_at", Type: "TIMESTAMP", Mode: "NULLABLE"},
+		{Name: "n", Type: "INTEGER", Mode: "NULLABLE"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("schema = %+v, want %+v", fields, want)
+	}
+}
+
+func TestValidateBigQuery(t *testing.T) {
+	base := exportConfig{format: "jsonl", bigQuery: true, output: "out"}
+	if err := validateBigQuery(base); err != nil {
+		t.Errorf("validateBigQuery() error = %v", err)
+	}
+	invalid := map[string]func(*exportConfig){
+		"--pretty-json":     func(c *exportConfig) { c.prettyJSON = true },
+		"--split-documents": func(c *exportConfig) { c.splitDocuments = true },
+		"--output -":        func(c *exportConfig) { c.output = stdoutOutput },
+		"--append":          func(c *exportConfig) { c.append = true },
+		"--resume":          func(c *exportConfig) { c.resume = true },
+	}
+	for name, mutate := range invalid {
+		cfg := base
+		mutate(&cfg)
+		if err := validateBigQuery(cfg); err == nil {
+			t.Errorf("validateBigQuery(%s) error = nil, want error", name)
+		}
+	}
+}
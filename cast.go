@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The types --cast converts string values to.
+const (
+	castInt   = "int"
+	castFloat = "float"
+	castBool  = "bool"
+)
+
+// parseCast parses the --cast list of field:type pairs, e.g.
+// "price:float,count:int". Fields are paths, as for --hash-fields.
+func parseCast(raw string) (map[string]string, error) {
+	casts := make(map[string]string)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, typ, ok := strings.Cut(entry, ":")
+		field, typ = strings.TrimSpace(field), strings.TrimSpace(typ)
+		if !ok || field == "" || typ == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected field:type", entry)
+		}
+		switch typ {
+		case castInt, castFloat, castBool:
+		default:
+			return nil, fmt.Errorf("invalid type %q for %q: expected int, float or bool", typ, field)
+		}
+		if _, dup := casts[field]; dup {
+			return nil, fmt.Errorf("%q is cast more than once", field)
+		}
+		casts[field] = typ
+	}
+	return casts, nil
+}
+
+// castFields returns data, the document at docPath, with the string values of
+// the --cast fields converted to their types, as if Firestore had stored them
+// so: "19.90" becomes the float 19.9. The elements of an array of strings are
+// converted one by one. A value that doesn't parse keeps its string, with a
+// warning the first time its field has one in the collection; values of other
+// types are left alone. data isn't modified; the maps holding it are copied
+// instead, unless nothing in them is cast.
+func castFields(data map[string]any, docPath string, cfg exportConfig) map[string]any {
+	if len(cfg.casts) == 0 {
+		return data
+	}
+	return castMap("", data, docPath, cfg.casts)
+}
+
+func castMap(prefix string, data map[string]any, docPath string, casts map[string]string) map[string]any {
+	if prefix != "" && !castsUnder(prefix, casts) {
+		return data
+	}
+	out := make(map[string]any, len(data))
+	for k, v := range data {
+		name := prefix + k
+		if typ, ok := casts[name]; ok {
+			v = castField(name, typ, v, docPath)
+		} else if m, ok := v.(map[string]any); ok {
+			v = castMap(name+".", m, docPath, casts)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// castsUnder reports whether a --cast field is nested under prefix.
+func castsUnder(prefix string, casts map[string]string) bool {
+	for field := range casts {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func castField(field, typ string, v any, docPath string) any {
+	switch val := v.(type) {
+	case string:
+		if cast, ok := parseCastValue(typ, val); ok {
+			return cast
+		}
+		key := castFailure{collection: collectionPattern(docPath), field: field}
+		if _, warned := castFailures.LoadOrStore(key, true); !warned {
+			printWarn("Field %q of %s has a value that isn't a valid %s, %q; --cast writes such values as strings", field, docPath, typ, val)
+		}
+	case []any:
+		elems := make([]any, len(val))
+		for i, elem := range val {
+			elems[i] = castField(field, typ, elem, docPath)
+		}
+		return elems
+	}
+	return v
+}
+
+// parseCastValue converts s, with surrounding spaces trimmed, to typ. bool
+// accepts what strconv.ParseBool does: true, false, 1, 0, t, f and their
+// capitalized forms.
+func parseCastValue(typ, s string) (any, bool) {
+	s = strings.TrimSpace(s)
+	var v any
+	var err error
+	switch typ {
+	case castInt:
+		v, err = strconv.ParseInt(s, 10, 64)
+	case castFloat:
+		v, err = strconv.ParseFloat(s, 64)
+	case castBool:
+		v, err = strconv.ParseBool(s)
+	}
+	return v, err == nil
+}
+
+// castFailure identifies a field of a collection whose unparsable values
+// --cast has warned about.
+type castFailure struct {
+	collection string
+	field      string
+}
+
+// castFailures records the castFailure of every warning --cast has printed.
+var castFailures sync.Map
+
+// collectionPattern returns the path of the collection holding the document
+// at docPath without the IDs of its parent documents, users/orders for
+// users/alice/orders/1, so every sub-collection of that name shares it.
+func collectionPattern(docPath string) string {
+	segments := strings.Split(collectionPath(docPath), "/")
+	names := make([]string, 0, len(segments)/2+1)
+	for i := 0; i < len(segments); i += 2 {
+		names = append(names, segments[i])
+	}
+	return strings.Join(names, "/")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCast(t *testing.T) {
+	got, err := parseCast(" price:float, count : int,address.zip:int,active:bool,")
+	if err != nil {
+		t.Fatalf("parseCast() error = %v", err)
+	}
+	want := map[string]string{"price": "float", "count": "int", "address.zip": "int", "active": "bool"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCast() = %v, want %v", got, want)
+	}
+	for _, in := range []string{"price", "price:", ":int", "price:decimal", "price:int,price:float"} {
+		if _, err := parseCast(in); err == nil {
+			t.Errorf("parseCast(%q) error = nil, want error", in)
+		}
+	}
+}
+
+func TestCastFields(t *testing.T) {
+	cfg := exportConfig{casts: map[string]string{
+		"price": "float", "count": "int", "active": "bool", "address.zip": "int", "codes": "int", "note": "int",
+	}}
+	data := map[string]any{
+		"price":   " 19.90 ",
+		"count":   int64(3), // already a number
+		"active":  "TRUE",
+		"address": map[string]any{"zip": "01234", "city": "Berlin"},
+		"codes":   []any{"1", "x", nil},
+		"note":    "n/a",
+		"name":    "42",
+	}
+	var got map[string]any
+	out := captureStderr(t, func() { got = castFields(data, "products/p1", cfg) })
+	want := map[string]any{
+		"price":   19.9,
+		"count":   int64(3),
+		"active":  true,
+		"address": map[string]any{"zip": int64(1234), "city": "Berlin"},
+		"codes":   []any{int64(1), "x", nil},
+		"note":    "n/a",
+		"name":    "42",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("castFields() = %v, want %v", got, want)
+	}
+	if data["price"] != " 19.90 " {
+		t.Error("castFields() modified its input")
+	}
+	for _, field := range []string{`"codes" of products/p1`, `"note" of products/p1`} {
+		if !strings.Contains(out, field) {
+			t.Errorf("no warning for %s:\n%s", field, out)
+		}
+	}
+
+	// Nested maps without a cast field are shared, not copied.
+	nested := map[string]any{"city": "Berlin"}
+	got = castFields(map[string]any{"price": "1", "home": nested}, "products/p2", cfg)
+	if reflect.ValueOf(got["home"]).UnsafePointer() != reflect.ValueOf(nested).UnsafePointer() {
+		t.Error("castFields() copied a map without cast fields")
+	}
+}
+
+func TestCastFields_WarnsPerCollection(t *testing.T) {
+	cfg := exportConfig{casts: map[string]string{"qty": "int"}}
+	data := map[string]any{"qty": "many"}
+	out := captureStderr(t, func() {
+		castFields(data, "shops/s1/stock/a", cfg)
+		castFields(data, "shops/s2/stock/b", cfg) // same collection, no second warning
+		castFields(data, "warehouse/w", cfg)
+	})
+	if got := strings.Count(out, `"qty"`); got != 2 {
+		t.Errorf("got %d warnings, want one per collection:\n%s", got, out)
+	}
+	for _, doc := range []string{"shops/s1/stock/a", "warehouse/w"} {
+		if !strings.Contains(out, doc) {
+			t.Errorf("no warning naming %s:\n%s", doc, out)
+		}
+	}
+}
+
+func TestCollectionPattern(t *testing.T) {
+	for docPath, want := range map[string]string{
+		"users/alice":               "users",
+		"users/alice/orders/1":      "users/orders",
+		"a/1/b/2/c/3":               "a/b/c",
+		"teams/t/users/u/tags/tag1": "teams/users/tags",
+	} {
+		if got := collectionPattern(docPath); got != want {
+			t.Errorf("collectionPattern(%q) = %q, want %q", docPath, got, want)
+		}
+	}
+}
+
+func TestPrepareRecord_Cast(t *testing.T) {
+	// Casts apply before masking and --rename, to the field's own name.
+	cfg := exportConfig{
+		casts:      map[string]string{"amount": "float"},
+		hashFields: map[string]bool{"amount": true},
+		rename:     map[string]string{"amount": "amount_hash"},
+	}
+	got, err := prepareRecord(map[string]any{"amount": "2.50"}, "payments/p1", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hashValue(2.5); got["amount_hash"] != want {
+		t.Errorf("amount_hash = %v, want the digest of 2.5", got["amount_hash"])
+	}
+}
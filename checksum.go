@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// checksumExt is appended to the name of a file for its --checksums sidecar.
+const checksumExt = ".sha256"
+
+// checksumSet collects the SHA-256 digests of the files an export writes, for
+// the manifest and --checksums. Collections are written concurrently, so it
+// is shared through a pointer in exportConfig.
+type checksumSet struct {
+	sidecars bool // write a <file>.sha256 next to every file
+
+	mu   sync.Mutex
+	sums map[string]string // file path → hex digest
+}
+
+func newChecksumSet(sidecars bool) *checksumSet {
+	return &checksumSet{sidecars: sidecars, sums: make(map[string]string)}
+}
+
+// get returns the digest of the file at filePath, or "" if it wasn't hashed.
+func (cs *checksumSet) get(filePath string) string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.sums[filePath]
+}
+
+// getAll returns the digests of files, in order.
+func (cs *checksumSet) getAll(files []string) []string {
+	if len(files) == 0 {
+		return nil
+	}
+	sums := make([]string, len(files))
+	for i, f := range files {
+		sums[i] = cs.get(f)
+	}
+	return sums
+}
+
+func (cs *checksumSet) add(filePath, sum string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.sums[filePath] = sum
+}
+
+// addFile hashes the finished file at filePath from disk, for writers that
+// don't go through openNamedFile, such as --resume's, which appends to a file
+// an earlier run started.
+func (cs *checksumSet) addFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("hashing %s: %w", filePath, err)
+	}
+	cs.add(filePath, fmt.Sprintf("%x", h.Sum(nil)))
+	return nil
+}
+
+// hashedFile hashes everything written to the underlying file. It sits
+// below compression, so the digest is that of the bytes on disk.
+type hashedFile struct {
+	f        io.WriteCloser
+	h        hash.Hash
+	name     string // name and ext as passed to openNamedFile, for the sidecar
+	ext      string
+	filePath string
+	cfg      exportConfig
+}
+
+func newHashedFile(f io.WriteCloser, name, ext, filePath string, cfg exportConfig) *hashedFile {
+	return &hashedFile{f: f, h: sha256.New(), name: name, ext: ext, filePath: filePath, cfg: cfg}
+}
+
+func (hf *hashedFile) Write(p []byte) (int, error) {
+	n, err := hf.f.Write(p)
+	hf.h.Write(p[:n])
+	return n, err
+}
+
+// Close closes the file, then records its digest and, with --checksums,
+// writes the sidecar. A file that failed to close gets neither.
+func (hf *hashedFile) Close() error {
+	if err := hf.f.Close(); err != nil {
+		return err
+	}
+	sum := fmt.Sprintf("%x", hf.h.Sum(nil))
+	cs := hf.cfg.checksums
+	cs.add(hf.filePath, sum)
+	if !cs.sidecars {
+		return nil
+	}
+	return writeChecksumFile(sum, hf.name, hf.ext, hf.filePath, hf.cfg)
+}
+
+// writeChecksumFile writes the sidecar of the file at filePath in the format
+// of sha256sum, so `sha256sum -c users.csv.sha256` checks it from its
+// directory.
+func writeChecksumFile(sum, name, ext, filePath string, cfg exportConfig) error {
+	cfg.checksums = nil // the sidecar itself isn't hashed
+	f, sidecarPath, err := openNamedFile(name, ext+checksumExt, cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s  %s\n", sum, path.Base(filepath.ToSlash(filePath))); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", sidecarPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", sidecarPath, err)
+	}
+	return nil
+}
+
+// validateChecksums rejects options that --checksums can't honor. Files that
+// are appended to would only be hashed from where writing started.
+func validateChecksums(cfg exportConfig) error {
+	switch {
+	case cfg.output == stdoutOutput:
+		return fmt.Errorf("--checksums writes checksum files; it can't be combined with --output -")
+	case cfg.append:
+		return fmt.Errorf("--checksums can't be combined with --append")
+	case cfg.resume:
+		return fmt.Errorf("--checksums can't be combined with --resume")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteCollection_Checksums(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice"}}}
+	cfg := exportConfig{output: tmpDir, format: "csv", compression: "gzip", checksums: newChecksumSet(true)}
+
+	filePath, err := writeCollection(docs, map[string]struct{}{"name": {}}, "users", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The digest is of the compressed bytes, as sha256sum would compute it.
+	want := fmt.Sprintf("%x", sha256.Sum256(b))
+	if got := cfg.checksums.get(filePath); got != want {
+		t.Errorf("checksum = %q, want %q", got, want)
+	}
+
+	sidecar, err := os.ReadFile(filePath + checksumExt)
+	if err != nil {
+		t.Fatalf("reading sidecar: %v", err)
+	}
+	if got, wantLine := string(sidecar), want+"  users.csv.gz\n"; got != wantLine {
+		t.Errorf("sidecar = %q, want %q", got, wantLine)
+	}
+	if cfg.checksums.get(filePath+checksumExt) != "" {
+		t.Error("sidecar was hashed itself")
+	}
+}
+
+func TestWriteCollection_ChecksumsWithoutSidecars(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice"}}}
+	cfg := exportConfig{output: tmpDir, format: "jsonl", checksums: newChecksumSet(false)}
+
+	filePath, err := writeCollection(docs, map[string]struct{}{"name": {}}, "users", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if cfg.checksums.get(filePath) == "" {
+		t.Error("no checksum recorded for the output file")
+	}
+	if _, err := os.Stat(filePath + checksumExt); !os.IsNotExist(err) {
+		t.Errorf("sidecar written without --checksums (stat error = %v)", err)
+	}
+}
+
+func TestChecksumSet_GetAll(t *testing.T) {
+	cs := newChecksumSet(false)
+	cs.add(filepath.Join("out", "a.part001.csv"), "aa")
+	cs.add(filepath.Join("out", "a.part002.csv"), "bb")
+
+	got := cs.getAll([]string{filepath.Join("out", "a.part001.csv"), filepath.Join("out", "a.part002.csv"), "missing"})
+	if strings.Join(got, ",") != "aa,bb," {
+		t.Errorf("getAll() = %q, want [aa bb \"\"]", got)
+	}
+	if got := cs.getAll(nil); got != nil {
+		t.Errorf("getAll(nil) = %q, want nil", got)
+	}
+}
+
+func TestValidateChecksums(t *testing.T) {
+	base := exportConfig{output: ".", format: "csv", checksumFiles: true}
+	if err := validateChecksums(base); err != nil {
+		t.Errorf("validateChecksums() error = %v", err)
+	}
+	invalid := map[string]func(*exportConfig){
+		"--output -": func(c *exportConfig) { c.output = stdoutOutput },
+		"--append":   func(c *exportConfig) { c.append = true },
+		"--resume":   func(c *exportConfig) { c.resume = true },
+	}
+	for wantErr, modify := range invalid {
+		cfg := base
+		modify(&cfg)
+		if err := validateChecksums(cfg); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("validateChecksums() error = %v, want containing %q", err, wantErr)
+		}
+	}
+}
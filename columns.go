@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+// Values accepted by --on-collision.
+const (
+	onCollisionError  = "error"
+	onCollisionSuffix = "suffix"
+)
+
+// simpleFieldName matches field names that need no quoting in a field path.
+var simpleFieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// columnSet builds the columns of a single shaped record. It remembers which
+// field produced each column, so that two fields landing in the same column
+// (a nested a → b and a literal "a.b" with --flatten, say) are reported or
+// renamed instead of one silently overwriting the other.
+type columnSet struct {
+	out      map[string]any
+	sources  map[string]string // column → description of the field it came from
+	reserved map[string]bool   // columns the writer adds next to the fields
+	rename   map[string]string // --rename: column → new name
+	exclude  map[string]bool   // --exclude-fields
+	suffix   bool
+	flatten  bool
+	geo      bool
+	// arrays is --flatten-arrays: elements up to index maxIndex become
+	// field.N columns.
+	arrays   bool
+	maxIndex int
+}
+
+// newColumnSet returns an empty columnSet for cfg. The reserved columns of
+// the output format are taken up front, so fields can't claim them.
+func newColumnSet(size int, cfg exportConfig) *columnSet {
+	c := &columnSet{
+		out:      make(map[string]any, size),
+		reserved: make(map[string]bool),
+		sources:  make(map[string]string, size+4+len(cfg.rename)),
+		rename:   cfg.rename,
+		exclude:  cfg.excludeFields,
+		suffix:   cfg.onCollision == onCollisionSuffix,
+		flatten:  cfg.flatten,
+		geo:      cfg.geoColumns,
+		arrays:   cfg.flattenArrays,
+		maxIndex: cfg.maxArrayIndex,
+	}
+	for _, col := range reservedColumns(cfg) {
+		c.sources[col] = "the " + col + " column"
+		c.reserved[col] = true
+	}
+	// Rename targets are held for the renamed column, so a field that already
+	// has the new name collides with it even in documents without the old one.
+	for from, to := range cfg.rename {
+		if _, taken := c.sources[to]; !taken {
+			c.sources[to] = renameSource(from, to)
+		}
+	}
+	return c
+}
+
+// renameSource describes the column a --rename pair reserves.
+func renameSource(from, to string) string {
+	return fmt.Sprintf("--rename %s:%s", from, to)
+}
+
+// parseRename parses a comma-separated --rename value such as
+// "userId:user_id,createdAt:created_at" into a map from column to new name.
+// The old names are output columns, so with --flatten they can be dotted
+// paths like "address.city".
+func parseRename(raw string) (map[string]string, error) {
+	rename := make(map[string]string)
+	targets := make(map[string]string)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(entry, ":")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected oldName:newName", entry)
+		}
+		if _, dup := rename[from]; dup {
+			return nil, fmt.Errorf("%q is renamed more than once", from)
+		}
+		if prev, dup := targets[to]; dup {
+			return nil, fmt.Errorf("%q and %q are both renamed to %q", prev, from, to)
+		}
+		rename[from] = to
+		targets[to] = from
+	}
+	return rename, nil
+}
+
+// reservedColumns returns the columns the writer adds next to the fields.
+func reservedColumns(cfg exportConfig) []string {
+	cols := []string{"__path__"}
+	if cfg.singleFile {
+		cols = append(cols, "__collection__")
+	}
+	cols = append(cols, parentColumns(cfg.parentLevels)...)
+	if cfg.includeTimestamps {
+		cols = append(cols, "__create_time__", "__update_time__")
+	}
+	if cfg.baseline != "" {
+		cols = append(cols, changedColumn)
+	}
+	if cfg.withTypes && cfg.format != "jsonl" && cfg.format != "parquet" {
+		cols = append(cols, "__fs_types__")
+	}
+	return cols
+}
+
+// parentColumns returns the --path-columns columns of documents with levels
+// parent documents, the root first. The immediate parent's are
+// __parent_collection__ and __parent_id__; the one above it gets
+// __parent_2_collection__ and __parent_2_id__, and so on.
+func parentColumns(levels int) []string {
+	cols := make([]string, 0, 2*levels)
+	for level := levels; level >= 1; level-- {
+		name := "__parent"
+		if level > 1 {
+			name += fmt.Sprintf("_%d", level)
+		}
+		cols = append(cols, name+"_collection__", name+"_id__")
+	}
+	return cols
+}
+
+// parentValues returns the values of parentColumns(levels) for the document at
+// docPath: the collection and ID of each of its parent documents.
+func parentValues(docPath string, levels int) []string {
+	segments := strings.Split(docPath, "/")
+	// The document's own collection and ID come last; its parents precede them.
+	segments = segments[:max(len(segments)-2, 0)]
+	vals := make([]string, 2*levels)
+	if extra := len(segments) - 2*levels; extra >= 0 {
+		copy(vals, segments[extra:])
+	}
+	return vals
+}
+
+// isParentColumn reports whether column is one of the parentColumns. Field
+// names of the form __name__ are reserved by Firestore, so it can't be a field.
+func isParentColumn(column string) bool {
+	return strings.HasPrefix(column, "__parent") && (strings.HasSuffix(column, "_collection__") || strings.HasSuffix(column, "_id__"))
+}
+
+// validatePathColumns rejects options that --path-columns can't honor. Every
+// file needs a fixed number of parent columns, so its documents must all be
+// equally deep.
+func validatePathColumns(cfg exportConfig) error {
+	switch {
+	case cfg.group != "":
+		return fmt.Errorf("--path-columns can't be combined with --collection-group, whose documents can be at any depth")
+	case cfg.singleFile:
+		return fmt.Errorf("--path-columns can't be combined with --single-file")
+	}
+	return nil
+}
+
+// addFields adds the fields of data, whose field path is path. Keys are
+// visited in sorted order so that, on a collision, the same field keeps the
+// plain column name in every document. Excluded fields are skipped, along
+// with everything below them when they are maps.
+func (c *columnSet) addFields(path []string, data map[string]any) error {
+	for _, k := range sortedKeys(data) {
+		v := data[k]
+		p := append(path[:len(path):len(path)], k)
+		column := strings.Join(p, ".")
+		if c.exclude[column] {
+			continue
+		}
+		if m, ok := v.(map[string]any); ok && c.flatten && len(m) > 0 {
+			if err := c.addFields(p, m); err != nil {
+				return err
+			}
+			continue
+		}
+		if elems, ok := v.([]any); ok && c.arrays && len(elems) > 0 {
+			if err := c.addFields(p, c.indexed(column, elems)); err != nil {
+				return err
+			}
+			continue
+		}
+		source := "field " + fieldPathString(p)
+		if to, ok := c.rename[column]; ok {
+			if c.sources[to] == renameSource(column, to) {
+				delete(c.sources, to)
+			}
+			column, source = to, source+" (renamed)"
+		}
+		if geo, ok := v.(*latlng.LatLng); ok && c.geo {
+			if err := c.add(column+".lat", source+" (latitude)", geo.GetLatitude()); err != nil {
+				return err
+			}
+			if err := c.add(column+".lng", source+" (longitude)", geo.GetLongitude()); err != nil {
+				return err
+			}
+			continue
+		}
+		// A top-level field named like a reserved column, such as a literal
+		// __path__, clashes with it in any export, not through --flatten or
+		// --rename, so it is moved to a suffixed column instead of failing.
+		if len(p) == 1 && column == k && c.reserved[column] {
+			column = c.free(column)
+		}
+		if err := c.add(column, source, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexed returns the elements of the array field column keyed by their
+// index, for addFields to turn into column.0, column.1 and so on, so maps in
+// the elements are flattened with --flatten and nested arrays are indexed
+// again. Elements past maxIndex are dropped, with a warning once per column.
+func (c *columnSet) indexed(column string, elems []any) map[string]any {
+	if len(elems) > c.maxIndex+1 {
+		if _, warned := droppedElements.LoadOrStore(column, true); !warned {
+			printWarn("Array field %q has more than %d elements; --max-array-index %d drops the rest", column, c.maxIndex+1, c.maxIndex)
+		}
+		elems = elems[:c.maxIndex+1]
+	}
+	m := make(map[string]any, len(elems))
+	for i, elem := range elems {
+		m[strconv.Itoa(i)] = elem
+	}
+	return m
+}
+
+// droppedElements records the array columns whose extra elements
+// --flatten-arrays has warned about.
+var droppedElements sync.Map
+
+// add sets column to v. If the column is taken, the value is stored under the
+// first free column_N (N ≥ 2) with --on-collision suffix, and an error is
+// returned otherwise.
+func (c *columnSet) add(column, source string, v any) error {
+	if prev, taken := c.sources[column]; taken {
+		if !c.suffix {
+			return fmt.Errorf("%s and %s both map to column %q; rename one or use --on-collision suffix", prev, source, column)
+		}
+		column = c.free(column)
+	}
+	c.out[column] = v
+	c.sources[column] = source
+	return nil
+}
+
+// free returns the first column_N (N ≥ 2) that isn't taken.
+func (c *columnSet) free(column string) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s_%d", column, n)
+		if _, taken := c.sources[candidate]; !taken {
+			return candidate
+		}
+	}
+}
+
+// fieldPathString renders a field path the way Firestore writes it: segments
+// joined by dots, with segments that aren't simple names in backticks.
+func fieldPathString(path []string) string {
+	parts := make([]string, len(path))
+	for i, seg := range path {
+		if simpleFieldName.MatchString(seg) {
+			parts[i] = seg
+		} else {
+			parts[i] = "`" + strings.ReplaceAll(seg, "`", "\\`") + "`"
+		}
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+func TestShapeRecord_Collision(t *testing.T) {
+	data := map[string]any{
+		"a":   map[string]any{"b": int64(1)},
+		"a.b": int64(2),
+	}
+
+	_, err := shapeRecord(data, exportConfig{flatten: true, onCollision: onCollisionError})
+	if err == nil {
+		t.Fatal("expected collision error")
+	}
+	for _, want := range []string{"field a.b", "field `a.b`", `column "a.b"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %s", err, want)
+		}
+	}
+
+	got, err := shapeRecord(data, exportConfig{flatten: true, onCollision: onCollisionSuffix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"a.b": int64(1), "a.b_2": int64(2)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord(suffix) = %v, want %v", got, want)
+	}
+}
+
+func TestShapeRecord_GeoPointCollision(t *testing.T) {
+	data := map[string]any{
+		"loc":     &latlng.LatLng{Latitude: 1, Longitude: 2},
+		"loc.lat": "literal",
+	}
+	got, err := shapeRecord(data, exportConfig{geoColumns: true, onCollision: onCollisionSuffix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"loc.lat": float64(1), "loc.lng": float64(2), "loc.lat_2": "literal"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord = %v, want %v", got, want)
+	}
+}
+
+func TestShapeRecord_ReservedColumns(t *testing.T) {
+	data := map[string]any{"__path__": "x", "__fs_types__": "y"}
+
+	// A literal field named like a reserved column is suffixed even with the
+	// default --on-collision error.
+	for _, onCollision := range []string{onCollisionError, onCollisionSuffix} {
+		got, err := shapeRecord(data, exportConfig{withTypes: true, onCollision: onCollision})
+		if err != nil {
+			t.Fatalf("shapeRecord(--on-collision %s) error = %v", onCollision, err)
+		}
+		want := map[string]any{"__path___2": "x", "__fs_types___2": "y"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("shapeRecord(--on-collision %s) = %v, want %v", onCollision, got, want)
+		}
+	}
+
+	// __fs_types__ is only reserved in CSV output with --with-types.
+	got, err := shapeRecord(map[string]any{"__fs_types__": "y"}, exportConfig{})
+	if err != nil || got["__fs_types__"] != "y" {
+		t.Errorf("shapeRecord = %v, %v; want __fs_types__ kept", got, err)
+	}
+}
+
+func TestShapeRecord_Rename(t *testing.T) {
+	cfg := exportConfig{flatten: true, rename: map[string]string{"userId": "user_id", "address.city": "city"}}
+	data := map[string]any{
+		"userId":  "u1",
+		"address": map[string]any{"city": "Berlin", "zip": "10115"},
+	}
+	got, err := shapeRecord(data, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"user_id": "u1", "city": "Berlin", "address.zip": "10115"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord = %v, want %v", got, want)
+	}
+
+	// A field that already has the new name collides whether or not the
+	// document also has the old one.
+	for _, data := range []map[string]any{
+		{"userId": "u1", "user_id": "u2"},
+		{"user_id": "u2"},
+	} {
+		_, err := shapeRecord(data, cfg)
+		if err == nil || !strings.Contains(err.Error(), `column "user_id"`) {
+			t.Errorf("shapeRecord(%v) error = %v, want collision on user_id", data, err)
+		}
+	}
+
+	cfg.onCollision = onCollisionSuffix
+	got, err = shapeRecord(map[string]any{"userId": "u1", "user_id": "u2"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["user_id"] != "u1" || got["user_id_2"] != "u2" {
+		t.Errorf("shapeRecord(suffix) = %v, want the renamed field in user_id", got)
+	}
+
+	if _, err := shapeRecord(map[string]any{"id": "x"}, exportConfig{rename: map[string]string{"id": "__path__"}}); err == nil {
+		t.Error("expected error renaming to a reserved column")
+	}
+}
+
+func TestShapeRecord_ExcludeFields(t *testing.T) {
+	data := map[string]any{
+		"name":     "Alice",
+		"password": "secret",
+		"address":  map[string]any{"city": "Berlin", "zip": "10115"},
+		"profile":  map[string]any{"bio": "long text"},
+	}
+	exclude := map[string]bool{"password": true, "address.zip": true, "profile": true}
+
+	got, err := shapeRecord(data, exportConfig{excludeFields: exclude})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"name": "Alice", "address": map[string]any{"city": "Berlin", "zip": "10115"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord = %v, want %v", got, want)
+	}
+
+	// With --flatten, dotted names drop single leaves and map names drop
+	// everything below them.
+	got, err = shapeRecord(data, exportConfig{flatten: true, excludeFields: exclude})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]any{"name": "Alice", "address.city": "Berlin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord(flatten) = %v, want %v", got, want)
+	}
+}
+
+func TestShapeRecord_FlattenArrays(t *testing.T) {
+	data := map[string]any{
+		"tags":   []any{"a", "b", "c"},
+		"items":  []any{map[string]any{"price": 2.5}},
+		"matrix": []any{[]any{int64(1), int64(2)}},
+		"empty":  []any{},
+	}
+	cfg := exportConfig{flatten: true, flattenArrays: true, maxArrayIndex: 1}
+	got, err := shapeRecord(data, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"tags.0":        "a",
+		"tags.1":        "b",
+		"items.0.price": 2.5,
+		"matrix.0.0":    int64(1),
+		"matrix.0.1":    int64(2),
+		"empty":         []any{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord() = %v, want %v", got, want)
+	}
+
+	// Without --flatten, map elements stay JSON cells.
+	got, err = shapeRecord(map[string]any{"items": []any{map[string]any{"price": 2.5}}}, exportConfig{flattenArrays: true, maxArrayIndex: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"items.0": map[string]any{"price": 2.5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord(no --flatten) = %v, want %v", got, want)
+	}
+
+	// An indexed column can collide with a field named like it.
+	if _, err := shapeRecord(map[string]any{"tags": []any{"a"}, "tags.0": "x"}, exportConfig{flattenArrays: true, maxArrayIndex: 9}); err == nil {
+		t.Error("expected collision error for tags.0")
+	}
+}
+
+func TestParseRename(t *testing.T) {
+	got, err := parseRename("userId:user_id, createdAt : created_at,,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"userId": "user_id", "createdAt": "created_at"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRename() = %v, want %v", got, want)
+	}
+
+	invalid := map[string]string{
+		"userId":  "expected oldName:newName",
+		"userId:": "expected oldName:newName",
+		"a:x,a:y": `"a" is renamed more than once`,
+		"a:x,b:x": `"a" and "b" are both renamed to "x"`,
+	}
+	for input, wantErr := range invalid {
+		if _, err := parseRename(input); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("parseRename(%q) error = %v, want containing %q", input, err, wantErr)
+		}
+	}
+}
+
+func TestFieldPathString(t *testing.T) {
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"a", "b"}, "a.b"},
+		{[]string{"a.b"}, "`a.b`"},
+		{[]string{"user", "first name"}, "user.`first name`"},
+		{[]string{"9lives"}, "`9lives`"},
+	}
+	for _, tt := range tests {
+		if got := fieldPathString(tt.path); got != tt.want {
+			t.Errorf("fieldPathString(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParentColumns(t *testing.T) {
+	tests := []struct {
+		docPath string
+		levels  int
+		cols    []string
+		vals    []string
+	}{
+		{"users/a", 0, []string{}, []string{}},
+		{"users/a/orders/1", 1, []string{"__parent_collection__", "__parent_id__"}, []string{"users", "a"}},
+		{
+			"users/a/orders/1/items/x", 2,
+			[]string{"__parent_2_collection__", "__parent_2_id__", "__parent_collection__", "__parent_id__"},
+			[]string{"users", "a", "orders", "1"},
+		},
+		// A shallower document than the collection's depth leaves the cells empty.
+		{"users/a", 1, []string{"__parent_collection__", "__parent_id__"}, []string{"", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.docPath, func(t *testing.T) {
+			if got := parentColumns(tt.levels); !reflect.DeepEqual(got, tt.cols) {
+				t.Errorf("parentColumns(%d) = %q, want %q", tt.levels, got, tt.cols)
+			}
+			if got := parentValues(tt.docPath, tt.levels); !reflect.DeepEqual(got, tt.vals) {
+				t.Errorf("parentValues(%q, %d) = %q, want %q", tt.docPath, tt.levels, got, tt.vals)
+			}
+			for _, col := range tt.cols {
+				if !isParentColumn(col) {
+					t.Errorf("isParentColumn(%q) = false", col)
+				}
+			}
+		})
+	}
+	for _, col := range []string{"__path__", "__collection__", "parent_id", "__create_time__"} {
+		if isParentColumn(col) {
+			t.Errorf("isParentColumn(%q) = true", col)
+		}
+	}
+}
+
+func TestValidatePathColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"collections", exportConfig{pathColumns: true}, ""},
+		{"collection group", exportConfig{pathColumns: true, group: "orders"}, "--collection-group"},
+		{"single file", exportConfig{pathColumns: true, singleFile: true}, "--single-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePathColumns(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validatePathColumns() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePathColumns() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
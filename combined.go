@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sync"
+)
+
+// combinedName is the output name of the --single-file CSV, before
+// --file-prefix, --file-suffix and the extension are applied.
+const combinedName = "export"
+
+// combinedOutput gathers the documents of every exported collection for
+// --single-file, which writes them to one CSV once all collections are read.
+// Collections are read in parallel with --concurrency, so access is locked.
+type combinedOutput struct {
+	mu       sync.Mutex
+	docs     map[string][]docRecord // keyed by display path
+	fieldSet map[string]struct{}
+}
+
+func newCombinedOutput() *combinedOutput {
+	return &combinedOutput{
+		docs:     make(map[string][]docRecord),
+		fieldSet: make(map[string]struct{}),
+	}
+}
+
+// add keeps the documents of one collection and merges its fields into the
+// shared header.
+func (c *combinedOutput) add(displayPath string, docs []docRecord, fieldSet map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs[displayPath] = docs
+	for k := range fieldSet {
+		c.fieldSet[k] = struct{}{}
+	}
+}
+
+// write writes the gathered documents in the order of results, so the file
+// is the same whatever order the collections finished in, and returns its
+// path. A field holding different types in different collections shares one
+// column; every cell is text in CSV, so the values are written as they are.
+// With --emit-schema one schema covers the file, flagging such fields as
+// conflicts.
+func (c *combinedOutput) write(results []exportResult, cfg exportConfig) (string, []string, error) {
+	var docs []docRecord
+	for _, r := range results {
+		docs = append(docs, c.docs[r.collection]...)
+	}
+	filePath, parts, err := writeCollectionFiles(docs, c.fieldSet, combinedName, cfg)
+	if err == nil && cfg.emitSchema {
+		_, err = writeSchemaFile(inferSchema(docs, combinedName), combinedName, cfg)
+	}
+	return filePath, parts, err
+}
+
+// writeCombined writes the --single-file CSV and points the result of every
+// collection in it at the file. If the write fails, those collections are
+// marked as failed.
+func writeCombined(results []exportResult, cfg exportConfig) {
+	var docs, collections int
+	for _, r := range results {
+		if r.err == nil && r.docCount > 0 {
+			docs += r.docCount
+			collections++
+		}
+	}
+	if collections == 0 {
+		printInfo("No documents to write to the combined file")
+		return
+	}
+
+	filePath, parts, err := cfg.combined.write(results, cfg)
+	for i := range results {
+		if r := &results[i]; r.err == nil && r.docCount > 0 {
+			if err != nil {
+				r.err = err
+			} else {
+				r.filePath, r.parts = filePath, parts
+			}
+		}
+	}
+	if err != nil {
+		printErr("Failed to write the combined file: %v", err)
+		return
+	}
+	printOK("Wrote %s docs from %d collection(s) → %s", fmtInt(docs), collections, describeOutput(filePath, parts, 0))
+}
+
+// collectionPath returns the path of the collection holding the document at
+// docPath, as written to the __collection__ column.
+func collectionPath(docPath string) string {
+	return path.Dir(docPath)
+}
+
+// validateSingleFile rejects options that --single-file can't honor.
+func validateSingleFile(cfg exportConfig) error {
+	switch {
+	case cfg.format != "csv" && cfg.format != "tsv":
+		return fmt.Errorf("--single-file only supports CSV and TSV output")
+	case cfg.group != "":
+		return fmt.Errorf("--single-file can't be combined with --collection-group, which already writes one file")
+	case cfg.stream:
+		return fmt.Errorf("--single-file can't be combined with --stream; the header needs every collection's fields")
+	case cfg.resume:
+		return fmt.Errorf("--single-file can't be combined with --resume")
+	}
+	return nil
+}
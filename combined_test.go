@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCombinedOutput_Write(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := exportConfig{output: tmpDir, format: "csv", singleFile: true, emitSchema: true}
+	c := newCombinedOutput()
+	// Added out of order, as with --concurrency; the file follows results.
+	c.add("users/u1/orders", []docRecord{
+		{path: "users/u1/orders/o1", data: map[string]any{"total": 9.5, "age": "new"}},
+	}, map[string]struct{}{"total": {}, "age": {}})
+	c.add("users", []docRecord{
+		{path: "users/u1", data: map[string]any{"name": "Alice", "age": int64(30)}},
+	}, map[string]struct{}{"name": {}, "age": {}})
+	results := []exportResult{
+		{collection: "users", docCount: 1},
+		{collection: "users/u1/orders", docCount: 1},
+	}
+
+	filePath, _, err := c.write(results, cfg)
+	if err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if filePath != filepath.Join(tmpDir, "export.csv") {
+		t.Errorf("filePath = %q, want export.csv under output dir", filePath)
+	}
+
+	want := [][]string{
+		{"__path__", "__collection__", "age", "name", "total"},
+		{"users/u1", "users", "30", "Alice", ""},
+		{"users/u1/orders/o1", "users/u1/orders", "new", "", "9.5"},
+	}
+	if got := readCSV(t, filePath); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	b, err := os.ReadFile(filepath.Join(tmpDir, "export.schema.json"))
+	if err != nil {
+		t.Fatalf("reading schema: %v", err)
+	}
+	if !strings.Contains(string(b), `"conflict": true`) {
+		t.Errorf("schema = %s, want age flagged as a conflict", b)
+	}
+}
+
+func TestWriteCombined(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := exportConfig{output: tmpDir, format: "csv", singleFile: true, combined: newCombinedOutput()}
+	cfg.combined.add("users", []docRecord{{path: "users/u1", data: map[string]any{"a": int64(1)}}}, map[string]struct{}{"a": {}})
+	results := []exportResult{
+		{collection: "users", docCount: 1},
+		{collection: "empty"},
+	}
+
+	writeCombined(results, cfg)
+	if want := filepath.Join(tmpDir, "export.csv"); results[0].filePath != want {
+		t.Errorf("results[0].filePath = %q, want %q", results[0].filePath, want)
+	}
+	if results[1].filePath != "" {
+		t.Errorf("results[1].filePath = %q, want empty for a collection with no documents", results[1].filePath)
+	}
+}
+
+func TestValidateSingleFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"valid", exportConfig{format: "csv"}, ""},
+		{"tsv", exportConfig{format: "tsv"}, ""},
+		{"jsonl", exportConfig{format: "jsonl"}, "CSV"},
+		{"collection group", exportConfig{format: "csv", group: "orders"}, "--collection-group"},
+		{"stream", exportConfig{format: "csv", stream: true}, "--stream"},
+		{"resume", exportConfig{format: "csv", resume: true}, "--resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSingleFile(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSingleFile() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSingleFile() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// completionShells are the shells `completion` writes scripts for.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completionTimeout bounds the Firestore lookup behind --collections
+// completion, so a slow connection doesn't hang the shell.
+const completionTimeout = 5 * time.Second
+
+// writeCompletion writes the completion script of rootCmd for shell to w.
+func writeCompletion(rootCmd *cobra.Command, shell string, w io.Writer) error {
+	switch shell {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(w, true)
+	case "zsh":
+		return rootCmd.GenZshCompletion(w)
+	case "fish":
+		return rootCmd.GenFishCompletion(w, true)
+	case "powershell":
+		return rootCmd.GenPowerShellCompletionWithDesc(w)
+	}
+	return fmt.Errorf("unsupported shell %q: must be one of %s", shell, strings.Join(completionShells, ", "))
+}
+
+// completeCollections completes the --collections flag with the top-level
+// collections of the database. It only connects once --project or --emulator
+// is on the command line, so pressing tab never reaches for a project the user
+// didn't name.
+func completeCollections(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	f := cmd.Flags()
+	if !f.Changed("project") && !f.Changed("emulator") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	project, database, emulator, err := validateConnectionFlags(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	// Completion is for export and count, which both take one database.
+	if databases := splitList(database); len(databases) > 0 {
+		database = databases[0]
+	}
+	credentials, err := credentialsFromFlags(cmd)
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveError
+	}
+	endpoint, err := endpointFromFlags(cmd, emulator, credentials)
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	client, err := newFirestoreClient(ctx, project, database, emulator, credentials, endpoint)
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveError
+	}
+	defer client.Close()
+	names, err := listCollections(ctx, client)
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return collectionCompletions(names, toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// collectionCompletions returns the candidates for toComplete, the
+// comma-separated --collections value typed so far: each collection in names
+// that starts with its last entry and isn't listed already, appended to the
+// entries before it.
+func collectionCompletions(names []string, toComplete string) []string {
+	done, partial := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		done, partial = toComplete[:i+1], toComplete[i+1:]
+	}
+	listed := splitList(done)
+	var out []string
+	for _, name := range names {
+		if strings.HasPrefix(name, partial) && !slices.Contains(listed, name) {
+			out = append(out, done+name)
+		}
+	}
+	return out
+}
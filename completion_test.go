@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCollectionCompletions(t *testing.T) {
+	names := []string{"orders", "users", "userEvents"}
+	tests := []struct {
+		toComplete string
+		want       []string
+	}{
+		{"", []string{"orders", "users", "userEvents"}},
+		{"us", []string{"users", "userEvents"}},
+		{"orders,", []string{"orders,users", "orders,userEvents"}},
+		{"orders,users,user", []string{"orders,users,userEvents"}},
+		{"x", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.toComplete, func(t *testing.T) {
+			got := collectionCompletions(names, tt.toComplete)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("collectionCompletions(%q) = %q, want %q", tt.toComplete, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	root := &cobra.Command{Use: "firestore2csv"}
+	root.AddCommand(&cobra.Command{Use: "export", Run: func(*cobra.Command, []string) {}})
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeCompletion(root, shell, &buf); err != nil {
+				t.Fatalf("writeCompletion() error = %v", err)
+			}
+			if !strings.Contains(buf.String(), "firestore2csv") {
+				t.Errorf("%s script doesn't mention the command:\n%s", shell, buf.String())
+			}
+		})
+	}
+	if err := writeCompletion(root, "tcsh", &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "unsupported shell") {
+		t.Errorf("writeCompletion(tcsh) error = %v, want unsupported shell", err)
+	}
+}
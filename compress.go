@@ -0,0 +1,71 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionNone is the --compression value that leaves files uncompressed.
+const compressionNone = "none"
+
+// codec is an output compression selected with --compression.
+type codec struct {
+	// ext is appended to the file name, after the format's extension.
+	ext string
+	// newWriter returns a writer that compresses into w. Closing it must
+	// flush the stream and finish it, but not close w.
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// codecs maps --compression values to their codecs. Adding a codec here is
+// all it takes to support it.
+var codecs = map[string]codec{
+	"gzip": {ext: ".gz", newWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	}},
+	"zstd": {ext: ".zst", newWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	}},
+}
+
+// codecNames returns the accepted --compression values, for error messages.
+func codecNames() []string {
+	names := []string{compressionNone}
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// compressedFile compresses everything written to it into the underlying
+// file.
+type compressedFile struct {
+	w io.WriteCloser
+	f io.WriteCloser
+}
+
+func newCompressedFile(f io.WriteCloser, c codec) (*compressedFile, error) {
+	w, err := c.newWriter(f)
+	if err != nil {
+		return nil, err
+	}
+	return &compressedFile{w: w, f: f}, nil
+}
+
+func (c *compressedFile) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// Close finishes the compressed stream, writing its footer, before closing
+// the underlying file; closing the file first would truncate the archive.
+func (c *compressedFile) Close() error {
+	if err := c.w.Close(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
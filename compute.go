@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// computedColumn is a --compute column: an expr-lang expression evaluated
+// against each document, e.g. fullName=firstName + " " + lastName.
+type computedColumn struct {
+	name    string
+	source  string
+	program *vm.Program
+}
+
+// parseCompute parses the --compute values, each name=expression. The name
+// ends at the first "=", so the expression may contain comparisons such as
+// isAdult=age >= 18. Expressions are compiled here, so a syntax error fails
+// the export before anything is read.
+func parseCompute(values []string) ([]computedColumn, error) {
+	cols := make([]computedColumn, 0, len(values))
+	for _, v := range values {
+		name, source, ok := strings.Cut(v, "=")
+		name, source = strings.TrimSpace(name), strings.TrimSpace(source)
+		if !ok || name == "" || source == "" {
+			return nil, fmt.Errorf("%q must be name=expression", v)
+		}
+		if slices.ContainsFunc(cols, func(c computedColumn) bool { return c.name == name }) {
+			return nil, fmt.Errorf("column %q is computed more than once", name)
+		}
+		// Fields are only known per document, so identifiers are resolved
+		// when the expression runs; a field the document lacks is nil.
+		program, err := expr.Compile(source, expr.AllowUndefinedVariables())
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", name, err)
+		}
+		cols = append(cols, computedColumn{name: name, source: source, program: program})
+	}
+	return cols, nil
+}
+
+// computeColumns sets each --compute column of out to its expression
+// evaluated against data, the document's fields before --flatten and
+// --rename. A computed column replaces a field of the same name. An
+// expression that fails for a document leaves its cell empty, with a warning
+// the first time each column fails.
+func computeColumns(out, data map[string]any, cfg exportConfig) {
+	for _, c := range cfg.compute {
+		v, err := expr.Run(c.program, data)
+		if err != nil {
+			if _, warned := computeFailures.LoadOrStore(c.name, true); !warned {
+				// expr errors end with the expression, marking where it failed.
+				msg, _, _ := strings.Cut(err.Error(), "\n")
+				printWarn("--compute %s failed (%s); its cell is left empty, and further failures aren't reported", c.name, msg)
+			}
+			v = nil
+		}
+		out[c.name] = v
+	}
+}
+
+// computeFailures records the --compute columns whose failure has been
+// reported, so a field missing from many documents warns once.
+var computeFailures sync.Map
+
+// computedHeader returns header with the --compute columns moved to its end,
+// in the order they were given.
+func computedHeader(header []string, cfg exportConfig) []string {
+	if len(cfg.compute) == 0 {
+		return header
+	}
+	fields := make([]string, 0, len(header)+len(cfg.compute))
+	for _, f := range header {
+		if !slices.ContainsFunc(cfg.compute, func(c computedColumn) bool { return c.name == f }) {
+			fields = append(fields, f)
+		}
+	}
+	for _, c := range cfg.compute {
+		fields = append(fields, c.name)
+	}
+	return fields
+}
+
+// validateCompute rejects --compute columns that would replace a column the
+// writer adds.
+func validateCompute(cfg exportConfig) error {
+	reserved := reservedColumns(cfg)
+	for _, c := range cfg.compute {
+		if slices.Contains(reserved, c.name) || (cfg.pathColumns && isParentColumn(c.name)) {
+			return fmt.Errorf("--compute can't write the %s column, which is always written first", c.name)
+		}
+	}
+	return nil
+}
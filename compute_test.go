@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mustCompute parses --compute values for a test config.
+func mustCompute(t *testing.T, values ...string) []computedColumn {
+	t.Helper()
+	cols, err := parseCompute(values)
+	if err != nil {
+		t.Fatalf("parseCompute(%q) error = %v", values, err)
+	}
+	return cols
+}
+
+func TestParseCompute(t *testing.T) {
+	cols := mustCompute(t, `fullName = firstName + " " + lastName`, "isAdult=age >= 18")
+	var names, sources []string
+	for _, c := range cols {
+		names = append(names, c.name)
+		sources = append(sources, c.source)
+	}
+	if want := []string{"fullName", "isAdult"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	if want := []string{`firstName + " " + lastName`, "age >= 18"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("sources = %v, want %v", sources, want)
+	}
+
+	invalid := []struct {
+		values  []string
+		wantErr string
+	}{
+		{[]string{"firstName"}, "must be name=expression"},
+		{[]string{"=age + 1"}, "must be name=expression"},
+		{[]string{"next="}, "must be name=expression"},
+		{[]string{"next=age +"}, `column "next"`},
+		{[]string{"next=age + 1", "next=1"}, "computed more than once"},
+	}
+	for _, tt := range invalid {
+		if _, err := parseCompute(tt.values); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseCompute(%q) error = %v, want containing %q", tt.values, err, tt.wantErr)
+		}
+	}
+}
+
+func TestShapeRecord_Compute(t *testing.T) {
+	cfg := exportConfig{
+		flatten: true,
+		compute: mustCompute(t, `fullName=firstName + " " + lastName`, "city=address.city", "age=age + 1"),
+	}
+	data := map[string]any{"firstName": "Ada", "lastName": "Lovelace", "age": 36, "address": map[string]any{"city": "London"}}
+	got, err := shapeRecord(data, cfg)
+	if err != nil {
+		t.Fatalf("shapeRecord() error = %v", err)
+	}
+	want := map[string]any{
+		"firstName": "Ada", "lastName": "Lovelace", "address.city": "London",
+		"fullName": "Ada Lovelace", "city": "London", "age": 37,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord() = %v, want %v", got, want)
+	}
+	if data["age"] != 36 {
+		t.Errorf("shapeRecord() modified its input: age = %v", data["age"])
+	}
+}
+
+func TestShapeRecord_ComputeFailure(t *testing.T) {
+	cfg := exportConfig{compute: mustCompute(t, `fullName=firstName + " " + lastName`)}
+	got, err := shapeRecord(map[string]any{"firstName": "Ada"}, cfg)
+	if err != nil {
+		t.Fatalf("shapeRecord() error = %v", err)
+	}
+	if v, ok := got["fullName"]; !ok || v != nil {
+		t.Errorf("fullName = %v (present %v), want an empty cell", v, ok)
+	}
+}
+
+func TestShapeRecord_ComputeMasked(t *testing.T) {
+	cfg := exportConfig{
+		redactFields: map[string]bool{"ssn": true},
+		compute:      mustCompute(t, "copy=ssn"),
+	}
+	got, err := shapeRecord(map[string]any{"ssn": "123-45-6789"}, cfg)
+	if err != nil {
+		t.Fatalf("shapeRecord() error = %v", err)
+	}
+	if got["copy"] != redactedValue {
+		t.Errorf("copy = %v, want the redacted value", got["copy"])
+	}
+}
+
+func TestHeaderFields_Compute(t *testing.T) {
+	cfg := exportConfig{compute: mustCompute(t, "total=price * qty", "age=age + 1")}
+	fieldSet := map[string]struct{}{"price": {}, "qty": {}, "age": {}, "total": {}}
+	if got, want := headerFields(fieldSet, cfg), []string{"price", "qty", "total", "age"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("headerFields() = %v, want %v", got, want)
+	}
+	cfg.fields = []string{"qty", "price"}
+	if got, want := headerFields(fieldSet, cfg), []string{"qty", "price", "total", "age"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("headerFields(--fields) = %v, want %v", got, want)
+	}
+}
+
+func TestWriteCollectionCSV_Compute(t *testing.T) {
+	cfg := exportConfig{output: t.TempDir(), format: "csv", compute: mustCompute(t, "total=price * qty")}
+	docs := []docRecord{
+		{path: "orders/a", data: map[string]any{"price": 2.5, "qty": 4}},
+		{path: "orders/b", data: map[string]any{"price": 3, "qty": 2}},
+	}
+	fieldSet := map[string]struct{}{}
+	for i, doc := range docs {
+		rec, err := shapeRecord(doc.data, cfg)
+		if err != nil {
+			t.Fatalf("shapeRecord() error = %v", err)
+		}
+		for k := range rec {
+			fieldSet[k] = struct{}{}
+		}
+		docs[i].data = rec
+	}
+
+	filePath, err := writeCollection(docs, fieldSet, "orders", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "__path__,price,qty,total\norders/a,2.5,4,10\norders/b,3,2,6\n"
+	if string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestValidateCompute(t *testing.T) {
+	base := exportConfig{compute: mustCompute(t, "total=price * qty")}
+	if err := validateCompute(base); err != nil {
+		t.Errorf("validateCompute() error = %v", err)
+	}
+	invalid := map[string]func(*exportConfig){
+		"__path__": func(c *exportConfig) { c.compute = mustCompute(t, "__path__=name") },
+		"__update_time__": func(c *exportConfig) {
+			c.includeTimestamps = true
+			c.compute = mustCompute(t, "__update_time__=name")
+		},
+		"__parent_id__": func(c *exportConfig) {
+			c.pathColumns = true
+			c.compute = mustCompute(t, "__parent_id__=name")
+		},
+	}
+	for name, mutate := range invalid {
+		cfg := base
+		mutate(&cfg)
+		if err := validateCompute(cfg); err == nil {
+			t.Errorf("validateCompute(%s) error = nil, want error", name)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigFile reads the --config file at path and sets the flags of cmd it
+// names. Top-level keys are flag names (without dashes) for every command; a
+// key named after a command, such as export, holds flags for that command only
+// and wins over the top-level ones:
+//
+//	project: my-project
+//	export:
+//	  collections: [users, orders]
+//	  format: jsonl
+//
+// Flags given on the command line keep their values.
+func loadConfigFile(cmd *cobra.Command, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	if err := applyConfig(cmd, raw); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyConfig sets the flags of cmd from raw, a parsed config file, skipping
+// flags that are already set.
+func applyConfig(cmd *cobra.Command, raw map[string]any) error {
+	root := cmd.Root()
+	sections := make(map[string]bool)
+	for _, c := range root.Commands() {
+		sections[c.Name()] = true
+	}
+	values := make(map[string]any)
+	for key, v := range raw {
+		if !sections[key] {
+			values[key] = v
+		}
+	}
+	if section, ok := raw[cmd.Name()]; ok && cmd != root {
+		m, ok := section.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%q must hold a mapping of flags to values", cmd.Name())
+		}
+		for key := range m {
+			if cmd.Flags().Lookup(key) == nil {
+				return fmt.Errorf("unknown option %q for %s", key, cmd.Name())
+			}
+		}
+		maps.Copy(values, m)
+	}
+
+	f := cmd.Flags()
+	for _, key := range sortedKeys(values) {
+		flag := f.Lookup(key)
+		if flag == nil {
+			// Shared top-level keys may belong to other commands only.
+			if !commandsHaveFlag(root, key) {
+				return fmt.Errorf("unknown option %q", key)
+			}
+			continue
+		}
+		if flag.Name == "config" {
+			return fmt.Errorf("a config file can't name another with %q", key)
+		}
+		if flag.Changed {
+			continue
+		}
+		s, err := configValueString(values[key])
+		if err != nil {
+			return fmt.Errorf("option %q: %w", key, err)
+		}
+		if err := f.Set(flag.Name, s); err != nil {
+			return fmt.Errorf("option %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// commandsHaveFlag reports whether root or any of its sub-commands has a flag
+// called name.
+func commandsHaveFlag(root *cobra.Command, name string) bool {
+	if root.Flags().Lookup(name) != nil || root.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, c := range root.Commands() {
+		if commandsHaveFlag(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// configValueString converts a config file value to the string the flag would
+// get on the command line. Lists become comma-separated, as the list flags
+// expect.
+func configValueString(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", fmt.Errorf("no value")
+	case map[string]any:
+		return "", fmt.Errorf("expected a value or a list, got a mapping")
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			if _, nested := item.([]any); nested {
+				return "", fmt.Errorf("expected a value or a list, got nested lists")
+			}
+			s, err := configValueString(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return fmt.Sprint(v), nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// runWithConfig runs a small command tree like firestore2csv's with the given
+// config file contents and arguments, returning the flags export saw.
+func runWithConfig(t *testing.T, config string, args ...string) (map[string]string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "firestore2csv.yaml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	got := make(map[string]string)
+	root := &cobra.Command{Use: "firestore2csv", SilenceUsage: true, SilenceErrors: true}
+	root.PersistentFlags().StringP("project", "p", "", "")
+	root.PersistentFlags().String("credentials", "", "")
+	root.PersistentFlags().CountP("verbose", "v", "")
+	root.PersistentFlags().String("config", "", "")
+	root.SetGlobalNormalizationFunc(normalizeFlagName)
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return loadConfigFile(cmd, path)
+	}
+	exportCmd := &cobra.Command{Use: "export", RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.Flags().VisitAll(func(f *pflag.Flag) { got[f.Name] = f.Value.String() })
+		return nil
+	}}
+	exportCmd.Flags().StringP("collections", "c", "", "")
+	exportCmd.Flags().String("format", "csv", "")
+	exportCmd.Flags().Int("limit", 0, "")
+	exportCmd.Flags().Bool("stream", false, "")
+	importCmd := &cobra.Command{Use: "import", RunE: func(*cobra.Command, []string) error { return nil }}
+	importCmd.Flags().String("input", ".", "")
+	root.AddCommand(exportCmd, importCmd)
+
+	root.SetArgs(append([]string{"export"}, args...))
+	return got, root.Execute()
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		want   map[string]string
+	}{
+		{
+			name:   "top-level keys",
+			config: "project: my-project\ncollections: [users, orders]\nlimit: 100\nstream: true\nverbose: 2\n",
+			want:   map[string]string{"project": "my-project", "collections": "users,orders", "limit": "100", "stream": "true", "verbose": "2"},
+		},
+		{
+			name:   "command line wins",
+			config: "project: my-project\nformat: jsonl\n",
+			args:   []string{"-p", "other", "--format", "tsv"},
+			want:   map[string]string{"project": "other", "format": "tsv"},
+		},
+		{
+			name:   "command section wins over top level",
+			config: "format: jsonl\nexport:\n  format: parquet\nimport:\n  input: in\n",
+			want:   map[string]string{"format": "parquet"},
+		},
+		{
+			name:   "flag of another command",
+			config: "input: backups\n",
+			want:   map[string]string{"format": "csv"},
+		},
+		{
+			name:   "alias",
+			config: "key-file: key.json\n",
+			want:   map[string]string{"credentials": "key.json"},
+		},
+		{
+			name:   "empty file",
+			config: "",
+			want:   map[string]string{"format": "csv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runWithConfig(t, tt.config, tt.args...)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("--%s = %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"unknown key", "colections: users\n", `unknown option "colections"`},
+		{"unknown key in section", "export:\n  input: in\n", `unknown option "input" for export`},
+		{"section not a mapping", "export: users\n", "mapping of flags"},
+		{"bad value", "limit: many\n", `option "limit"`},
+		{"mapping value", "collections:\n  users: 1\n", "got a mapping"},
+		{"no value", "collections:\n", "no value"},
+		{"nested config", "config: other.yaml\n", "another"},
+		{"not yaml", "project: [\n", "parsing config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runWithConfig(t, tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Execute() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRootCmd_SanitizeConfigFlag(t *testing.T) {
+	// sanitize's own --config shadows the root one: it holds the fields to
+	// sanitize, not a file of flag values.
+	dir := t.TempDir()
+	input := filepath.Join(dir, "users.csv")
+	if err := os.WriteFile(input, []byte("__path__,email\nusers/a,a@example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out")
+	root := newRootCmd()
+	root.SetArgs([]string{"sanitize", "--config", "email=email", "-i", input, "-o", output, "--seed", "1"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("sanitize --config email=email: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(output, "users.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "a@example.com") {
+		t.Errorf("email wasn't sanitized:\n%s", b)
+	}
+
+	// Other commands still read the root --config as a flags file.
+	root = newRootCmd()
+	root.SetArgs([]string{"count", "--config", filepath.Join(dir, "missing.yaml")})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "reading config file") {
+		t.Errorf("count --config missing.yaml error = %v, want a config file error", err)
+	}
+}
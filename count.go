@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/spf13/cobra"
+)
+
+// countConfig holds the settings of the count subcommand.
+type countConfig struct {
+	project     string
+	database    string
+	emulator    string
+	credentials string
+	endpoint    endpointOptions
+	collections string
+	collFile    string
+	exclude     []string
+}
+
+// countResult is the document count of one top-level collection.
+type countResult struct {
+	collection string
+	count      int64
+	err        error
+}
+
+// runCountCmd is the cobra RunE handler for the count subcommand.
+func runCountCmd(cmd *cobra.Command, args []string) error {
+	project, database, emulator, err := validateConnectionFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if err := requireSingleDatabase(database, "count"); err != nil {
+		return err
+	}
+	credentials, err := credentialsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	endpoint, err := endpointFromFlags(cmd, emulator, credentials)
+	if err != nil {
+		return err
+	}
+
+	f := cmd.Flags()
+	collections, _ := f.GetString("collections")
+	collectionsFile, _ := f.GetString("collections-file")
+	excludeFlag, _ := f.GetString("exclude")
+	if collections != "" && collectionsFile != "" {
+		return fmt.Errorf("--collections and --collections-file can't be combined")
+	}
+
+	return runCount(countConfig{
+		project:     project,
+		database:    database,
+		emulator:    emulator,
+		credentials: credentials,
+		endpoint:    endpoint,
+		collections: collections,
+		collFile:    collectionsFile,
+		exclude:     splitList(excludeFlag),
+	})
+}
+
+// runCount prints the number of documents in each top-level collection. The
+// counts come from aggregation queries, so no documents are read.
+func runCount(cfg countConfig) error {
+	printText("\n")
+	displayProject := describeConnection(cfg.project, cfg.emulator, cfg.endpoint)
+	printInfo("Connecting to %s (database: %s)", bold(displayProject), bold(cfg.database))
+
+	ctx := context.Background()
+	client, err := newFirestoreClient(ctx, cfg.project, cfg.database, cfg.emulator, cfg.credentials, cfg.endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	defer client.Close()
+
+	names, err := resolveCollections(ctx, client, cfg.collections, cfg.collFile, cfg.exclude)
+	if err != nil {
+		return fmt.Errorf("failed to resolve collections: %w", err)
+	}
+	printInfo("Found %d collection(s): %s", len(names), strings.Join(names, ", "))
+	printText("\n")
+
+	results := make([]countResult, 0, len(names))
+	var failed []string
+	for _, name := range names {
+		n, err := countDocuments(ctx, client.Collection(name).Query)
+		if err != nil {
+			printErrFor(name, "Failed to count %q: %v", name, err)
+			failed = append(failed, name)
+		} else {
+			printOKFor(name, "Counted %q — %s docs", name, fmtInt(int(n)))
+		}
+		results = append(results, countResult{collection: name, count: n, err: err})
+	}
+
+	printCountTable(results)
+
+	if len(failed) > 0 {
+		printDone(false, "Count completed with %d error(s). Failed: %s",
+			len(failed), strings.Join(failed, ", "))
+		err := fmt.Errorf("count failed for %d collection(s)", len(failed))
+		if len(failed) < len(results) {
+			return &partialError{err}
+		}
+		return err
+	}
+	printDone(true, "Counted %d collection(s).", len(results))
+	return nil
+}
+
+// countDocuments returns the number of documents matching query, using a
+// count aggregation evaluated by Firestore.
+func countDocuments(ctx context.Context, query firestore.Query) (int64, error) {
+	res, err := query.NewAggregationQuery().WithCount("all").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	v, ok := res["all"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("unexpected count result %T", res["all"])
+	}
+	return v.GetIntegerValue(), nil
+}
+
+// printCountTable prints the counts as a table like printSummaryTable's, with
+// just the Collection and Docs columns.
+func printCountTable(results []countResult) {
+	if len(results) == 0 || logJSON {
+		return
+	}
+
+	colW, docW := len("Collection"), len("Docs")
+	rows := make([][]string, len(results))
+	for i, r := range results {
+		docs := fmtInt(int(r.count))
+		if r.err != nil {
+			docs = "-"
+		}
+		rows[i] = []string{r.collection, docs}
+		colW = max(colW, len(r.collection))
+		docW = max(docW, len(docs))
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, " %-*s  %*s\n", colW, bold("Collection"), docW, bold("Docs"))
+	fmt.Fprintf(os.Stderr, " %s  %s\n", faint(strings.Repeat("─", colW)), faint(strings.Repeat("─", docW)))
+	for _, row := range rows {
+		fmt.Fprintf(os.Stderr, " %-*s  %*s\n", colW, row[0], docW, row[1])
+	}
+}
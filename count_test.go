@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrintCountTable(t *testing.T) {
+	out := captureStderr(t, func() {
+		printCountTable([]countResult{
+			{collection: "users", count: 1234},
+			{collection: "audit_logs", err: errors.New("permission denied")},
+		})
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want header, separator and 2 rows: %q", len(lines), out)
+	}
+	if !strings.Contains(lines[0], "Collection") || !strings.Contains(lines[0], "Docs") {
+		t.Errorf("header = %q", lines[0])
+	}
+	if fields := strings.Fields(lines[2]); len(fields) != 2 || fields[0] != "users" || fields[1] != "1,234" {
+		t.Errorf("row = %q, want users and 1,234", lines[2])
+	}
+	if fields := strings.Fields(lines[3]); len(fields) != 2 || fields[1] != "-" {
+		t.Errorf("failed row = %q, want - for the count", lines[3])
+	}
+}
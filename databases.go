@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseDatabases splits the --database value of export, which may list
+// several databases separated by commas. Each is exported in turn, and their
+// output files are told apart by databaseFilePrefix.
+func parseDatabases(raw string) ([]string, error) {
+	dbs := splitList(raw)
+	if len(dbs) == 0 {
+		return nil, fmt.Errorf("invalid --database %q: no database named", raw)
+	}
+	// Two names with one prefix would write to the same files.
+	seen := make(map[string]string)
+	for _, db := range dbs {
+		prefix := databaseFilePrefix(db)
+		if other, ok := seen[prefix]; ok {
+			return nil, fmt.Errorf("invalid --database %q: %q and %q would share output files", raw, other, db)
+		}
+		seen[prefix] = db
+	}
+	return dbs, nil
+}
+
+// databaseFilePrefix returns the prefix of output file names for database db
+// when several databases are exported. The parentheses of "(default)" are
+// dropped, so those files start with "default_". Database IDs can't contain
+// underscores, so the prefix can't be confused with the collection name.
+func databaseFilePrefix(db string) string {
+	return strings.Trim(db, "()") + "_"
+}
+
+// requireSingleDatabase rejects a --database list for commands that work on
+// one database.
+func requireSingleDatabase(database, command string) error {
+	if strings.Contains(database, ",") {
+		return fmt.Errorf("--database takes a single database for %s, got %q", command, database)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDatabases(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []string
+		wantErr string
+	}{
+		{"(default)", []string{"(default)"}, ""},
+		{"(default), tenant-a,tenant-b", []string{"(default)", "tenant-a", "tenant-b"}, ""},
+		{" , ", nil, "no database"},
+		{"tenant-a,tenant-a", nil, "share output files"},
+		{"(default),default", nil, "share output files"},
+	}
+	for _, tt := range tests {
+		got, err := parseDatabases(tt.raw)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseDatabases(%q) error = %v, want containing %q", tt.raw, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDatabases(%q) error = %v", tt.raw, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseDatabases(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestDatabaseFilePrefix(t *testing.T) {
+	for db, want := range map[string]string{"(default)": "default_", "tenant-a": "tenant-a_"} {
+		if got := databaseFilePrefix(db); got != want {
+			t.Errorf("databaseFilePrefix(%q) = %q, want %q", db, got, want)
+		}
+	}
+}
+
+func TestRequireSingleDatabase(t *testing.T) {
+	if err := requireSingleDatabase("(default)", "import"); err != nil {
+		t.Errorf("requireSingleDatabase() error = %v", err)
+	}
+	if err := requireSingleDatabase("a,b", "import"); err == nil || !strings.Contains(err.Error(), "import") {
+		t.Errorf("requireSingleDatabase(list) error = %v, want one naming import", err)
+	}
+}
+
+func TestRunExport_DatabaseFailureKeepsResults(t *testing.T) {
+	// newFirestoreClient sets the variable; t.Setenv restores it afterwards.
+	t.Setenv(emulatorHostEnv, "localhost:1")
+	dir := t.TempDir()
+	errorsPath := filepath.Join(dir, "errors.json")
+	err := runExport(exportConfig{
+		databases:  []string{"tenant-a", "tenant-b"},
+		emulator:   "localhost:1",
+		collFile:   filepath.Join(dir, "missing.txt"),
+		output:     dir,
+		summary:    "none",
+		errorsFile: errorsPath,
+	})
+	if err == nil {
+		t.Fatal("runExport() error = nil, want error")
+	}
+	b, err := os.ReadFile(errorsPath)
+	if err != nil {
+		t.Fatalf("errors file not written: %v", err)
+	}
+	var got []collectionError
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("parsing errors file: %v", err)
+	}
+	if len(got) != 2 || got[0].Database != "tenant-a" || got[1].Database != "tenant-b" {
+		t.Errorf("errors file = %+v, want a failure for each database", got)
+	}
+}
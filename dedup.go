@@ -0,0 +1,63 @@
+package main
+
+import "fmt"
+
+// Values accepted by --dedup-keep.
+const (
+	dedupKeepFirst = "first"
+	dedupKeepLast  = "last"
+)
+
+// dedupDocs drops documents whose field value was already seen in another
+// document, keeping the first or last one in read order (which --order-by
+// controls) for each value. Documents without the field, or with it set to
+// null, are always kept. The result keeps read order; dropped is the number
+// of documents removed.
+func dedupDocs(docs []docRecord, field, keep string) (kept []docRecord, dropped int) {
+	// winner maps each value to the index of the document kept for it.
+	winner := make(map[string]int)
+	for i, doc := range docs {
+		key, ok := dedupKey(doc.data, field)
+		if !ok {
+			continue
+		}
+		if _, seen := winner[key]; !seen || keep == dedupKeepLast {
+			winner[key] = i
+		}
+	}
+
+	kept = make([]docRecord, 0, len(winner))
+	for i, doc := range docs {
+		if key, ok := dedupKey(doc.data, field); ok && winner[key] != i {
+			dropped++
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	return kept, dropped
+}
+
+// dedupKey returns the value of field in data as a map key. The type is part
+// of the key, so the integer 1 and the string "1" are different values.
+func dedupKey(data map[string]any, field string) (string, bool) {
+	v, ok := data[field]
+	if !ok || v == nil {
+		return "", false
+	}
+	return typeLabel(v) + ":" + formatValue(v), true
+}
+
+// validateDedup checks the --dedup-by and --dedup-keep settings.
+func validateDedup(cfg exportConfig) error {
+	if cfg.dedupKeep != dedupKeepFirst && cfg.dedupKeep != dedupKeepLast {
+		return fmt.Errorf("invalid --dedup-keep value %q: must be one of first, last", cfg.dedupKeep)
+	}
+	switch {
+	case cfg.dedupBy == "":
+	case cfg.stream:
+		return fmt.Errorf("--dedup-by needs the whole collection in memory; it can't be combined with --stream")
+	case cfg.resume:
+		return fmt.Errorf("--dedup-by can't be combined with --resume")
+	}
+	return nil
+}
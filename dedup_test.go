@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDedupDocs(t *testing.T) {
+	docs := []docRecord{
+		{path: "users/a", data: map[string]any{"email": "x@example.com"}},
+		{path: "users/b", data: map[string]any{"email": "y@example.com"}},
+		{path: "users/c", data: map[string]any{"email": "x@example.com"}},
+		{path: "users/d", data: map[string]any{"name": "no email"}},
+		{path: "users/e", data: map[string]any{"email": nil}},
+		{path: "users/f", data: map[string]any{"email": nil}},
+		{path: "users/g", data: map[string]any{"email": "x@example.com"}},
+	}
+	tests := []struct {
+		keep string
+		want []string
+	}{
+		{dedupKeepFirst, []string{"users/a", "users/b", "users/d", "users/e", "users/f"}},
+		{dedupKeepLast, []string{"users/b", "users/d", "users/e", "users/f", "users/g"}},
+	}
+	for _, tt := range tests {
+		kept, dropped := dedupDocs(docs, "email", tt.keep)
+		var got []string
+		for _, doc := range kept {
+			got = append(got, doc.path)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("dedupDocs(keep %s) kept %v, want %v", tt.keep, got, tt.want)
+		}
+		if dropped != 2 {
+			t.Errorf("dedupDocs(keep %s) dropped = %d, want 2", tt.keep, dropped)
+		}
+	}
+}
+
+func TestDedupDocs_TypedValues(t *testing.T) {
+	docs := []docRecord{
+		{path: "items/a", data: map[string]any{"sku": int64(1)}},
+		{path: "items/b", data: map[string]any{"sku": "1"}},
+	}
+	if kept, dropped := dedupDocs(docs, "sku", dedupKeepFirst); len(kept) != 2 || dropped != 0 {
+		t.Errorf("dedupDocs() kept %d, dropped %d; want the integer 1 and string \"1\" kept apart", len(kept), dropped)
+	}
+}
+
+func TestValidateDedup(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"unset", exportConfig{dedupKeep: dedupKeepLast}, ""},
+		{"valid", exportConfig{dedupBy: "email", dedupKeep: dedupKeepFirst}, ""},
+		{"bad keep", exportConfig{dedupKeep: "newest"}, "--dedup-keep"},
+		{"stream", exportConfig{dedupBy: "email", dedupKeep: dedupKeepLast, stream: true}, "--stream"},
+		{"resume", exportConfig{dedupBy: "email", dedupKeep: dedupKeepLast, resume: true}, "--resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDedup(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateDedup() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateDedup() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// documentWriter writes each document to its own JSON file for
+// --split-documents. Files mirror the document paths below the output
+// directory, so users/alice is written to users/alice.json and its orders to
+// users/alice/orders/1001.json; documents of a sub-collection with the same ID
+// under different parents keep separate files. Each file holds the object a
+// JSON Lines export would write for the document.
+type documentWriter struct {
+	cfg exportConfig
+}
+
+func (dw *documentWriter) write(doc docRecord) error {
+	cfg, abort := abortableUploads(dw.cfg)
+	defer abort()
+	f, filePath, err := createNamedFile(documentFileName(doc.path, cfg), ".json", cfg)
+	if err != nil {
+		return err
+	}
+	jw := newJSONLWriter(f, cfg)
+	if err := jw.write(doc); err != nil {
+		abort()
+		jw.close()
+		return err
+	}
+	if err := jw.close(); err != nil {
+		return fmt.Errorf("closing %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// Every document file is closed as soon as it is written.
+func (dw *documentWriter) flush() error { return nil }
+func (dw *documentWriter) close() error { return nil }
+
+// documentFileName returns the slash-separated name of the file for the
+// document at docPath, without extension. Document IDs are passed through
+// sanitizeFileName, collection names only with --sanitize-names, as in
+// outputName; the file prefixes wrap the document's own collection.
+func documentFileName(docPath string, cfg exportConfig) string {
+	segments := strings.Split(docPath, "/")
+	for i, seg := range segments {
+		if i%2 == 1 || cfg.sanitizeNames {
+			segments[i] = sanitizeFileName(seg)
+		}
+	}
+	if last := len(segments) - 2; last >= 0 {
+		segments[last] = cfg.dbPrefix + cfg.filePrefix + segments[last] + cfg.fileSuffix
+	}
+	return strings.Join(segments, "/")
+}
+
+// documentDir returns where the document files of displayPath go, for the
+// progress lines and the summary. Directories named after parent documents
+// are shown as *, e.g. ./output/users/*/orders.
+func documentDir(displayPath string, cfg exportConfig) string {
+	name := strings.ReplaceAll(outputName(displayPath, cfg), "/", "/*/")
+	if cfg.gcs != nil {
+		return gcsScheme + cfg.gcs.bucket + "/" + cfg.gcs.objectName(name)
+	}
+	return filepath.Join(cfg.output, filepath.FromSlash(name))
+}
+
+// documentFiles returns the number of files written for docs documents: one
+// each with --split-documents, none otherwise, where the count isn't shown.
+func documentFiles(docs int, cfg exportConfig) int {
+	if !cfg.splitDocuments {
+		return 0
+	}
+	return docs
+}
+
+// validateSplitDocuments rejects options that --split-documents can't honor.
+func validateSplitDocuments(cfg exportConfig) error {
+	switch {
+	case cfg.output == stdoutOutput:
+		return fmt.Errorf("--split-documents writes a file per document; it can't be combined with --output -")
+	case cfg.group != "":
+		return fmt.Errorf("--split-documents can't be combined with --collection-group")
+	case cfg.singleFile:
+		return fmt.Errorf("--split-documents can't be combined with --single-file")
+	case cfg.maxFileSize > 0:
+		return fmt.Errorf("--split-documents can't be combined with --max-file-size")
+	case cfg.append:
+		return fmt.Errorf("--split-documents can't be combined with --append")
+	case cfg.resume:
+		return fmt.Errorf("--split-documents can't be combined with --resume")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteCollection_SplitDocuments(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "users/a/orders/1", data: map[string]any{"total": int64(5)}},
+		{path: "users/b/orders/1", data: map[string]any{"total": int64(7)}},
+		{path: "users/b/orders/x\\y", data: map[string]any{"total": int64(9)}},
+	}
+	cfg := exportConfig{output: tmpDir, format: "jsonl", splitDocuments: true}
+
+	dir, err := writeCollection(docs, nil, "users/orders", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if want := filepath.Join(tmpDir, "users", "*", "orders"); dir != want {
+		t.Errorf("filePath = %q, want %q", dir, want)
+	}
+	want := map[string]string{
+		"users/a/orders/1.json":   `{"__path__":"users/a/orders/1","total":5}` + "\n",
+		"users/b/orders/1.json":   `{"__path__":"users/b/orders/1","total":7}` + "\n",
+		"users/b/orders/x_y.json": `{"__path__":"users/b/orders/x\\y","total":9}` + "\n",
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(tmpDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("reading %s: %v", name, err)
+			continue
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestWriteCollection_SplitDocumentsGzip(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice"}}}
+	cfg := exportConfig{output: tmpDir, format: "jsonl", splitDocuments: true, compression: "gzip"}
+
+	if _, err := writeCollection(docs, nil, "users", cfg); err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "users", "a.json.gz")); err != nil {
+		t.Errorf("compressed document file: %v", err)
+	}
+}
+
+func TestDocumentFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		docPath string
+		cfg     exportConfig
+		want    string
+	}{
+		{"top-level", "users/alice", exportConfig{}, "users/alice"},
+		{"sub-collection", "users/alice/orders/1", exportConfig{}, "users/alice/orders/1"},
+		{"unsafe ID", "users/a\\b\x01", exportConfig{}, "users/a_b_"},
+		{"unsafe collection kept", "us\\ers/a", exportConfig{}, "us\\ers/a"},
+		{"sanitize names", "us\\ers/a", exportConfig{sanitizeNames: true}, "us_ers/a"},
+		{"prefixes wrap the collection", "users/alice/orders/1", exportConfig{dbPrefix: "db_", filePrefix: "p_", fileSuffix: "_s"}, "users/alice/db_p_orders_s/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := documentFileName(tt.docPath, tt.cfg); got != tt.want {
+				t.Errorf("documentFileName(%q) = %q, want %q", tt.docPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeOutput_Files(t *testing.T) {
+	if got, want := describeOutput("out/users", nil, 1234), "out/users (1,234 file(s))"; got != want {
+		t.Errorf("describeOutput() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateSplitDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"files", exportConfig{output: "out"}, ""},
+		{"stdout", exportConfig{output: stdoutOutput}, "--output -"},
+		{"collection group", exportConfig{group: "orders"}, "--collection-group"},
+		{"single file", exportConfig{singleFile: true}, "--single-file"},
+		{"max file size", exportConfig{maxFileSize: 1 << 20}, "--max-file-size"},
+		{"append", exportConfig{append: true}, "--append"},
+		{"resume", exportConfig{resume: true}, "--resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSplitDocuments(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSplitDocuments() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSplitDocuments() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
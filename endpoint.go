@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/option"
+)
+
+// endpointOptions are the --endpoint and --no-auth settings of the Firestore
+// client, for private access setups such as VPC Service Controls. They don't
+// apply to the Cloud Storage client of gs:// output.
+type endpointOptions struct {
+	endpoint string // host:port of the Firestore API; "" = the default
+	noAuth   bool
+}
+
+// endpointFromFlags returns the validated --endpoint and --no-auth settings.
+// emulator and credentials are the resolved --emulator host and --credentials
+// file, which they can't be combined with.
+func endpointFromFlags(cmd *cobra.Command, emulator, credentials string) (endpointOptions, error) {
+	f := cmd.Flags()
+	endpoint, _ := f.GetString("endpoint")
+	noAuth, _ := f.GetBool("no-auth")
+
+	switch {
+	case endpoint != "" && emulator != "":
+		// The client connects to FIRESTORE_EMULATOR_HOST whatever the endpoint.
+		return endpointOptions{}, fmt.Errorf("--endpoint can't be combined with --emulator (or %s)", emulatorHostEnv)
+	case strings.Contains(endpoint, "://"):
+		return endpointOptions{}, fmt.Errorf("invalid --endpoint %q: must be host:port, without a scheme", endpoint)
+	case noAuth && credentials != "":
+		return endpointOptions{}, fmt.Errorf("--no-auth can't be combined with --credentials")
+	case noAuth && endpoint == "" && emulator == "":
+		return endpointOptions{}, fmt.Errorf("--no-auth needs --endpoint or --emulator; Google's endpoint requires credentials")
+	}
+	return endpointOptions{endpoint: endpoint, noAuth: noAuth}, nil
+}
+
+// clientOptions returns the Firestore client options for e.
+func (e endpointOptions) clientOptions() []option.ClientOption {
+	var opts []option.ClientOption
+	if e.endpoint != "" {
+		opts = append(opts, option.WithEndpoint(e.endpoint))
+	}
+	if e.noAuth {
+		opts = append(opts, option.WithoutAuthentication())
+	}
+	return opts
+}
+
+// describeConnection names what a command connects to in its first log
+// line: the emulator host, or the project and any --endpoint.
+func describeConnection(project, emulator string, e endpointOptions) string {
+	desc := project
+	switch {
+	case emulator != "":
+		desc = fmt.Sprintf("emulator @ %s", emulator)
+	case e.endpoint != "":
+		desc = fmt.Sprintf("%s @ %s", project, e.endpoint)
+	}
+	if e.noAuth {
+		desc += " without authentication"
+	}
+	return desc
+}
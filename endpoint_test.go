@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestEndpointFromFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		emulator    string
+		credentials string
+		want        endpointOptions
+		wantErr     string
+	}{
+		{name: "not set", args: []string{"export", "-p", "p"}},
+		{name: "endpoint", args: []string{"export", "-p", "p", "--endpoint", "firestore.p.vpc:443"}, want: endpointOptions{endpoint: "firestore.p.vpc:443"}},
+		{name: "endpoint without auth", args: []string{"export", "-p", "p", "--endpoint", "firestore.p.vpc:443", "--no-auth"}, want: endpointOptions{endpoint: "firestore.p.vpc:443", noAuth: true}},
+		{name: "emulator without auth", args: []string{"export", "-e", "localhost:8686", "--no-auth"}, emulator: "localhost:8686", want: endpointOptions{noAuth: true}},
+		{name: "endpoint and emulator", args: []string{"export", "-e", "localhost:8686", "--endpoint", "x:443"}, emulator: "localhost:8686", wantErr: "--endpoint can't be combined with --emulator"},
+		{name: "scheme", args: []string{"export", "-p", "p", "--endpoint", "https://x:443"}, wantErr: "without a scheme"},
+		{name: "credentials without auth", args: []string{"export", "-p", "p", "--endpoint", "x:443", "--no-auth"}, credentials: "key.json", wantErr: "--no-auth can't be combined with --credentials"},
+		{name: "no auth to Google", args: []string{"export", "-p", "p", "--no-auth"}, wantErr: "--no-auth needs --endpoint or --emulator"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got endpointOptions
+			var gotErr error
+			cmd := newTestCommand()
+			exportCmd, _, _ := cmd.Find([]string{"export"})
+			exportCmd.RunE = func(cmd *cobra.Command, args []string) error {
+				got, gotErr = endpointFromFlags(cmd, tt.emulator, tt.credentials)
+				return nil
+			}
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" {
+				if gotErr == nil || !strings.Contains(gotErr.Error(), tt.wantErr) {
+					t.Errorf("endpointFromFlags() error = %v, want containing %q", gotErr, tt.wantErr)
+				}
+				return
+			}
+			if gotErr != nil || got != tt.want {
+				t.Errorf("endpointFromFlags() = %+v, %v; want %+v", got, gotErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointOptions_ClientOptions(t *testing.T) {
+	if opts := (endpointOptions{}).clientOptions(); len(opts) != 0 {
+		t.Errorf("clientOptions() = %v, want none by default", opts)
+	}
+	if opts := (endpointOptions{endpoint: "x:443", noAuth: true}).clientOptions(); len(opts) != 2 {
+		t.Errorf("clientOptions() = %v, want an endpoint and no-auth option", opts)
+	}
+}
+
+func TestDescribeConnection(t *testing.T) {
+	tests := []struct {
+		project, emulator string
+		endpoint          endpointOptions
+		want              string
+	}{
+		{"p", "", endpointOptions{}, "p"},
+		{"", "localhost:8686", endpointOptions{}, "emulator @ localhost:8686"},
+		{"p", "", endpointOptions{endpoint: "x:443"}, "p @ x:443"},
+		{"p", "", endpointOptions{endpoint: "x:443", noAuth: true}, "p @ x:443 without authentication"},
+	}
+	for _, tt := range tests {
+		if got := describeConnection(tt.project, tt.emulator, tt.endpoint); got != tt.want {
+			t.Errorf("describeConnection(%q, %q, %+v) = %q, want %q", tt.project, tt.emulator, tt.endpoint, got, tt.want)
+		}
+	}
+}
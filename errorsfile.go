@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// collectionError is the --errors-file record of a collection that failed.
+type collectionError struct {
+	Collection string `json:"collection"`
+	Database   string `json:"database,omitempty"` // Set when several databases are exported
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Partial    bool   `json:"partial,omitempty"` // Its file holds the documents read before the error
+}
+
+// errorCode returns the gRPC status code of err by name, such as
+// "PermissionDenied" or "Unavailable". An export stopped by a signal is
+// "Canceled" and one stopped by --timeout "DeadlineExceeded"; errors that
+// didn't come from Firestore, such as failed writes, are "Unknown".
+func errorCode(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled.String()
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded.String()
+	}
+	return status.Code(err).String()
+}
+
+// collectionErrors returns the records of the failed results, in order. It
+// is never nil, so a run without failures writes an empty array.
+func collectionErrors(results []exportResult) []collectionError {
+	errs := []collectionError{}
+	for _, r := range results {
+		if r.err == nil {
+			continue
+		}
+		errs = append(errs, collectionError{
+			Collection: r.collection,
+			Database:   r.database,
+			Code:       errorCode(r.err),
+			Message:    r.err.Error(),
+			Partial:    r.partial,
+		})
+	}
+	return errs
+}
+
+// writeErrorsFile writes the failed collections of results to path as a JSON
+// array, for scripts that retry them.
+func writeErrorsFile(path string, results []exportResult) error {
+	b, err := json.MarshalIndent(collectionErrors(results), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding errors: %w", err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"gRPC status", status.Error(codes.PermissionDenied, "missing permission"), "PermissionDenied"},
+		{"wrapped status", fmt.Errorf("listing users: %w", status.Error(codes.Unavailable, "try again")), "Unavailable"},
+		{"interrupted", fmt.Errorf("reading: %w", context.Canceled), "Canceled"},
+		{"timed out", fmt.Errorf("reading: %w", context.DeadlineExceeded), "DeadlineExceeded"},
+		{"local", errors.New("creating file out/users.csv: permission denied"), "Unknown"},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.want {
+			t.Errorf("errorCode(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteErrorsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.json")
+	results := []exportResult{
+		{collection: "users", docCount: 3},
+		{collection: "orders", database: "eu", err: status.Error(codes.PermissionDenied, "denied")},
+		{collection: "events", docCount: 2, err: fmt.Errorf("interrupted: %w", context.Canceled), partial: true},
+	}
+	if err := writeErrorsFile(path, results); err != nil {
+		t.Fatalf("writeErrorsFile() error = %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []collectionError
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("errors file is not valid JSON: %v", err)
+	}
+	want := []collectionError{
+		{Collection: "orders", Database: "eu", Code: "PermissionDenied", Message: "rpc error: code = PermissionDenied desc = denied"},
+		{Collection: "events", Code: "Canceled", Message: "interrupted: context canceled", Partial: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors file = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteErrorsFile_NoFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.json")
+	if err := writeErrorsFile(path, []exportResult{{collection: "users"}}); err != nil {
+		t.Fatalf("writeErrorsFile() error = %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "[]\n" {
+		t.Errorf("errors file = %q, want an empty array", b)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import "fmt"
+
+// explodeRows returns the rows --explode makes of data, a document as read
+// from Firestore: one per element of its array field, each holding the
+// document's other fields. The keys of a map element become columns named
+// field.key, so they can't collide with the document's own fields; any other
+// element is written under field itself. A document without the field, or
+// with an empty array, gives one row without it, and one whose field isn't an
+// array gives one row as it is. data is not modified.
+func explodeRows(data map[string]any, field string) []map[string]any {
+	v, ok := data[field]
+	if !ok {
+		return []map[string]any{data}
+	}
+	elems, ok := v.([]any)
+	if !ok {
+		return []map[string]any{data}
+	}
+
+	parent := make(map[string]any, len(data))
+	for k, v := range data {
+		if k != field {
+			parent[k] = v
+		}
+	}
+	if len(elems) == 0 {
+		return []map[string]any{parent}
+	}
+	rows := make([]map[string]any, len(elems))
+	for i, elem := range elems {
+		row := make(map[string]any, len(parent)+1)
+		for k, v := range parent {
+			row[k] = v
+		}
+		if m, ok := elem.(map[string]any); ok {
+			for k, v := range m {
+				row[field+"."+k] = v
+			}
+		} else {
+			row[field] = elem
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// prepareRows is prepareRecord for a document that --explode may turn into
+// several rows. The document is sanitized before it is split, so every row
+// gets the same replacement values.
+func prepareRows(data map[string]any, docPath string, cfg exportConfig) ([]map[string]any, error) {
+	if cfg.sanitizer != nil {
+		cfg.sanitizer.sanitizeLocked(data)
+	}
+	return shapeRows(data, docPath, cfg)
+}
+
+// shapeRows applies --cast and shapeRecord to the document at docPath, which
+// --explode may turn into several rows. Casts apply to each row, so they can
+// name the columns of exploded map elements.
+func shapeRows(data map[string]any, docPath string, cfg exportConfig) ([]map[string]any, error) {
+	if cfg.explode == "" {
+		rec, err := shapeRecord(castFields(data, docPath, cfg), cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]any{rec}, nil
+	}
+	rows := explodeRows(data, cfg.explode)
+	for i, row := range rows {
+		rec, err := shapeRecord(castFields(row, docPath, cfg), cfg)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = rec
+	}
+	return rows, nil
+}
+
+// validateExplode rejects options that --explode can't honor.
+func validateExplode(cfg exportConfig) error {
+	switch {
+	case len(cfg.fields) > 0:
+		// The header would be the selected fields, without the element columns.
+		return fmt.Errorf("--explode can't be combined with --fields")
+	case cfg.dedupBy != "":
+		return fmt.Errorf("--explode can't be combined with --dedup-by; the rows of a document share its fields")
+	case cfg.splitDocuments:
+		return fmt.Errorf("--explode writes several rows per document; it can't be combined with --split-documents")
+	case cfg.resume:
+		return fmt.Errorf("--explode can't be combined with --resume")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplodeRows(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]any
+		want []map[string]any
+	}{
+		{
+			name: "maps",
+			data: map[string]any{"total": 30, "lineItems": []any{
+				map[string]any{"sku": "a", "qty": 1},
+				map[string]any{"sku": "b", "qty": 2},
+			}},
+			want: []map[string]any{
+				{"total": 30, "lineItems.sku": "a", "lineItems.qty": 1},
+				{"total": 30, "lineItems.sku": "b", "lineItems.qty": 2},
+			},
+		},
+		{
+			name: "scalars",
+			data: map[string]any{"id": 1, "lineItems": []any{"a", "b"}},
+			want: []map[string]any{{"id": 1, "lineItems": "a"}, {"id": 1, "lineItems": "b"}},
+		},
+		{
+			name: "empty array",
+			data: map[string]any{"id": 1, "lineItems": []any{}},
+			want: []map[string]any{{"id": 1}},
+		},
+		{
+			name: "absent",
+			data: map[string]any{"id": 1},
+			want: []map[string]any{{"id": 1}},
+		},
+		{
+			name: "not an array",
+			data: map[string]any{"id": 1, "lineItems": "none"},
+			want: []map[string]any{{"id": 1, "lineItems": "none"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explodeRows(tt.data, "lineItems"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("explodeRows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplodeRows_NoSideEffects(t *testing.T) {
+	data := map[string]any{"id": 1, "lineItems": []any{map[string]any{"sku": "a"}}}
+	explodeRows(data, "lineItems")
+	if _, ok := data["lineItems"]; !ok || len(data) != 2 {
+		t.Errorf("explodeRows() modified its input: %v", data)
+	}
+}
+
+func TestShapeRows_Flatten(t *testing.T) {
+	data := map[string]any{"lineItems": []any{
+		map[string]any{"sku": "a", "price": map[string]any{"amount": 10, "currency": "EUR"}},
+	}}
+	got, err := shapeRows(data, "orders/o1", exportConfig{explode: "lineItems", flatten: true})
+	if err != nil {
+		t.Fatalf("shapeRows() error = %v", err)
+	}
+	want := []map[string]any{{"lineItems.sku": "a", "lineItems.price.amount": 10, "lineItems.price.currency": "EUR"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRows() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteCollection_Explode(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := exportConfig{output: tmpDir, format: "csv", explode: "lineItems"}
+	raw := map[string]string{
+		"orders/o1": "a,b",
+		"orders/o2": "",
+	}
+	var docs []docRecord
+	fieldSet := make(map[string]struct{})
+	for _, path := range sortedKeys(raw) {
+		var items []any
+		for _, sku := range strings.Split(raw[path], ",") {
+			if sku != "" {
+				items = append(items, map[string]any{"sku": sku})
+			}
+		}
+		rows, err := shapeRows(map[string]any{"status": "paid", "lineItems": items}, "orders/o1", cfg)
+		if err != nil {
+			t.Fatalf("shapeRows() error = %v", err)
+		}
+		for _, row := range rows {
+			for k := range row {
+				fieldSet[k] = struct{}{}
+			}
+			docs = append(docs, docRecord{path: path, data: row})
+		}
+	}
+
+	filePath, err := writeCollection(docs, fieldSet, "orders", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "__path__,lineItems.sku,status\norders/o1,a,paid\norders/o1,b,paid\norders/o2,,paid\n"
+	if string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestValidateExplode(t *testing.T) {
+	base := exportConfig{output: ".", format: "csv", explode: "lineItems"}
+	if err := validateExplode(base); err != nil {
+		t.Errorf("validateExplode() error = %v", err)
+	}
+	invalid := map[string]func(*exportConfig){
+		"--fields":          func(c *exportConfig) { c.fields = []string{"lineItems"} },
+		"--dedup-by":        func(c *exportConfig) { c.dedupBy = "id" },
+		"--split-documents": func(c *exportConfig) { c.splitDocuments = true },
+		"--resume":          func(c *exportConfig) { c.resume = true },
+	}
+	for wantErr, modify := range invalid {
+		cfg := base
+		modify(&cfg)
+		if err := validateExplode(cfg); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("validateExplode() error = %v, want containing %q", err, wantErr)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+const gcsScheme = "gs://"
+
+// isGCSURL reports whether an --output value points at Google Cloud Storage.
+func isGCSURL(output string) bool {
+	return strings.HasPrefix(output, gcsScheme)
+}
+
+// parseGCSURL splits a gs://bucket/prefix URL into its bucket and object
+// prefix. The prefix may be empty and never has leading or trailing slashes.
+func parseGCSURL(raw string) (bucket, prefix string, err error) {
+	rest := strings.TrimPrefix(raw, gcsScheme)
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid GCS URL %q: missing bucket name", raw)
+	}
+	return bucket, strings.Trim(prefix, "/"), nil
+}
+
+// gcsOutput writes export files as objects under a gs://bucket/prefix location.
+type gcsOutput struct {
+	ctx    context.Context // governs object uploads for the whole run
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// newGCSOutput creates a Cloud Storage client for the given gs:// URL. It
+// authenticates like the Firestore client: with the --credentials key file if
+// given, otherwise Application Default Credentials.
+func newGCSOutput(ctx context.Context, rawURL, credentials string) (*gcsOutput, error) {
+	bucket, prefix, err := parseGCSURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewClient(ctx, clientOptions(credentials)...)
+	if err != nil {
+		return nil, fmt.Errorf("creating Cloud Storage client: %w", err)
+	}
+	return &gcsOutput{ctx: ctx, client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// objectName returns the object name for a file path relative to the prefix.
+func (g *gcsOutput) objectName(name string) string {
+	if g.prefix == "" {
+		return name
+	}
+	return path.Join(g.prefix, name)
+}
+
+// create opens a writer for the named object and returns it with the object's
+// gs:// URL. The upload is only committed when the writer is closed.
+func (g *gcsOutput) create(name string) (io.WriteCloser, string) {
+	obj := g.objectName(name)
+	w := g.client.Bucket(g.bucket).Object(obj).NewWriter(g.ctx)
+	return w, gcsScheme + g.bucket + "/" + obj
+}
+
+// withCancel returns a copy of g whose uploads use a context of their own.
+// Calling cancel before one of its writers is closed aborts that upload, so
+// closing the writer doesn't commit a truncated object.
+func (g *gcsOutput) withCancel() (*gcsOutput, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(g.ctx)
+	c := *g
+	c.ctx = ctx
+	return &c, cancel
+}
+
+func (g *gcsOutput) close() error {
+	return g.client.Close()
+}
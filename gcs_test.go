@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+func TestParseGCSURL(t *testing.T) {
+	tests := []struct {
+		raw        string
+		wantBucket string
+		wantPrefix string
+	}{
+		{"gs://bucket", "bucket", ""},
+		{"gs://bucket/", "bucket", ""},
+		{"gs://bucket/exports", "bucket", "exports"},
+		{"gs://bucket/exports/2024/", "bucket", "exports/2024"},
+	}
+	for _, tt := range tests {
+		bucket, prefix, err := parseGCSURL(tt.raw)
+		if err != nil {
+			t.Errorf("parseGCSURL(%q) error: %v", tt.raw, err)
+			continue
+		}
+		if bucket != tt.wantBucket || prefix != tt.wantPrefix {
+			t.Errorf("parseGCSURL(%q) = (%q, %q), want (%q, %q)", tt.raw, bucket, prefix, tt.wantBucket, tt.wantPrefix)
+		}
+	}
+
+	if _, _, err := parseGCSURL("gs://"); err == nil {
+		t.Error("parseGCSURL(\"gs://\") expected error for missing bucket")
+	}
+}
+
+func TestGCSOutput_ObjectName(t *testing.T) {
+	g := &gcsOutput{bucket: "b"}
+	if got := g.objectName("users/u1/orders.csv"); got != "users/u1/orders.csv" {
+		t.Errorf("objectName without prefix = %q", got)
+	}
+	g.prefix = "exports"
+	if got := g.objectName("users.csv"); got != "exports/users.csv" {
+		t.Errorf("objectName with prefix = %q", got)
+	}
+}
+
+func TestWriteCollectionFiles_GCSAbortsFailedUpload(t *testing.T) {
+	var uploads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploads.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"bucket":"b","name":"users.jsonl"}`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	cfg := exportConfig{format: "jsonl", gcs: &gcsOutput{ctx: ctx, client: client, bucket: "b"}}
+
+	// JSON has no NaN, so the second document fails to write.
+	docs := []docRecord{
+		{path: "users/a", data: map[string]any{"n": 1.0}},
+		{path: "users/b", data: map[string]any{"n": math.NaN()}},
+	}
+	if _, _, err := writeCollectionFiles(docs, nil, "users", cfg); err == nil {
+		t.Fatal("writeCollectionFiles() error = nil, want error")
+	}
+	if n := uploads.Load(); n != 0 {
+		t.Errorf("%d upload request(s) sent, want the failed file discarded", n)
+	}
+
+	// The same export without the bad document is committed.
+	if _, _, err := writeCollectionFiles(docs[:1], nil, "users", cfg); err != nil {
+		t.Fatalf("writeCollectionFiles() error = %v", err)
+	}
+	if uploads.Load() == 0 {
+		t.Error("no upload request sent for a successful export")
+	}
+}
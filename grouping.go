@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// numberGrouping holds the separators --group-numbers writes in numeric CSV
+// cells, those of a --locale as CLDR defines them: 1,234,567.5 for en,
+// 1.234.567,5 for de, 12,34,567.5 for hi. Digits stay ASCII in every locale.
+type numberGrouping struct {
+	group     string // between groups of digits; empty if the locale doesn't group
+	decimal   string // before the fractional digits
+	primary   int    // digits in the group left of the decimal separator
+	secondary int    // digits in each group further left
+}
+
+// newNumberGrouping returns the numberGrouping of locale, a BCP 47 tag such
+// as en, de-CH or en-IN.
+func newNumberGrouping(locale string) (*numberGrouping, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --locale %q: %w", locale, err)
+	}
+	// x/text keeps its CLDR tables internal, so the separators are read off
+	// numbers formatted for the locale.
+	p := message.NewPrinter(tag)
+	g := &numberGrouping{decimal: ".", primary: 3, secondary: 3}
+	var sizes []int
+	var sep strings.Builder
+	digits := 0
+	for _, r := range p.Sprintf("%d", 1234567890) + "|" {
+		if unicode.IsDigit(r) {
+			digits++
+			continue
+		}
+		sizes = append(sizes, digits)
+		digits = 0
+		if r != '|' && len(sizes) == 1 {
+			sep.WriteRune(r)
+		}
+	}
+	if len(sizes) > 1 {
+		g.group = sep.String()
+		g.primary = sizes[len(sizes)-1]
+		g.secondary = sizes[len(sizes)-2]
+	}
+	if dec := strings.TrimFunc(p.Sprintf("%.1f", 1.5), unicode.IsDigit); dec != "" {
+		g.decimal = dec
+	}
+	return g, nil
+}
+
+// format inserts the separators into s, a number as formatValue writes it:
+// an optional minus sign, digits, and an optional fraction after a point.
+// Anything else, NaN or Inf say, is returned as is, as is s when g is nil.
+func (g *numberGrouping) format(s string) string {
+	if g == nil {
+		return s
+	}
+	sign, digits := "", s
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	intPart, frac, hasFrac := strings.Cut(digits, ".")
+	if intPart == "" || strings.ContainsFunc(intPart, func(r rune) bool { return r < '0' || r > '9' }) {
+		return s
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	if g.group != "" && len(intPart) > g.primary {
+		// Groups left of the primary one are secondary-sized; the leftmost
+		// may be shorter.
+		head := intPart[:len(intPart)-g.primary]
+		first := len(head) % g.secondary
+		if first == 0 {
+			first = g.secondary
+		}
+		b.WriteString(head[:first])
+		for i := first; i < len(head); i += g.secondary {
+			b.WriteString(g.group)
+			b.WriteString(head[i : i+g.secondary])
+		}
+		b.WriteString(g.group)
+		b.WriteString(intPart[len(head):])
+	} else {
+		b.WriteString(intPart)
+	}
+	if hasFrac {
+		b.WriteString(g.decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// separators returns the characters g writes into numbers.
+func (g *numberGrouping) separators() string {
+	return g.group + g.decimal
+}
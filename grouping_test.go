@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestNumberGrouping(t *testing.T) {
+	tests := []struct {
+		locale string
+		in     string
+		want   string
+	}{
+		{"en", "1234567", "1,234,567"},
+		{"en", "-1234567.25", "-1,234,567.25"},
+		{"en", "123", "123"},
+		{"en", "1234", "1,234"},
+		{"en", "0.5", "0.5"},
+		{"en", "NaN", "NaN"},
+		{"en", "+Inf", "+Inf"},
+		{"de", "1234567.5", "1.234.567,5"},
+		{"hi", "1234567", "12,34,567"},
+		{"en-IN", "123456789.5", "12,34,56,789.5"},
+		{"de-CH", "1234567", "1’234’567"},
+		{"fr", "1234.5", "1\u00a0234,5"},
+	}
+	for _, tt := range tests {
+		g, err := newNumberGrouping(tt.locale)
+		if err != nil {
+			t.Fatalf("newNumberGrouping(%q) error = %v", tt.locale, err)
+		}
+		if got := g.format(tt.in); got != tt.want {
+			t.Errorf("format(%q) with %s = %q, want %q", tt.in, tt.locale, got, tt.want)
+		}
+	}
+
+	if _, err := newNumberGrouping("not a locale"); err == nil {
+		t.Error("newNumberGrouping(invalid) error = nil, want error")
+	}
+	var off *numberGrouping
+	if got := off.format("1234"); got != "1234" {
+		t.Errorf("nil format() = %q, want it unchanged", got)
+	}
+}
+
+func TestFormatValue_GroupNumbers(t *testing.T) {
+	g, err := newNumberGrouping("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vf := valueFormatter{grouping: g, numberFmt: numberFormatFloat, arrayDelim: "|"}
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{int64(1234567), "1,234,567.0"},
+		{1e21, "1,000,000,000,000,000,000,000.0"},
+		{[]any{int64(1000), 2.5}, "1,000.0|2.5"},
+		{"1234", "1234"},
+		// JSON cells keep plain numbers.
+		{map[string]any{"n": int64(1000)}, `{"n":1000}`},
+	}
+	for _, tt := range tests {
+		if got := vf.formatValue(tt.in); got != tt.want {
+			t.Errorf("formatValue(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// hookNote marks collections in the run summary whose --on-file command
+// failed after their files were written.
+const hookNote = "__hook_failed__"
+
+// commandHook is an --on-file or --on-complete command. It runs without a
+// shell: the template is split into words once, and each {name} placeholder is
+// replaced inside its word, so a value with spaces stays a single argument.
+type commandHook struct {
+	flag  string // for messages, e.g. "--on-file"
+	words []string
+}
+
+// parseHook splits the template of flag into words, as a shell would: words
+// are separated by spaces and tabs, quotes group them, and a backslash
+// escapes the next character outside single quotes. It returns nil for an
+// empty template.
+func parseHook(flag, template string) (*commandHook, error) {
+	if strings.TrimSpace(template) == "" {
+		return nil, nil
+	}
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range template {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	switch {
+	case quote != 0:
+		return nil, fmt.Errorf("invalid %s: unterminated %c quote", flag, quote)
+	case escaped:
+		return nil, fmt.Errorf("invalid %s: trailing backslash", flag)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return &commandHook{flag: flag, words: words}, nil
+}
+
+// command returns the hook's arguments with the placeholders replaced by
+// vars, keyed by name without the braces.
+func (h *commandHook) command(vars map[string]string) []string {
+	pairs := make([]string, 0, 2*len(vars))
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	r := strings.NewReplacer(pairs...)
+	args := make([]string, len(h.words))
+	for i, w := range h.words {
+		args[i] = r.Replace(w)
+	}
+	return args
+}
+
+// run runs the hook for collection ("" for the whole run) and waits for it.
+// Its output is logged with --verbose; when it fails, the error holds the
+// exit status and the last line of output. ctx cancels it, as --timeout and
+// interrupts cancel the export.
+func (h *commandHook) run(ctx context.Context, collection string, vars map[string]string) error {
+	args := h.command(vars)
+	if verbosity >= verboseQueries {
+		printDebugFor(collection, "Running %s: %s", h.flag, strings.Join(args, " "))
+	}
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	lines := strings.Split(string(bytes.TrimSpace(out)), "\n")
+	if err != nil {
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			return fmt.Errorf("%w: %s", err, last)
+		}
+		return err
+	}
+	if verbosity >= verboseQueries && len(out) > 0 {
+		for _, line := range lines {
+			printDebugFor(collection, "%s: %s", h.flag, line)
+		}
+	}
+	return nil
+}
+
+// runFileHook runs the --on-file command for each file of a collection that
+// was exported without errors: its file, or each of its --max-file-size parts.
+// The first failure is recorded in r.hookErr and stops the remaining parts;
+// the export goes on.
+func runFileHook(ctx context.Context, r *exportResult, cfg exportConfig) {
+	if cfg.onFile == nil || cfg.dryRun || r.err != nil || r.partial || r.filePath == "" {
+		return
+	}
+	files := r.parts
+	if len(files) == 0 {
+		files = []string{r.filePath}
+	}
+	for _, file := range files {
+		vars := map[string]string{"file": file, "collection": r.collection, "database": cfg.database}
+		if err := cfg.onFile.run(ctx, r.collection, vars); err != nil {
+			printErrFor(r.collection, "%s failed for %s: %v", cfg.onFile.flag, file, err)
+			r.hookErr = err
+			return
+		}
+	}
+}
+
+// runCompleteHook runs the --on-complete command once the export is done,
+// with {output} set to --output and {failed} to the number of collections
+// that failed. It doesn't run after a dry run or once the export was stopped.
+func runCompleteHook(ctx context.Context, failed int, cfg exportConfig) error {
+	if cfg.onComplete == nil || cfg.dryRun {
+		return nil
+	}
+	if ctx.Err() != nil {
+		printInfo("Export stopped; not running %s", cfg.onComplete.flag)
+		return nil
+	}
+	vars := map[string]string{"output": cfg.output, "failed": strconv.Itoa(failed)}
+	if err := cfg.onComplete.run(ctx, "", vars); err != nil {
+		printErr("%s failed: %v", cfg.onComplete.flag, err)
+		return err
+	}
+	return nil
+}
+
+// validateOnFile rejects options that leave --on-file without a file per
+// collection to run on.
+func validateOnFile(cfg exportConfig) error {
+	switch {
+	case cfg.output == stdoutOutput:
+		return fmt.Errorf("--on-file needs files; it can't be used with --output -")
+	case cfg.splitDocuments:
+		return fmt.Errorf("--on-file can't be combined with --split-documents, which writes a file per document")
+	case cfg.singleFile:
+		return fmt.Errorf("--on-file can't be combined with --single-file; use --on-complete to run a command on the combined file")
+	}
+	return nil
+}
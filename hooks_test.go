@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHook(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"./load.sh {file}", []string{"./load.sh", "{file}"}},
+		{`bq load  "my dataset.{collection}"	{file}`, []string{"bq", "load", "my dataset.{collection}", "{file}"}},
+		{`sh -c 'gzip "$1"' _ {file}`, []string{"sh", "-c", `gzip "$1"`, "_", "{file}"}},
+		{`echo a\ b "c\"d" ''`, []string{"echo", "a b", `c"d`, ""}},
+	}
+	for _, tt := range tests {
+		h, err := parseHook("--on-file", tt.in)
+		if err != nil {
+			t.Fatalf("parseHook(%q) error = %v", tt.in, err)
+		}
+		if !reflect.DeepEqual(h.words, tt.want) {
+			t.Errorf("parseHook(%q) = %q, want %q", tt.in, h.words, tt.want)
+		}
+	}
+
+	if h, err := parseHook("--on-file", "  "); h != nil || err != nil {
+		t.Errorf("parseHook(blank) = %v, %v; want nil, nil", h, err)
+	}
+	for _, in := range []string{`echo "open`, `echo 'open`, `echo \`} {
+		if _, err := parseHook("--on-file", in); err == nil {
+			t.Errorf("parseHook(%q) error = nil, want error", in)
+		}
+	}
+}
+
+func TestCommandHook_Command(t *testing.T) {
+	h, err := parseHook("--on-file", "load {collection} {file} --tag={collection}-{database} {other}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := h.command(map[string]string{"file": "out/my users.csv", "collection": "users", "database": "(default)"})
+	want := []string{"load", "users", "out/my users.csv", "--tag=users-(default)", "{other}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("command() = %q, want %q", got, want)
+	}
+}
+
+func TestRunFileHook(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "hook.log")
+	h, err := parseHook("--on-file", `sh -c 'echo "$1 $2" >> "$0"' `+log+` {collection} {file}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := exportConfig{onFile: h}
+	ctx := context.Background()
+
+	r := exportResult{collection: "users", filePath: "out/users.csv", parts: []string{"out/users.csv", "out/users.part2.csv"}}
+	runFileHook(ctx, &r, cfg)
+	// Failed, partial and fileless collections are skipped.
+	for _, skipped := range []exportResult{
+		{collection: "orders", err: errors.New("boom")},
+		{collection: "orders", filePath: "out/orders.csv", partial: true},
+		{collection: "empty"},
+	} {
+		runFileHook(ctx, &skipped, cfg)
+	}
+	if r.hookErr != nil {
+		t.Fatalf("hookErr = %v", r.hookErr)
+	}
+	got, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "users out/users.csv\nusers out/users.part2.csv\n"; string(got) != want {
+		t.Errorf("hook ran with %q, want %q", got, want)
+	}
+
+	h, err = parseHook("--on-file", `sh -c 'echo "no such table" >&2; exit 3'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = exportResult{collection: "users", filePath: "out/users.csv"}
+	captureStderr(t, func() { runFileHook(ctx, &r, exportConfig{onFile: h}) })
+	if r.hookErr == nil || !strings.Contains(r.hookErr.Error(), "exit status 3: no such table") {
+		t.Errorf("hookErr = %v, want the exit status and last output line", r.hookErr)
+	}
+}
+
+func TestRunCompleteHook(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "hook.log")
+	h, err := parseHook("--on-complete", `sh -c 'echo "$1 $2" > "$0"' `+log+` {output} {failed}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := exportConfig{output: "out", onComplete: h}
+	if err := runCompleteHook(context.Background(), 2, cfg); err != nil {
+		t.Fatalf("runCompleteHook() error = %v", err)
+	}
+	if got, _ := os.ReadFile(log); string(got) != "out 2\n" {
+		t.Errorf("hook ran with %q, want %q", got, "out 2\n")
+	}
+
+	fail, err := parseHook("--on-complete", "false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.onComplete = fail
+	captureStderr(t, func() { err = runCompleteHook(context.Background(), 0, cfg) })
+	if err == nil {
+		t.Error("runCompleteHook(false) error = nil, want error")
+	}
+	cfg.dryRun = true
+	if err := runCompleteHook(context.Background(), 0, cfg); err != nil {
+		t.Errorf("runCompleteHook(dry run) error = %v, want it skipped", err)
+	}
+}
+
+func TestValidateOnFile(t *testing.T) {
+	if err := validateOnFile(exportConfig{output: "out"}); err != nil {
+		t.Errorf("validateOnFile() error = %v", err)
+	}
+	for name, cfg := range map[string]exportConfig{
+		"--output -":        {output: stdoutOutput},
+		"--split-documents": {output: "out", splitDocuments: true},
+		"--single-file":     {output: "out", singleFile: true},
+	} {
+		if err := validateOnFile(cfg); err == nil {
+			t.Errorf("validateOnFile(%s) error = nil, want error", name)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// idCodec is a format of time-sortable document IDs, selected with
+// --id-codec. Its IDs sort by the time they were generated, so a time window
+// is a range of IDs that --id-start-time and --id-end-time turn into
+// --id-start and --id-end.
+type idCodec struct {
+	// first and last return the smallest and largest ID generated at t, or
+	// an error if the format can't represent t.
+	first, last func(t time.Time) (string, error)
+	// valid reports why id isn't an ID of the format, or nil if it is.
+	valid func(id string) error
+}
+
+// idCodecs maps --id-codec values to their codecs.
+var idCodecs = map[string]idCodec{
+	"ulid":  {first: ulidBound('0'), last: ulidBound('Z'), valid: validULID},
+	"ksuid": {first: ksuidBound(false), last: ksuidBound(true), valid: validKSUID},
+}
+
+// idCodecNames returns the accepted --id-codec values, for error messages.
+func idCodecNames() []string {
+	names := make([]string, 0, len(idCodecs))
+	for name := range idCodecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// crockford is the base32 alphabet of ULIDs, in sort order.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDs are 26 characters: a 10-character timestamp in milliseconds, then 16
+// random ones. Only the canonical upper case sorts correctly.
+const (
+	ulidLen     = 26
+	ulidTimeLen = 10
+)
+
+// ulidBound returns the bound of ULIDs generated at a time, with every random
+// character set to fill.
+func ulidBound(fill byte) func(t time.Time) (string, error) {
+	return func(t time.Time) (string, error) {
+		ms := t.UnixMilli()
+		if ms < 0 || ms >= 1<<48 {
+			return "", fmt.Errorf("%s is outside the range of ULID timestamps", t.Format(time.RFC3339))
+		}
+		b := make([]byte, ulidLen)
+		for i := ulidTimeLen - 1; i >= 0; i-- {
+			b[i] = crockford[ms&31]
+			ms >>= 5
+		}
+		for i := ulidTimeLen; i < ulidLen; i++ {
+			b[i] = fill
+		}
+		return string(b), nil
+	}
+}
+
+func validULID(id string) error {
+	if len(id) != ulidLen {
+		return fmt.Errorf("%d characters, not %d", len(id), ulidLen)
+	}
+	if id[0] > '7' {
+		// The timestamp is 48 bits; the first character holds only three.
+		return fmt.Errorf("timestamp out of range")
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(crockford, c) {
+			return fmt.Errorf("%q is not an upper-case Crockford base32 character", c)
+		}
+	}
+	return nil
+}
+
+// base62 is the alphabet of KSUIDs, in sort order.
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// KSUIDs are 20 bytes in 27 base62 characters: a 4-byte timestamp in seconds
+// since ksuidEpoch, then a 16-byte payload.
+const (
+	ksuidLen   = 27
+	ksuidEpoch = 1400000000
+)
+
+// ksuidMax is the largest 20-byte value.
+var ksuidMax = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+
+// ksuidBound returns the bound of KSUIDs generated in the second of a time,
+// with the payload all zero bits, or all one bits if full.
+func ksuidBound(full bool) func(t time.Time) (string, error) {
+	return func(t time.Time) (string, error) {
+		ts := t.Unix() - ksuidEpoch
+		if ts < 0 || ts >= 1<<32 {
+			return "", fmt.Errorf("%s is outside the range of KSUID timestamps", t.Format(time.RFC3339))
+		}
+		n := new(big.Int).Lsh(big.NewInt(ts), 128)
+		if full {
+			n.Or(n, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1)))
+		}
+		return encodeKSUID(n), nil
+	}
+}
+
+// encodeKSUID writes the 20-byte value n in base62, padded to ksuidLen
+// characters. big.Int's own base 62 puts lower case first, so it is written
+// here.
+func encodeKSUID(n *big.Int) string {
+	n = new(big.Int).Set(n)
+	base, digit := big.NewInt(62), new(big.Int)
+	b := make([]byte, ksuidLen)
+	for i := ksuidLen - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		b[i] = base62[digit.Int64()]
+	}
+	return string(b)
+}
+
+func validKSUID(id string) error {
+	if len(id) != ksuidLen {
+		return fmt.Errorf("%d characters, not %d", len(id), ksuidLen)
+	}
+	n, base := new(big.Int), big.NewInt(62)
+	for _, c := range id {
+		i := strings.IndexRune(base62, c)
+		if i < 0 {
+			return fmt.Errorf("%q is not a base62 character", c)
+		}
+		n.Mul(n, base).Add(n, big.NewInt(int64(i)))
+	}
+	if n.Cmp(ksuidMax) > 0 {
+		return fmt.Errorf("value out of range")
+	}
+	return nil
+}
+
+// idTimeBounds returns the --id-start and --id-end bounds of the IDs the
+// --id-codec codec generates between the --id-start-time and --id-end-time
+// timestamps, either of which may be empty.
+func idTimeBounds(codecName, startTime, endTime string) (string, string, error) {
+	codec, ok := idCodecs[codecName]
+	switch {
+	case codecName == "":
+		return "", "", fmt.Errorf("--id-start-time and --id-end-time need --id-codec, the format of the document IDs")
+	case !ok:
+		return "", "", fmt.Errorf("invalid --id-codec %q: must be one of %s", codecName, strings.Join(idCodecNames(), ", "))
+	case startTime == "" && endTime == "":
+		return "", "", fmt.Errorf("--id-codec needs --id-start-time or --id-end-time")
+	}
+
+	var first, last string
+	var start time.Time
+	if startTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, startTime)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid --id-start-time %q: must be an RFC3339 timestamp such as 2024-06-01T00:00:00Z", startTime)
+		}
+		if first, err = codec.first(t); err != nil {
+			return "", "", fmt.Errorf("invalid --id-start-time: %w", err)
+		}
+		start = t
+	}
+	if endTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, endTime)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid --id-end-time %q: must be an RFC3339 timestamp such as 2024-06-01T00:00:00Z", endTime)
+		}
+		if !start.IsZero() && t.Before(start) {
+			return "", "", fmt.Errorf("--id-start-time %s is after --id-end-time %s", startTime, endTime)
+		}
+		if last, err = codec.last(t); err != nil {
+			return "", "", fmt.Errorf("invalid --id-end-time: %w", err)
+		}
+	}
+	return first, last, nil
+}
+
+// keepDocumentID reports whether a top-level document with the given ID is
+// exported with --id-codec. IDs of another format can sort inside the range,
+// so they are skipped, with a warning if warn is set.
+func keepDocumentID(displayPath, id string, cfg exportConfig, warn bool) bool {
+	codec, ok := idCodecs[cfg.idCodec]
+	if !ok {
+		return true
+	}
+	if err := codec.valid(id); err != nil {
+		if warn {
+			printWarn("Skipping %s/%s: its ID isn't a %s (%v)", displayPath, id, strings.ToUpper(cfg.idCodec), err)
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIDCodecBounds(t *testing.T) {
+	tests := []struct {
+		codec string
+		id    string // generated at at
+		at    time.Time
+	}{
+		{"ulid", "01ARYZ6S41TSV4RRFFQ69G5FAV", time.UnixMilli(1469918176385)},
+		{"ksuid", "0ujtsYcgvSTl8PAuAdqWYSMnLOv", time.Date(2017, 10, 10, 4, 0, 47, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			codec := idCodecs[tt.codec]
+			if err := codec.valid(tt.id); err != nil {
+				t.Fatalf("valid(%q) error = %v", tt.id, err)
+			}
+			first, err := codec.first(tt.at)
+			if err != nil {
+				t.Fatalf("first() error = %v", err)
+			}
+			last, err := codec.last(tt.at)
+			if err != nil {
+				t.Fatalf("last() error = %v", err)
+			}
+			if !(first <= tt.id && tt.id <= last) {
+				t.Errorf("%q not between first %q and last %q", tt.id, first, last)
+			}
+			if len(first) != len(tt.id) || len(last) != len(tt.id) {
+				t.Errorf("bounds %q, %q have a different length than %q", first, last, tt.id)
+			}
+
+			// IDs of the next and previous ticks fall outside.
+			tick := time.Millisecond
+			if tt.codec == "ksuid" {
+				tick = time.Second
+			}
+			if next, _ := codec.first(tt.at.Add(tick)); next <= tt.id {
+				t.Errorf("first(at + %s) = %q, want after %q", tick, next, tt.id)
+			}
+			if prev, _ := codec.last(tt.at.Add(-tick)); prev >= tt.id {
+				t.Errorf("last(at - %s) = %q, want before %q", tick, prev, tt.id)
+			}
+		})
+	}
+}
+
+func TestULIDBound(t *testing.T) {
+	got, err := idCodecs["ulid"].first(time.UnixMilli(1469918176385))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "01ARYZ6S41" + strings.Repeat("0", 16); got != want {
+		t.Errorf("first() = %q, want %q", got, want)
+	}
+	if _, err := idCodecs["ulid"].first(time.Unix(-1, 0)); err == nil {
+		t.Error("first(before 1970) error = nil, want error")
+	}
+	if _, err := idCodecs["ksuid"].first(time.Unix(ksuidEpoch-1, 0)); err == nil {
+		t.Error("ksuid first(before its epoch) error = nil, want error")
+	}
+}
+
+func TestIDCodecValid(t *testing.T) {
+	invalid := map[string][]string{
+		"ulid":  {"", "01ARYZ6S41TSV4RRFFQ69G5FA", "01arYZ6S41TSV4RRFFQ69G5FAV", "81ARYZ6S41TSV4RRFFQ69G5FAV", "01ARYZ6S41TSV4RRFFQ69G5FAU", "user1"},
+		"ksuid": {"", "0ujtsYcgvSTl8PAuAdqWYSMnLO", "0ujtsYcgvSTl8PAuAdqWYSMnLO-", "zzzzzzzzzzzzzzzzzzzzzzzzzzz"},
+	}
+	for codec, ids := range invalid {
+		for _, id := range ids {
+			if err := idCodecs[codec].valid(id); err == nil {
+				t.Errorf("%s valid(%q) = nil, want error", codec, id)
+			}
+		}
+	}
+}
+
+func TestIDTimeBounds(t *testing.T) {
+	start, end, err := idTimeBounds("ulid", "2016-07-30T22:36:16.385Z", "")
+	if err != nil {
+		t.Fatalf("idTimeBounds() error = %v", err)
+	}
+	if !strings.HasPrefix(start, "01ARYZ6S41") || end != "" {
+		t.Errorf("idTimeBounds() = %q, %q; want a start with prefix 01ARYZ6S41 and no end", start, end)
+	}
+
+	tests := []struct {
+		codec, start, end string
+		wantErr           string
+	}{
+		{"", "2024-01-01T00:00:00Z", "", "need --id-codec"},
+		{"uuid", "2024-01-01T00:00:00Z", "", "invalid --id-codec"},
+		{"ulid", "", "", "needs --id-start-time or --id-end-time"},
+		{"ulid", "yesterday", "", "invalid --id-start-time"},
+		{"ulid", "", "2024-13-01T00:00:00Z", "invalid --id-end-time"},
+		{"ksuid", "2024-02-01T00:00:00Z", "2024-01-01T00:00:00Z", "is after --id-end-time"},
+	}
+	for _, tt := range tests {
+		if _, _, err := idTimeBounds(tt.codec, tt.start, tt.end); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("idTimeBounds(%q, %q, %q) error = %v, want containing %q", tt.codec, tt.start, tt.end, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKeepDocumentID(t *testing.T) {
+	cfg := exportConfig{idCodec: "ulid"}
+	if !keepDocumentID("events", "01ARYZ6S41TSV4RRFFQ69G5FAV", cfg, false) {
+		t.Error("keepDocumentID(ULID) = false, want true")
+	}
+	if keepDocumentID("events", "manual-entry", cfg, false) {
+		t.Error("keepDocumentID(not a ULID) = true, want false")
+	}
+	if !keepDocumentID("events", "manual-entry", exportConfig{}, false) {
+		t.Error("keepDocumentID(without --id-codec) = false, want true")
+	}
+}
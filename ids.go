@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+)
+
+// maxInValues is the most values Firestore accepts in one "in" filter.
+const maxInValues = 30
+
+// parseDocumentIDs parses the comma-separated --ids list. IDs name documents
+// directly in the exported collections, so they can't contain slashes.
+func parseDocumentIDs(raw string) ([]string, error) {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(raw, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if strings.Contains(id, "/") {
+			return nil, fmt.Errorf("invalid ID %q: must be a document ID, not a path", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate ID %q", id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// idScan returns a scanFunc that reads the --ids documents of colRef instead
+// of the whole collection. They are fetched with document ID "in" queries
+// rather than one Get each, so --where and --fields still apply and reads are
+// retried like any other query. IDs with no matching document are reported
+// once, even though --stream scans twice, and skipped.
+func idScan(ctx context.Context, colRef *firestore.CollectionRef, ids []string, cfg exportConfig) scanFunc {
+	reported := make(map[string]bool)
+	return func(sp *spinner, label string, fn func(snap *firestore.DocumentSnapshot) error) (int, error) {
+		var queries []firestore.Query
+		for start := 0; start < len(ids); start += maxInValues {
+			refs := make([]*firestore.DocumentRef, 0, maxInValues)
+			for _, id := range ids[start:min(start+maxInValues, len(ids))] {
+				refs = append(refs, colRef.Doc(id))
+			}
+			query := applyWhereFilters(colRef.Where(firestore.DocumentID, "in", refs), cfg.where)
+			query = applyOrderBy(applyFieldSelection(query, cfg.fields), cfg.orderBy, cfg.where)
+			queries = append(queries, query)
+		}
+
+		found := make(map[string]bool, len(ids))
+		count, err := scanDocuments(ctx, queries, 0, cfg.pageSize, cfg.maxRetries, cfg.limiter, sp, label, func(snap *firestore.DocumentSnapshot) error {
+			found[snap.Ref.ID] = true
+			return fn(snap)
+		})
+		if err != nil {
+			return count, err
+		}
+		for _, id := range ids {
+			if found[id] || reported[id] {
+				continue
+			}
+			reported[id] = true
+			if len(cfg.where) > 0 {
+				printWarn("Document %s not found or filtered out by --where; skipping it", documentPath(colRef.Doc(id)))
+			} else {
+				printWarn("Document %s not found; skipping it", documentPath(colRef.Doc(id)))
+			}
+		}
+		return count, nil
+	}
+}
+
+// validateIDs rejects options that --ids can't be combined with.
+func validateIDs(cfg exportConfig) error {
+	switch {
+	case cfg.group != "":
+		return fmt.Errorf("--ids can't be combined with --collection-group")
+	case cfg.collections == "" && cfg.collFile == "":
+		return fmt.Errorf("--ids needs --collections or --collections-file to name the collections holding the documents")
+	case cfg.resume:
+		return fmt.Errorf("--ids can't be combined with --resume")
+	}
+	return nil
+}
+
+// applyIDRange limits query, which must be ordered by document ID only, to
+// the documents whose IDs sort between --id-start and --id-end, both
+// inclusive. IDs compare as strings, byte by byte.
+func applyIDRange(query firestore.Query, cfg exportConfig) firestore.Query {
+	if cfg.idStart != "" {
+		query = query.StartAt(cfg.idStart)
+	}
+	if cfg.idEnd != "" {
+		query = query.EndAt(cfg.idEnd)
+	}
+	return query
+}
+
+// describeIDRange renders the --id-start and --id-end range for --verbose.
+func describeIDRange(cfg exportConfig) string {
+	return fmt.Sprintf("document IDs from %q to %q", cfg.idStart, cfg.idEnd)
+}
+
+// validateIDRange rejects --id-start and --id-end settings Firestore can't
+// run. The range is a cursor on the document ID order, so that has to be the
+// only order.
+func validateIDRange(cfg exportConfig) error {
+	switch {
+	case cfg.idStart != "" && cfg.idEnd != "" && cfg.idStart > cfg.idEnd:
+		return fmt.Errorf("--id-start %q sorts after --id-end %q", cfg.idStart, cfg.idEnd)
+	case strings.Contains(cfg.idStart, "/") || strings.Contains(cfg.idEnd, "/"):
+		return fmt.Errorf("--id-start and --id-end take document IDs, not paths")
+	case cfg.group != "":
+		return fmt.Errorf("--id-start and --id-end can't be combined with --collection-group")
+	case len(cfg.ids) > 0:
+		return fmt.Errorf("--id-start and --id-end can't be combined with --ids")
+	case len(cfg.orderBy) > 0:
+		return fmt.Errorf("--order-by can't be combined with --id-start or --id-end, which order by document ID")
+	}
+	for _, wf := range cfg.where {
+		if isInequalityOp(wf.op) {
+			return fmt.Errorf("--id-start and --id-end order documents by ID, which Firestore doesn't allow with the %q filter on %q", wf.op, wf.field)
+		}
+	}
+	return nil
+}
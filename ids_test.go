@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDocumentIDs(t *testing.T) {
+	got, err := parseDocumentIDs(" user1,user2 ,,user3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"user1", "user2", "user3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDocumentIDs() = %v, want %v", got, want)
+	}
+
+	invalid := map[string]string{
+		"users/user1": "not a path",
+		"a,b,a":       `duplicate ID "a"`,
+	}
+	for input, wantErr := range invalid {
+		if _, err := parseDocumentIDs(input); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("parseDocumentIDs(%q) error = %v, want containing %q", input, err, wantErr)
+		}
+	}
+}
+
+func TestValidateIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"collections", exportConfig{collections: "users"}, ""},
+		{"collections file", exportConfig{collFile: "collections.txt"}, ""},
+		{"all collections", exportConfig{}, "--collections"},
+		{"collection group", exportConfig{group: "orders"}, "--collection-group"},
+		{"resume", exportConfig{collections: "users", resume: true}, "--resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIDs(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateIDs() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateIDs() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateIDRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"start and end", exportConfig{idStart: "a", idEnd: "m"}, ""},
+		{"start only", exportConfig{idStart: "m"}, ""},
+		{"same ID", exportConfig{idStart: "m", idEnd: "m"}, ""},
+		{"equality filter", exportConfig{idEnd: "m", where: []whereFilter{{field: "status", op: "==", value: "active"}}}, ""},
+		{"start after end", exportConfig{idStart: "n", idEnd: "m"}, "sorts after"},
+		{"path", exportConfig{idStart: "users/a"}, "not paths"},
+		{"collection group", exportConfig{idStart: "a", group: "orders"}, "--collection-group"},
+		{"ids", exportConfig{idEnd: "m", ids: []string{"a"}}, "--ids"},
+		{"order by", exportConfig{idStart: "a", orderBy: []orderClause{{field: "createdAt"}}}, "--order-by"},
+		{"inequality filter", exportConfig{idStart: "a", where: []whereFilter{{field: "age", op: ">", value: int64(18)}}}, `"age"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIDRange(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateIDRange() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateIDRange() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
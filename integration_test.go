@@ -139,7 +139,7 @@ func cleanFirestore(t *testing.T, client *firestore.Client) {
 	t.Helper()
 	ctx := context.Background()
 
-	for _, name := range []string{"users", "products", "imported_users", "imported_products", "target", "heuristic_target", "virtual_parents"} {
+	for _, name := range []string{"users", "products", "imported_users", "imported_products", "target", "heuristic_target", "virtual_parents", "ref_holders"} {
 		deleteCollection(ctx, t, client, client.Collection(name))
 	}
 }
@@ -186,7 +186,7 @@ func TestResolveCollections_All(t *testing.T) {
 	seedFirestore(t, client)
 
 	ctx := context.Background()
-	names, err := resolveCollections(ctx, client, "")
+	names, err := resolveCollections(ctx, client, "", "", nil)
 	if err != nil {
 		t.Fatalf("resolveCollections() error = %v", err)
 	}
@@ -208,12 +208,28 @@ func TestResolveCollections_All(t *testing.T) {
 	}
 }
 
+func TestResolveCollections_Exclude(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	ctx := context.Background()
+	names, err := resolveCollections(ctx, client, "", "", []string{"users", "nope"})
+	if err != nil {
+		t.Fatalf("resolveCollections() error = %v", err)
+	}
+	for _, n := range names {
+		if n == "users" {
+			t.Errorf("excluded collection users still resolved: %v", names)
+		}
+	}
+}
+
 func TestResolveCollections_Filtered(t *testing.T) {
 	client := newTestClient(t)
 	seedFirestore(t, client)
 
 	ctx := context.Background()
-	names, err := resolveCollections(ctx, client, "users")
+	names, err := resolveCollections(ctx, client, "users", "", nil)
 	if err != nil {
 		t.Fatalf("resolveCollections() error = %v", err)
 	}
@@ -230,7 +246,7 @@ func TestExportSingleCollection(t *testing.T) {
 	tmpDir := t.TempDir()
 	ctx := context.Background()
 
-	results := exportCollectionTree(ctx, client, "users", 0, 0, 0, tmpDir, false, nil)
+	results := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir})
 
 	// depth=0 means top-level only
 	if len(results) != 1 {
@@ -260,7 +276,7 @@ func TestExportWithSubCollections(t *testing.T) {
 	ctx := context.Background()
 
 	// depth=-1 means unlimited recursion
-	results := exportCollectionTree(ctx, client, "users", 0, 0, -1, tmpDir, false, nil)
+	results := exportCollectionTree(ctx, client, "users", exportConfig{maxDepth: -1, output: tmpDir})
 
 	// Should have users + users/orders + users/orders/items
 	if len(results) < 3 {
@@ -293,7 +309,7 @@ func TestExportDepthLimit(t *testing.T) {
 	ctx := context.Background()
 
 	// depth=1 means users + orders but NOT items
-	results := exportCollectionTree(ctx, client, "users", 0, 0, 1, tmpDir, false, nil)
+	results := exportCollectionTree(ctx, client, "users", exportConfig{maxDepth: 1, output: tmpDir})
 
 	// Should have users + users/orders only
 	collections := map[string]bool{}
@@ -325,7 +341,7 @@ func TestExportWithLimit(t *testing.T) {
 	tmpDir := t.TempDir()
 	ctx := context.Background()
 
-	results := exportCollectionTree(ctx, client, "users", 1, 0, 0, tmpDir, false, nil)
+	results := exportCollectionTree(ctx, client, "users", exportConfig{limit: 1, output: tmpDir})
 
 	if len(results) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(results))
@@ -340,6 +356,369 @@ func TestExportWithLimit(t *testing.T) {
 	}
 }
 
+func TestExportWithWhere(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	where, err := parseWhereFilters([]string{"active == true", "age > 30"})
+	if err != nil {
+		t.Fatalf("parseWhereFilters() error = %v", err)
+	}
+	results := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir, where: where})
+	if len(results) != 1 || results[0].err != nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	// Only Charlie (35, active) matches both filters.
+	if results[0].docCount != 1 {
+		t.Errorf("docCount = %d, want 1", results[0].docCount)
+	}
+}
+
+func TestExportOrderBy(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	orderBy, err := parseOrderBy("age:desc")
+	if err != nil {
+		t.Fatalf("parseOrderBy() error = %v", err)
+	}
+	results := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir, orderBy: orderBy})
+	if len(results) != 1 || results[0].err != nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	records := readCSV(t, results[0].filePath)
+	var got []string
+	for _, rec := range records[1:] {
+		got = append(got, rec[0])
+	}
+	want := []string{"users/user3", "users/user1", "users/user2"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("row order = %v, want %v", got, want)
+	}
+}
+
+func TestExportPageSize(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	// users has three documents, so a page size of 2 takes two pages; with a
+	// limit of 3 the second page must stop at the limit.
+	for _, limit := range []int{0, 3} {
+		results := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir, pageSize: 2, limit: limit})
+		if len(results) != 1 || results[0].err != nil {
+			t.Fatalf("limit %d: unexpected results: %+v", limit, results)
+		}
+		if results[0].docCount != 3 {
+			t.Errorf("limit %d: docCount = %d, want 3", limit, results[0].docCount)
+		}
+	}
+
+	results := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir, pageSize: 2, limit: 1})
+	if len(results) != 1 || results[0].docCount != 1 {
+		t.Errorf("limit 1: unexpected results: %+v", results)
+	}
+}
+
+func TestCountDocuments(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	n, err := countDocuments(context.Background(), client.Collection("users").Query)
+	if err != nil {
+		t.Fatalf("countDocuments() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("countDocuments(users) = %d, want 3", n)
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	// Streaming has to type the columns from its discovery pass.
+	for _, stream := range []bool{false, true} {
+		results := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir, format: "parquet", stream: stream})
+		if len(results) != 1 || results[0].err != nil {
+			t.Fatalf("stream %v: unexpected results: %+v", stream, results)
+		}
+		types, rows := readParquet(t, results[0].filePath)
+		if len(rows) != 3 {
+			t.Fatalf("stream %v: got %d rows, want 3", stream, len(rows))
+		}
+		if types["age"] != "INT(64,true)" || types["active"] != "BOOLEAN" || types["created"] != "STRING" {
+			t.Errorf("stream %v: column types = %v", stream, types)
+		}
+		if got := rows[0]["age"].Int64(); got != 30 {
+			t.Errorf("stream %v: age = %d, want 30", stream, got)
+		}
+	}
+}
+
+func TestExportIncludeTimestamps(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	results := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir, includeTimestamps: true})
+	if len(results) != 1 || results[0].err != nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	records := readCSV(t, results[0].filePath)
+	if records[0][1] != "__create_time__" || records[0][2] != "__update_time__" {
+		t.Fatalf("header = %v, want timestamps after __path__", records[0])
+	}
+	for _, rec := range records[1:] {
+		for _, v := range rec[1:3] {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				t.Errorf("%s: bad timestamp %q", rec[0], v)
+			}
+		}
+	}
+}
+
+func TestExportIDs(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	cfg := exportConfig{output: tmpDir, ids: []string{"user3", "missing", "user1"}}
+	results := exportCollectionTree(ctx, client, "users", cfg)
+	if len(results) != 1 || results[0].err != nil {
+		t.Fatalf("exportCollectionTree() = %+v, want one successful result", results)
+	}
+	if results[0].docCount != 2 {
+		t.Errorf("docCount = %d, want 2 (the missing ID is skipped)", results[0].docCount)
+	}
+
+	records := readCSV(t, results[0].filePath)
+	var paths []string
+	for _, rec := range records[1:] {
+		paths = append(paths, rec[0])
+	}
+	if got := strings.Join(paths, ","); got != "users/user1,users/user3" {
+		t.Errorf("paths = %s, want users/user1,users/user3 in document ID order", got)
+	}
+}
+
+func TestExportCollectionGroup(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	result := exportCollectionGroup(ctx, client, "orders", exportConfig{output: tmpDir})
+	if result.err != nil {
+		t.Fatalf("exportCollectionGroup() error = %v", result.err)
+	}
+	if result.filePath != filepath.Join(tmpDir, "orders.csv") {
+		t.Errorf("filePath = %q, want orders.csv in the output directory", result.filePath)
+	}
+
+	records := readCSV(t, result.filePath)
+	if len(records) != 5 { // header + 4 orders across both users
+		t.Fatalf("expected 5 rows, got %d", len(records))
+	}
+	for _, rec := range records[1:] {
+		if !strings.HasPrefix(rec[0], "users/") || !strings.Contains(rec[0], "/orders/") {
+			t.Errorf("__path__ = %q, want the full path under its parent user", rec[0])
+		}
+	}
+}
+
+func TestExportDryRun(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	results := exportCollectionTree(ctx, client, "users", exportConfig{maxDepth: -1, output: tmpDir, dryRun: true})
+	if len(results) == 0 || results[0].err != nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[0].docCount != 3 || results[0].filePath != "(dry-run)" {
+		t.Errorf("users result = %+v, want 3 docs and (dry-run) output", results[0])
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("dry run wrote %d entries to the output directory", len(entries))
+	}
+}
+
+func TestExportPreview(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	cfg := exportConfig{maxDepth: 0, output: tmpDir, dryRun: true, preview: 2, limit: 2}
+	var results []exportResult
+	out := captureStderr(t, func() { results = exportCollectionTree(ctx, client, "users", cfg) })
+	if len(results) != 1 || results[0].err != nil || results[0].docCount != 2 {
+		t.Fatalf("results = %+v, want 2 users docs", results)
+	}
+	if rows := strings.Count(out, " users/"); rows != 2 {
+		t.Errorf("preview has %d document rows, want 2:\n%s", rows, out)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("preview wrote %d entries to the output directory", len(entries))
+	}
+}
+
+func TestExportCollectionsFailFast(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+	ctx := context.Background()
+
+	// A field named like the __path__ column fails the export by default.
+	broken := client.Collection("broken").Doc("a")
+	if _, err := broken.Set(ctx, map[string]any{"__path__": "x"}); err != nil {
+		t.Fatalf("seeding broken collection: %v", err)
+	}
+	t.Cleanup(func() { broken.Delete(ctx) })
+
+	names := []string{"broken", "users", "products"}
+	results := exportCollections(ctx, client, names, exportConfig{output: t.TempDir(), failFast: true})
+	if len(results) != 1 || results[0].collection != "broken" || results[0].err == nil {
+		t.Errorf("results = %+v, want only the failed broken collection", results)
+	}
+
+	results = exportCollections(ctx, client, names, exportConfig{output: t.TempDir()})
+	if len(results) != 3 {
+		t.Errorf("without --fail-fast got %d results, want 3", len(results))
+	}
+}
+
+func TestExportCollectionsConcurrent(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	names := []string{"users", "products"}
+	results := exportCollections(ctx, client, names, exportConfig{maxDepth: -1, output: tmpDir, concurrency: 2})
+
+	// Trees keep the order of names: users and its sub-collections, then products.
+	var got []string
+	for _, r := range results {
+		if r.err != nil {
+			t.Errorf("export %q error: %v", r.collection, r.err)
+		}
+		got = append(got, r.collection)
+	}
+	want := []string{"users", "users/orders", "users/orders/items", "products"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("result order = %v, want %v", got, want)
+	}
+}
+
+func TestExportStream(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	ctx := context.Background()
+	for _, format := range []string{"csv", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			bufferedDir, streamDir := t.TempDir(), t.TempDir()
+			buffered := exportCollectionTree(ctx, client, "users", exportConfig{maxDepth: -1, output: bufferedDir, format: format})
+			streamed := exportCollectionTree(ctx, client, "users", exportConfig{maxDepth: -1, output: streamDir, format: format, stream: true})
+
+			if len(streamed) != len(buffered) {
+				t.Fatalf("streamed %d collections, buffered %d", len(streamed), len(buffered))
+			}
+			for i := range buffered {
+				b, s := buffered[i], streamed[i]
+				if s.err != nil {
+					t.Fatalf("stream export %q error: %v", s.collection, s.err)
+				}
+				if s.collection != b.collection || s.docCount != b.docCount || s.fieldCount != b.fieldCount {
+					t.Errorf("streamed %+v, buffered %+v", s, b)
+				}
+				bRel, _ := filepath.Rel(bufferedDir, b.filePath)
+				sRel, _ := filepath.Rel(streamDir, s.filePath)
+				if bRel != sRel {
+					t.Errorf("streamed file %q, buffered %q", sRel, bRel)
+				}
+				// The reported count is the rows actually written.
+				if rows := countDataRows(t, s.filePath, format); rows != s.docCount {
+					t.Errorf("%q: docCount = %d, but the file has %d rows", s.collection, s.docCount, rows)
+				}
+			}
+		})
+	}
+}
+
+// countDataRows returns the number of documents in an exported CSV or JSON
+// Lines file, not counting the CSV header.
+func countDataRows(t *testing.T, filePath, format string) int {
+	t.Helper()
+	if format == "csv" {
+		return len(readCSV(t, filePath)) - 1
+	}
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	return strings.Count(string(b), "\n")
+}
+
+func TestExportJSONL(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	results := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir, format: "jsonl"})
+	if len(results) != 1 || results[0].err != nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	jsonlPath := filepath.Join(tmpDir, "users.jsonl")
+	if results[0].filePath != jsonlPath {
+		t.Errorf("filePath = %q, want %q", results[0].filePath, jsonlPath)
+	}
+	data, err := os.ReadFile(jsonlPath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", jsonlPath, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Errorf("expected 3 lines, got %d", len(lines))
+	}
+}
+
 func TestFormatValue_DocumentRef(t *testing.T) {
 	client := newTestClient(t)
 	ref := client.Doc("users/user1")
@@ -351,6 +730,43 @@ func TestFormatValue_DocumentRef(t *testing.T) {
 	}
 }
 
+func TestExportResolveRefs(t *testing.T) {
+	client := newTestClient(t)
+	seedFirestore(t, client)
+	ctx := context.Background()
+
+	holders := map[string]map[string]any{
+		"h1": {"owner": client.Doc("users/user1"), "team": []any{client.Doc("users/user2"), client.Doc("users/user3")}},
+		"h2": {"owner": client.Doc("users/deleted")},
+	}
+	for id, data := range holders {
+		if _, err := client.Collection("ref_holders").Doc(id).Set(ctx, data); err != nil {
+			t.Fatalf("failed to seed ref_holders/%s: %v", id, err)
+		}
+	}
+
+	tmpDir := t.TempDir()
+	cfg := exportConfig{output: tmpDir, format: "jsonl", resolveRefs: true, resolveField: "name"}
+	cfg.resolver = newRefResolver(client, cfg.resolveField, nil)
+	results := exportCollectionTree(ctx, client, "ref_holders", cfg)
+	if len(results) != 1 || results[0].err != nil {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "ref_holders.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{
+		`{"__path__":"ref_holders/h1","owner":"Alice","team":["Bob","Charlie"]}`,
+		`{"__path__":"ref_holders/h2","owner":null}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
 func TestRunExport_FullPipeline(t *testing.T) {
 	client := newTestClient(t)
 	seedFirestore(t, client)
@@ -359,6 +775,7 @@ func TestRunExport_FullPipeline(t *testing.T) {
 	err := runExport(exportConfig{
 		project:     testProject,
 		database:    "(default)",
+		databases:   []string{"(default)"},
 		collections: "users,products",
 		limit:       0,
 		childLimit:  0,
@@ -456,6 +873,7 @@ func TestImportRoundTrip(t *testing.T) {
 	err := runExport(exportConfig{
 		project:     testProject,
 		database:    "(default)",
+		databases:   []string{"(default)"},
 		collections: "users",
 		maxDepth:    0,
 		output:      exportDir,
@@ -670,6 +1088,7 @@ func TestImportSubCollectionRoundTrip(t *testing.T) {
 	err := runExport(exportConfig{
 		project:     testProject,
 		database:    "(default)",
+		databases:   []string{"(default)"},
 		collections: "users",
 		maxDepth:    -1,
 		output:      exportDir,
@@ -845,7 +1264,7 @@ func TestExportWithSanitize(t *testing.T) {
 	ctx := context.Background()
 
 	// First export with sanitization
-	results1 := exportCollectionTree(ctx, client, "users", 0, 0, 0, tmpDir1, false, san)
+	results1 := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir1, sanitizer: san})
 	if len(results1) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(results1))
 	}
@@ -898,7 +1317,7 @@ func TestExportWithSanitize(t *testing.T) {
 		"name": "firstName",
 	}}, 42)
 
-	results2 := exportCollectionTree(ctx, client, "users", 0, 0, 0, tmpDir2, false, san2)
+	results2 := exportCollectionTree(ctx, client, "users", exportConfig{output: tmpDir2, sanitizer: san2})
 	if results2[0].err != nil {
 		t.Fatalf("second export error: %v", results2[0].err)
 	}
@@ -1035,7 +1454,7 @@ func TestExportVirtualDocuments(t *testing.T) {
 	}
 
 	tmpDir := t.TempDir()
-	results := exportCollectionTree(ctx, client, "virtual_parents", 0, 0, -1, tmpDir, false, nil)
+	results := exportCollectionTree(ctx, client, "virtual_parents", exportConfig{maxDepth: -1, output: tmpDir})
 
 	// We should get 2 results: virtual_parents (0 docs) + virtual_parents/children
 	if len(results) < 2 {
@@ -1092,7 +1511,7 @@ func TestExportVirtualDocumentsNested(t *testing.T) {
 	})
 
 	tmpDir := t.TempDir()
-	results := exportCollectionTree(ctx, client, "virtual_parents", 0, 0, -1, tmpDir, false, nil)
+	results := exportCollectionTree(ctx, client, "virtual_parents", exportConfig{maxDepth: -1, output: tmpDir})
 
 	// Collect exported collection names
 	collections := map[string]bool{}
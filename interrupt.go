@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// exportContext returns the context an export runs under. It is cancelled on
+// SIGINT or SIGTERM and, if timeout is positive, once timeout has passed, so
+// in-flight queries stop and open files are closed. context.Cause reports
+// which of the two happened. After the first signal the default handling is
+// restored, so a second Ctrl-C quits immediately.
+func exportContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigs:
+			signal.Stop(sigs)
+			printWarn("Interrupted; stopping the export (press Ctrl-C again to quit immediately)")
+			cancelCause(fmt.Errorf("interrupted by %v", sig))
+		case <-ctx.Done():
+		}
+	}()
+
+	cancel := func() {
+		signal.Stop(sigs)
+		cancelCause(context.Canceled)
+	}
+	if timeout <= 0 {
+		return ctx, cancel
+	}
+	ctx, cancelTimeout := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("--timeout of %s exceeded", timeout))
+	return ctx, func() {
+		cancelTimeout()
+		cancel()
+	}
+}
+
+// reportStopped notes the collections left unexported when the run stopped
+// early, either after the first failure with --fail-fast or because ctx was
+// cancelled by a signal or --timeout.
+func reportStopped(ctx context.Context, skipped int) {
+	if skipped <= 0 {
+		return
+	}
+	if cause := context.Cause(ctx); cause != nil && cause != context.Canceled {
+		printInfo("Stopping: %v; %d collection(s) not exported", cause, skipped)
+		return
+	}
+	printInfo("Stopping after the first failure (--fail-fast); %d collection(s) not exported", skipped)
+}
+
+// partialNote marks collections in the run summary whose export was cut
+// short, so their file holds only the documents read until then.
+const partialNote = "__partial__"
+
+// stoppedResult reports a collection whose export was cut short by a signal or
+// --timeout after count documents were written to filePath. It still counts
+// as failed, with err saying why, but the file keeps those documents.
+func stoppedResult(displayPath string, depth, count, fieldCount int, filePath string, err error) exportResult {
+	printErrFor(displayPath, "Stopped exporting %q after %s docs: %v; kept them in %s", displayPath, fmtInt(count), err, filePath)
+	return exportResult{
+		collection: displayPath,
+		depth:      depth,
+		docCount:   count,
+		fieldCount: fieldCount,
+		filePath:   filePath,
+		err:        err,
+		partial:    true,
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportContext_Timeout(t *testing.T) {
+	ctx, cancel := exportContext(10 * time.Millisecond)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not cancelled after the timeout")
+	}
+	if cause := context.Cause(ctx); cause == nil || !strings.Contains(cause.Error(), "--timeout of 10ms exceeded") {
+		t.Errorf("context.Cause() = %v, want the --timeout reason", cause)
+	}
+}
+
+func TestExportContext_NoTimeout(t *testing.T) {
+	ctx, cancel := exportContext(0)
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("context has a deadline without --timeout")
+	}
+	cancel()
+	if ctx.Err() == nil {
+		t.Error("cancel() didn't cancel the context")
+	}
+	if cause := context.Cause(ctx); cause != context.Canceled {
+		t.Errorf("context.Cause() = %v, want context.Canceled", cause)
+	}
+}
@@ -4,31 +4,63 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path"
 	"path/filepath"
-	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"cloud.google.com/go/firestore"
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/time/rate"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 	"google.golang.org/genproto/googleapis/type/latlng"
+	"google.golang.org/grpc/status"
 )
 
 // defaultEmulatorProject is the project ID used when connecting to an emulator
 // without an explicit --project flag.
 const defaultEmulatorProject = "emulator-project"
 
+// emulatorHostEnv is the environment variable the Firestore client reads to
+// connect to an emulator instead of production.
+const emulatorHostEnv = "FIRESTORE_EMULATOR_HOST"
+
+// credentialsEnv is the environment variable Application Default Credentials
+// reads a key file path from.
+const credentialsEnv = "GOOGLE_APPLICATION_CREDENTIALS"
+
 type docRecord struct {
 	path string
 	data map[string]any
+	// createTime and updateTime come from the document snapshot and are
+	// written with --include-timestamps.
+	createTime time.Time
+	updateTime time.Time
+}
+
+// newDocRecord returns the record for snap with the given shaped data.
+func newDocRecord(snap *firestore.DocumentSnapshot, data map[string]any) docRecord {
+	return docRecord{
+		path:       documentPath(snap.Ref),
+		data:       data,
+		createTime: snap.CreateTime,
+		updateTime: snap.UpdateTime,
+	}
 }
 
 type exportResult struct {
@@ -38,56 +70,222 @@ type exportResult struct {
 	fieldCount int
 	filePath   string
 	err        error
+	partial    bool     // stopped early; filePath holds the documents read until then
+	database   string   // set when several databases are exported
+	parts      []string // every file written with --max-file-size; filePath is the first
+	files      int      // files written with --split-documents, one per document
+	sha256     string   // digest of filePath with --manifest or --checksums
+	hookErr    error    // --on-file failed for one of the files
+	partSums   []string // digests of parts, in order
 }
 
-func buildVersion() string {
-	info, ok := debug.ReadBuildInfo()
-	if !ok {
-		return "dev"
-	}
-	// Local builds have VCS info; prefer commit hash
-	var revision, dirty string
-	for _, s := range info.Settings {
-		switch s.Key {
-		case "vcs.revision":
-			revision = s.Value
-		case "vcs.modified":
-			if s.Value == "true" {
-				dirty = "-dirty"
-			}
-		}
+var (
+	cyan   = color.New(color.FgCyan, color.Bold).SprintFunc()
+	green  = color.New(color.FgGreen, color.Bold).SprintFunc()
+	yellow = color.New(color.FgYellow, color.Bold).SprintFunc()
+	red    = color.New(color.FgRed, color.Bold).SprintFunc()
+	bold   = color.New(color.Bold).SprintFunc()
+	faint  = color.New(color.Faint).SprintFunc()
+)
+
+// termMu serializes writes to stderr so that log lines and spinner frames from
+// concurrent exports don't interleave.
+var (
+	termMu    sync.Mutex
+	lineDirty bool // a spinner frame occupies the current line
+)
+
+// quiet is set by --quiet. It silences spinners and INFO/OK lines; warnings,
+// errors and the final summary are still printed.
+var quiet bool
+
+// verbosity is the number of times --verbose was given: verboseQueries logs
+// the query read for each collection, and verboseDocuments also every
+// document read. Call sites check it before building a message, so debug
+// logging costs nothing while it's off.
+var verbosity int
+
+// Levels of --verbose.
+const (
+	verboseQueries   = 1
+	verboseDocuments = 2
+)
+
+// logJSON is set by --log-format json. Log lines are then written as JSON
+// objects, one per line, and spinners and the summary table are disabled.
+var logJSON bool
+
+// Log levels used by the print helpers.
+const (
+	levelDebug = "debug"
+	levelInfo  = "info"
+	levelOK    = "ok"
+	levelWarn  = "warn"
+	levelError = "error"
+)
+
+// logger writes a single log line. collection is the collection the line is
+// about, or empty.
+type logger interface {
+	log(level, collection, msg string)
+}
+
+// textLogger writes the colored, human-readable lines printed by default.
+type textLogger struct{}
+
+func (textLogger) log(level, collection, msg string) {
+	switch level {
+	case levelDebug:
+		writeStderr(fmt.Sprintf("%s %s\n", faint("DEBUG"), msg))
+	case levelInfo:
+		writeStderr(fmt.Sprintf("%s  %s\n", cyan("INFO"), msg))
+	case levelOK:
+		writeStderr(fmt.Sprintf("  %s  %s\n", green("✓"), msg))
+	case levelWarn:
+		writeStderr(fmt.Sprintf("%s  %s\n", yellow("WARN"), msg))
+	default:
+		writeStderr(fmt.Sprintf("%s %s\n", red("ERROR"), msg))
 	}
-	if revision != "" {
-		if len(revision) > 7 {
-			revision = revision[:7]
-		}
-		return revision + dirty
+}
+
+// jsonLogger writes each line as a JSON object for log collectors.
+type jsonLogger struct{}
+
+// logEntry is a line written by jsonLogger.
+type logEntry struct {
+	Level      string `json:"level"`
+	Msg        string `json:"msg"`
+	Collection string `json:"collection,omitempty"`
+	TS         string `json:"ts"`
+}
+
+func (jsonLogger) log(level, collection, msg string) {
+	b, err := json.Marshal(logEntry{
+		Level:      level,
+		Msg:        msg,
+		Collection: collection,
+		TS:         time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		b = []byte(fmt.Sprintf(`{"level":%q,"msg":%q}`, levelError, err.Error()))
 	}
-	// go install module@version sets Main.Version (e.g. "v1.2.3")
-	if v := info.Main.Version; v != "" && v != "(devel)" {
-		return v
+	writeStderr(string(b) + "\n")
+}
+
+// stderrLog is the logger used by the print helpers; see setLogFormat.
+var stderrLog logger = textLogger{}
+
+// setLogFormat selects the logger for --log-format ("text" or "json").
+func setLogFormat(format string) error {
+	switch format {
+	case "text":
+		stderrLog, logJSON = textLogger{}, false
+	case "json":
+		// JSON messages must not carry terminal color codes.
+		color.NoColor = true
+		stderrLog, logJSON = jsonLogger{}, true
+	default:
+		return fmt.Errorf("invalid --log-format %q: must be text or json", format)
 	}
-	return "dev"
+	return nil
 }
 
-var (
-	cyan  = color.New(color.FgCyan, color.Bold).SprintFunc()
-	green = color.New(color.FgGreen, color.Bold).SprintFunc()
-	red   = color.New(color.FgRed, color.Bold).SprintFunc()
-	bold  = color.New(color.Bold).SprintFunc()
-	faint = color.New(color.Faint).SprintFunc()
-)
+// writeStderr writes s to stderr, first clearing any spinner frame on the line.
+func writeStderr(s string) {
+	termMu.Lock()
+	defer termMu.Unlock()
+	if lineDirty {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		lineDirty = false
+	}
+	fmt.Fprint(os.Stderr, s)
+}
 
 func printInfo(format string, a ...any) {
-	fmt.Fprintf(os.Stderr, "%s  %s\n", cyan("INFO"), fmt.Sprintf(format, a...))
+	printInfoFor("", format, a...)
 }
 
 func printOK(format string, a ...any) {
-	fmt.Fprintf(os.Stderr, "  %s  %s\n", green("✓"), fmt.Sprintf(format, a...))
+	printOKFor("", format, a...)
+}
+
+// printWarn reports a problem that doesn't fail the export, such as data that
+// was changed on the way out. Unlike info lines it isn't silenced by --quiet.
+func printWarn(format string, a ...any) {
+	stderrLog.log(levelWarn, "", fmt.Sprintf(format, a...))
 }
 
 func printErr(format string, a ...any) {
-	fmt.Fprintf(os.Stderr, "%s %s\n", red("ERROR"), fmt.Sprintf(format, a...))
+	printErrFor("", format, a...)
+}
+
+// printInfoFor, printOKFor and printErrFor are the print helpers for lines
+// about a single collection, which JSON logs report in the collection field.
+func printInfoFor(collection, format string, a ...any) {
+	if quiet {
+		return
+	}
+	stderrLog.log(levelInfo, collection, fmt.Sprintf(format, a...))
+}
+
+func printOKFor(collection, format string, a ...any) {
+	if quiet {
+		return
+	}
+	stderrLog.log(levelOK, collection, fmt.Sprintf(format, a...))
+}
+
+func printErrFor(collection, format string, a ...any) {
+	stderrLog.log(levelError, collection, fmt.Sprintf(format, a...))
+}
+
+// printDebugFor writes a --verbose line about collection. Callers check
+// verbosity first.
+func printDebugFor(collection, format string, a ...any) {
+	stderrLog.log(levelDebug, collection, fmt.Sprintf(format, a...))
+}
+
+// debugDocument logs the ID and raw field names of a document read from
+// displayPath, with --verbose at verboseDocuments.
+func debugDocument(displayPath string, snap *firestore.DocumentSnapshot) {
+	data := snap.Data()
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	printDebugFor(displayPath, "Read %s: %d field(s): %s", snap.Ref.ID, len(keys), strings.Join(keys, ", "))
+}
+
+// printText writes report output such as blank lines and lists. In JSON mode
+// non-blank lines are logged at info level instead.
+func printText(format string, a ...any) {
+	s := fmt.Sprintf(format, a...)
+	if !logJSON {
+		writeStderr(s)
+		return
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			printInfo("%s", line)
+		}
+	}
+}
+
+// printDone writes the final line of a command, marked ✓ or FAILED. Unlike
+// the other helpers it is printed with --quiet.
+func printDone(ok bool, format string, a ...any) {
+	msg := fmt.Sprintf(format, a...)
+	switch {
+	case logJSON && ok:
+		stderrLog.log(levelOK, "", msg)
+	case logJSON:
+		stderrLog.log(levelError, "", msg)
+	case ok:
+		writeStderr(fmt.Sprintf("\n%s %s\n", green("✓"), msg))
+	default:
+		writeStderr(fmt.Sprintf("\n%s %s\n", red("FAILED"), msg))
+	}
 }
 
 // documentPath extracts the document path from a Firestore DocumentRef.
@@ -102,6 +300,27 @@ func documentPath(ref *firestore.DocumentRef) string {
 	return full
 }
 
+// fmtBytes formats a byte count with a decimal unit, e.g. 340 KB or 1.2 MB:
+// one decimal below 10 and none above.
+func fmtBytes(n int64) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d B", n)
+	}
+	v := float64(n)
+	unit := ""
+	for _, u := range []string{"KB", "MB", "GB", "TB", "PB"} {
+		v /= 1000
+		unit = u
+		if v < 999.5 {
+			break
+		}
+	}
+	if v < 9.95 {
+		return fmt.Sprintf("%.1f %s", v, unit)
+	}
+	return fmt.Sprintf("%.0f %s", v, unit)
+}
+
 // fmtInt formats an integer with comma thousands separators.
 func fmtInt(n int) string {
 	s := strconv.Itoa(n)
@@ -124,15 +343,46 @@ func fmtInt(n int) string {
 
 // spinner provides a simple animated spinner for terminal output.
 type spinner struct {
-	mu     sync.Mutex
-	suffix string
-	done   chan struct{}
+	mu      sync.Mutex
+	suffix  string
+	done    chan struct{}
+	enabled bool
 }
 
 var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 
-func newSpinner(suffix string) *spinner {
-	return &spinner{suffix: suffix, done: make(chan struct{})}
+// stderrIsTerminal reports whether stderr is a terminal. Spinner frames are
+// redrawn with \r, which only works there; in a log file or pipe they would
+// pile up, so spinners stay off.
+var stderrIsTerminal = isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd())
+
+// disableColorsIfNeeded turns off colored output unless stderr, where all
+// output goes, is a terminal. The color package only checks stdout. NO_COLOR
+// (https://no-color.org) and TERM=dumb are honored as well.
+func disableColorsIfNeeded() {
+	if !stderrIsTerminal || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		color.NoColor = true
+	}
+}
+
+// newSpinner creates a spinner. A disabled spinner ignores Start and Stop, so
+// callers can keep a single code path when progress output is suppressed.
+// Spinners are always disabled with --quiet and --log-format json, and when
+// stderr isn't a terminal.
+func newSpinner(suffix string, enabled bool) *spinner {
+	return &spinner{suffix: suffix, done: make(chan struct{}), enabled: enabled && !quiet && !logJSON && stderrIsTerminal}
+}
+
+// progressBar renders done out of total as "[=====>    ] 42% (4,200/10,000)".
+func progressBar(done, total int) string {
+	const width = 20
+	done = min(done, total)
+	filled := done * width / total
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	return fmt.Sprintf("[%s] %d%% (%s/%s)", bar, done*100/total, fmtInt(done), fmtInt(total))
 }
 
 func (s *spinner) SetSuffix(suffix string) {
@@ -142,6 +392,9 @@ func (s *spinner) SetSuffix(suffix string) {
 }
 
 func (s *spinner) Start() {
+	if !s.enabled {
+		return
+	}
 	go func() {
 		i := 0
 		for {
@@ -152,7 +405,10 @@ func (s *spinner) Start() {
 				s.mu.Lock()
 				suffix := s.suffix
 				s.mu.Unlock()
+				termMu.Lock()
 				fmt.Fprintf(os.Stderr, "\r\033[K%s %s", cyan(spinnerFrames[i%len(spinnerFrames)]), suffix)
+				lineDirty = true
+				termMu.Unlock()
 				i++
 				time.Sleep(80 * time.Millisecond)
 			}
@@ -161,21 +417,60 @@ func (s *spinner) Start() {
 }
 
 func (s *spinner) Stop() {
+	if !s.enabled {
+		return
+	}
 	close(s.done)
+	termMu.Lock()
 	fmt.Fprintf(os.Stderr, "\r\033[K")
+	lineDirty = false
+	termMu.Unlock()
+}
+
+// Exit codes, besides 0 for success.
+const (
+	exitFailure = 1 // nothing succeeded, or the command couldn't run
+	exitPartial = 2 // some collections (or documents) succeeded and others failed
+)
+
+// partialError is returned by a command when only part of its work failed.
+type partialError struct{ err error }
+
+func (e *partialError) Error() string { return e.err.Error() }
+func (e *partialError) Unwrap() error { return e.err }
+
+// exitCode returns the process exit code for an error returned by a command.
+func exitCode(err error) int {
+	var pe *partialError
+	if errors.As(err, &pe) {
+		return exitPartial
+	}
+	return exitFailure
 }
 
 func main() {
+	disableColorsIfNeeded()
+
+	if err := newRootCmd().Execute(); err != nil {
+		printText("\n")
+		printErr("%s", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// newRootCmd returns the firestore2csv command with its subcommands and flags.
+func newRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "firestore2csv",
 		Short: "Export and import Firestore collections as CSV files",
 		Long: `Export and import Firestore collections as CSV files.
 
 Use 'firestore2csv export' to export collections to CSV, or
-'firestore2csv import' to import CSV files into Firestore.
+'firestore2csv import' to import CSV files into Firestore. Use
+'firestore2csv count' to print document counts without exporting.
 
 Run 'firestore2csv <command> --help' for details on each command.`,
-		Version:       buildVersion(),
+		Version:       versionString(currentBuild()),
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
@@ -184,7 +479,31 @@ Run 'firestore2csv <command> --help' for details on each command.`,
 	pf := rootCmd.PersistentFlags()
 	pf.StringP("project", "p", "", "GCP project ID")
 	pf.StringP("emulator", "e", "", "Firestore emulator host (e.g. localhost:8686)")
-	pf.StringP("database", "d", "(default)", "Firestore database name")
+	pf.StringP("database", "d", "(default)", "Firestore database name (export accepts a comma-separated list)")
+	pf.String("credentials", "", "Service account key file (default: Application Default Credentials)")
+	pf.String("endpoint", "", "Firestore API endpoint as host:port, e.g. for private access (default: Google's)")
+	pf.Bool("no-auth", false, "Connect without credentials, for --endpoint or --emulator setups that don't need them")
+	pf.BoolP("quiet", "q", false, "Suppress progress output; only errors and the final summary are printed")
+	pf.CountP("verbose", "v", "Log each collection's query; repeat (-vv) to log every document read")
+	pf.String("log-format", "text", "Log output format: text or json")
+	pf.String("config", "", "YAML file of flag values; flags on the command line override it")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// sanitize has a --config of its own, which shadows this one.
+		config := cmd.Flags().Lookup("config")
+		if path := config.Value.String(); path != "" && config == cmd.Root().PersistentFlags().Lookup("config") {
+			if err := loadConfigFile(cmd, path); err != nil {
+				return err
+			}
+		}
+		quiet, _ = cmd.Flags().GetBool("quiet")
+		verbosity, _ = cmd.Flags().GetCount("verbose")
+		if quiet && verbosity > 0 {
+			return fmt.Errorf("--verbose and --quiet can't be combined")
+		}
+		logFormat, _ := cmd.Flags().GetString("log-format")
+		return setLogFormat(logFormat)
+	}
+	rootCmd.SetGlobalNormalizationFunc(normalizeFlagName)
 
 	// Export subcommand
 	exportCmd := &cobra.Command{
@@ -201,21 +520,113 @@ files are organized in a directory structure mirroring the collection hierarchy
 (e.g. users.csv, users/orders.csv). Use --depth to limit recursion depth.
 
 Complex types (arrays, maps) are stored as JSON strings. Timestamps use
-RFC3339 format. Authentication uses Google Application Default Credentials.`,
+RFC3339 format. Authentication uses Google Application Default Credentials.
+
+Use --format jsonl to write newline-delimited JSON instead (one object per
+document, with the document path under the __path__ key). Arrays and maps are
+kept as native JSON values.`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE:          run,
 	}
 
 	ef := exportCmd.Flags()
-	ef.StringP("collections", "c", "", "Comma-separated collection names (default: all top-level)")
+	ef.StringP("collections", "c", "", "Comma-separated collection names or glob patterns (default: all top-level)")
+	exportCmd.RegisterFlagCompletionFunc("collections", completeCollections)
+	ef.String("collections-file", "", "File listing collection names or glob patterns, one per line (# starts a comment)")
+	ef.String("exclude", "", "Comma-separated collection names to skip when exporting all collections")
+	ef.String("ids", "", "Comma-separated document IDs to export from each collection instead of all documents")
+	ef.String("id-start", "", "Only export top-level documents whose ID sorts at or after this one")
+	ef.String("id-end", "", "Only export top-level documents whose ID sorts at or before this one")
+	ef.String("id-codec", "", "Format of time-sortable document IDs for --id-start-time/--id-end-time: ulid or ksuid")
+	ef.String("id-start-time", "", "Only export top-level documents whose ID was generated at or after this RFC3339 time")
+	ef.String("id-end-time", "", "Only export top-level documents whose ID was generated at or before this RFC3339 time")
+	ef.String("collection-group", "", "Export every collection with this ID, under any parent, into one file")
 	ef.IntP("limit", "l", 0, "Max documents per top-level collection (0 = all)")
+	ef.String("limit-per", "", `Per-collection limits overriding --limit, e.g. "logs=100,events=500"`)
 	ef.Int("child-limit", 0, "Max documents per sub-collection (0 = all)")
 	ef.Int("depth", -1, "Max sub-collection depth (-1 = unlimited, 0 = top-level only)")
-	ef.StringP("output", "o", ".", "Output directory for CSV files")
+	ef.StringArray("where", nil, `Filter top-level documents: "field op value" (repeatable, ANDed)`)
+	ef.String("dedup-by", "", "Keep one top-level document per value of this field, dropping the other duplicates")
+	ef.String("dedup-keep", dedupKeepLast, "Which duplicate --dedup-by keeps, in read order: first, last")
+	ef.String("missing-field", "", "Only export top-level documents without this field (filtered after reading them all)")
+	ef.String("explode", "", "Write one row per element of this array field, with the element's fields as columns")
+	ef.String("schema-file", "", "JSON array of column names that sets the CSV header and its order")
+	ef.Bool("schema-strict", true, "With --schema-file, drop fields it doesn't list (false = append them, sorted)")
+	ef.String("modified-since", "", "Only export top-level documents whose --modified-field is after this RFC3339 timestamp")
+	ef.String("modified-field", "", "Document field holding the last update time, used by --modified-since")
+	ef.String("fields", "", "Comma-separated fields to export, in column order (default: union of all fields)")
+	ef.String("exclude-fields", "", "Comma-separated fields to leave out of the output, applied after --fields")
+	ef.String("order-by", "", `Document order, e.g. "createdAt:desc,name" (default: document ID)`)
+	ef.StringP("output", "o", ".", "Output directory for exported files, a gs://bucket/prefix URL, or - for stdout")
+	ef.StringP("format", "f", "csv", "Output format: csv, tsv, jsonl, parquet")
+	ef.String("file-prefix", "", "Text added before the collection name in output file names (e.g. prod_)")
+	ef.Bool("sanitize-names", true, "Replace path separators and control characters in collection names with _ in file names")
+	ef.String("file-suffix", "", "Text added after the collection name in output file names, before the extension")
+	ef.String("compression", compressionNone, "Compress output files: none, gzip (adds .gz) or zstd (adds .zst)")
+	ef.String("max-file-size", "", `Split each output file into numbered parts of at most this size before compression, e.g. "1GB"`)
+	ef.Bool("gzip", false, "Shorthand for --compression gzip")
+	ef.String("delimiter", ",", `CSV field delimiter, a single character (use \t for tab)`)
+	ef.Bool("no-header", false, "Omit the CSV header row")
+	ef.Bool("bom", false, "Start CSV files with a UTF-8 byte order mark (for Excel)")
+	ef.Bool("quote-all", false, "Quote every CSV field, not only those that need it")
+	ef.String("line-ending", "lf", "Row terminator in CSV and TSV files: lf or crlf (for Windows tools)")
+	ef.String("time-format", "rfc3339nano", "Timestamp format: a Go layout or one of rfc3339, rfc3339nano, date, datetime, unix (or epoch-s), epoch-ms")
+	ef.String("null-value", "", `CSV cell written for null and missing fields (e.g. \N or NULL)`)
+	ef.String("array-format", "json", "CSV array cells: json, or delimited to join scalar arrays with --array-delimiter")
+	ef.String("array-delimiter", "|", "Separator between array elements with --array-format delimited")
+	ef.String("number-format", numberFormatNative, "CSV numbers: native, always-float (5 becomes 5.0), or always-int (5.0 becomes 5)")
+	ef.Bool("group-numbers", false, "Write CSV numbers with thousands separators, e.g. 1,234,567")
+	ef.String("locale", "en", "With --group-numbers, the locale whose separators are used, e.g. de for 1.234.567,5")
+	ef.Int("float-precision", -1, "Decimal places for floats (-1 = as many as needed to round-trip)")
+	ef.String("ref-format", refFormatPath, "Reference values: path (full resource name), relative (path below documents/), or id")
+	ef.String("bytes-format", bytesFormatBase64, "Bytes values: base64, hex, or skip (empty cell, null in JSON)")
+	ef.Bool("resolve-refs", false, "Replace top-level reference fields with the referenced documents (reads each one)")
+	ef.String("resolve-ref-field", "", "With --resolve-refs, inline only this field of the referenced documents")
+	ef.Int("max-cell-size", 0, "Truncate CSV cells longer than this many bytes (0 = no limit)")
+	ef.Bool("pretty-json", false, "Indent JSON Lines objects over several lines, for debugging (jsonl only)")
+	ef.Bool("bigquery-json", false, "Write JSON Lines that bq load accepts, with a <collection>.bqschema.json table schema")
 	ef.Bool("with-types", false, "Include __fs_types__ column with Firestore type metadata")
+	ef.Bool("include-timestamps", false, "Include __create_time__ and __update_time__ columns from document metadata")
+	ef.Bool("path-columns", false, "Add __parent_collection__ and __parent_id__ columns for each parent of sub-collection documents")
+	ef.Bool("emit-schema", false, "Write a <collection>.schema.json file with inferred field types")
+	ef.Bool("validate", false, "Report fields whose values have more than one type across a collection's documents")
+	ef.Bool("flatten", false, "Expand nested maps into dotted columns (e.g. address.city)")
+	ef.Bool("flatten-arrays", false, "Expand arrays into indexed columns (e.g. tags.0, tags.1), up to --max-array-index")
+	ef.Int("max-array-index", 9, "With --flatten-arrays, the highest array index written as a column; later elements are dropped")
+	ef.Bool("geopoint-columns", false, "Split GeoPoint fields into numeric <field>.lat and <field>.lng columns")
+	ef.String("rename", "", `Rename columns with oldName:newName pairs, e.g. "userId:user_id,createdAt:created_at"`)
+	ef.StringArray("compute", nil, `Add a column computed per document: "name=expression", e.g. "fullName=firstName + ' ' + lastName" (repeatable)`)
+	ef.String("on-collision", onCollisionError, "What to do when fields map to the same column: error, suffix")
+	ef.Bool("split-documents", false, "Write each document to its own JSON file at its path, e.g. users/alice.json")
+	ef.Bool("single-file", false, "Write every collection to one CSV file with a __collection__ column")
+	ef.Bool("stream", false, "Write rows as documents are read instead of buffering each collection in memory")
+	ef.String("hash-fields", "", "Comma-separated fields whose values are replaced by their SHA-256 hex digest")
+	ef.String("redact-fields", "", `Comma-separated fields whose values are replaced by "***"`)
+	ef.String("cast", "", `Convert string values of fields to another type with field:type pairs (int, float, bool), e.g. "price:float,active:bool"`)
 	ef.String("sanitize", "", "Sanitize fields: inline key=type pairs or path to YAML config file")
 	ef.Int64("seed", 0, "Random seed for sanitization (0 = random, non-zero = deterministic)")
+	ef.IntP("concurrency", "j", 1, "Number of top-level collections to export in parallel")
+	ef.Int("partitions", 0, "Read each top-level collection with up to N concurrent partitioned queries")
+	ef.Bool("fail-fast", false, "Stop exporting at the first collection that fails")
+	ef.Int("page-size", 0, "Read documents in queries of at most this many documents (0 = one query per collection)")
+	ef.Int("rate-limit", 0, "Read at most this many documents per second across the export (0 = unlimited)")
+	ef.Bool("empty-files", false, "Write a file with just the header for collections without documents, instead of skipping them")
+	ef.Bool("append", false, "Add rows to existing output files instead of overwriting them; CSV headers must match")
+	ef.String("baseline", "", "Directory of a previous export to compare with: unchanged fields are left empty and a __changed__ column lists the others")
+	ef.Bool("resume", false, "Checkpoint progress and continue an interrupted export from its .cursor files")
+	ef.Int("checkpoint-every", 1000, "Documents written between --resume checkpoints")
+	ef.String("summary-format", "table", "Run summary: table (stderr), csv or tsv (stdout, for piping), or none")
+	ef.Bool("summary-totals", true, "End the summary table with the total docs and number of collections")
+	ef.Bool("manifest", false, "Write a manifest.json summarizing the run to the output directory")
+	ef.Bool("checksums", false, "Write a <file>.sha256 next to every output file, in sha256sum format")
+	ef.String("errors-file", "", "Write the failed collections to this file as JSON, with their gRPC status codes")
+	ef.String("on-file", "", "Command to run after each file is written; {file}, {collection} and {database} are replaced")
+	ef.String("on-complete", "", "Command to run once the export is done; {output} and {failed} (failed collections) are replaced")
+	ef.Bool("dry-run", false, "Read collections and report document and field counts without writing files")
+	ef.Int("preview", 0, "Print the first N documents of each collection as a table instead of writing files")
+	ef.Int("max-retries", 3, "Retries per query on transient Firestore errors (0 = fail immediately)")
+	ef.Duration("timeout", 0, "Abort the export after this long, e.g. 30m (0 = no limit)")
 
 	// Import subcommand
 	importCmd := &cobra.Command{
@@ -262,51 +673,325 @@ Special columns (__path__, __fs_types__) are never modified.`,
 	_ = sanitizeCmd.MarkFlagRequired("config")
 	_ = sanitizeCmd.MarkFlagRequired("output")
 
+	// Count subcommand
+	countCmd := &cobra.Command{
+		Use:   "count",
+		Short: "Print the number of documents in each collection",
+		Long: `Print the number of documents in each top-level collection.
+
+Counts come from Firestore aggregation queries, so no documents are read and
+counting costs far less than an export. Sub-collections aren't counted.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runCountCmd,
+	}
+
+	cf := countCmd.Flags()
+	cf.StringP("collections", "c", "", "Comma-separated collection names or glob patterns (default: all top-level)")
+	countCmd.RegisterFlagCompletionFunc("collections", completeCollections)
+	cf.String("collections-file", "", "File listing collection names or glob patterns, one per line (# starts a comment)")
+	cf.String("exclude", "", "Comma-separated collection names to skip when counting all collections")
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version, git commit and build date",
+		Long: `Print the version, git commit and build date of this binary, the same
+as 'firestore2csv --version'. Include it in bug reports.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := fmt.Fprintf(cmd.OutOrStdout(), "%s version %s\n", rootCmd.Name(), rootCmd.Version)
+			return err
+		},
+	}
+
+	completionCmd := &cobra.Command{
+		Use:   "completion [bash|zsh|fish|powershell]",
+		Short: "Generate a shell completion script",
+		Long: `Generate a shell completion script, written to stdout.
+
+Load it in the current shell, e.g. for bash:
+
+  source <(firestore2csv completion bash)
+
+or install it for every new shell, e.g. for zsh:
+
+  firestore2csv completion zsh > "${fpath[1]}/_firestore2csv"
+
+Besides commands and flags, --collections completes the collection names of
+the database once --project or --emulator is on the command line.`,
+		ValidArgs: completionShells,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return writeCompletion(rootCmd, args[0], cmd.OutOrStdout())
+		},
+	}
+
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(sanitizeCmd)
-
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "\n%s %s\n", red("ERROR"), err)
-		os.Exit(1)
-	}
+	rootCmd.AddCommand(countCmd)
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(versionCmd)
+	return rootCmd
 }
 
 type exportConfig struct {
 	project     string
-	database    string
+	database    string   // the --database list; runExport sets each database in turn
+	databases   []string // database, split
 	emulator    string
+	emulatorEnv bool // emulator came from FIRESTORE_EMULATOR_HOST, not --emulator
+	credentials string
+	endpoint    endpointOptions // --endpoint and --no-auth
 	collections string
+	collFile    string // --collections-file; an alternative to collections
+	exclude     []string
+	group       string   // --collection-group; replaces collections and exclude
+	ids         []string // --ids; read instead of the whole top-level collections
+	idStart     string   // --id-start and --id-end; see applyIDRange
+	idEnd       string
+	idCodec     string // --id-codec; top-level documents with other IDs are skipped
 	limit       int
+	limitPer    map[string]int // --limit-per; overrides limit for the named collections
 	childLimit  int
 	maxDepth    int
 	output      string
+	gcs         *gcsOutput      // set by runExport when output is a gs:// URL
+	combined    *combinedOutput // set by runExport with --single-file
+	compression string          // --compression codec; "" = none
+	timeFormat  string          // resolved Go layout, timeFormatUnix or timeFormatMillis
+	nullValue   string
+	arrayDelim  string // set with --array-format delimited
+	numberFmt   string
+	grouping    *numberGrouping // --group-numbers; nil when off
+	roundFloats bool            // set with --float-precision; floatPrec holds the decimal places
+	floatPrec   int
+	refFormat   string
+	bytesFormat string
+	maxCellSize int
+	emitSchema  bool
+	maxRetries  int
+	timeout     time.Duration // --timeout; 0 = no limit
+	pageSize    int           // 0 = read each query in one go
+	limiter     *rate.Limiter // --rate-limit, shared by all reads; nil = unlimited
+	dryRun      bool
+	preview     int // --preview; implies dryRun, see printPreview
+	manifest    bool
+	checksums   *checksumSet // set by runExport with --manifest or --checksums
+	errorsFile  string       // --errors-file; see writeErrorsFile
+	onFile      *commandHook // --on-file; nil when unset, see runFileHook
+	onComplete  *commandHook // --on-complete; see runCompleteHook
+	summary     string       // --summary-format
+	totals      bool         // --summary-totals; see printSummaryTable
+	format      string
+	filePrefix  string // --file-prefix and --file-suffix; see outputName
+	fileSuffix  string
+	dbPrefix    string // set by runExport when several databases are exported
+	delimiter   rune
+	noHeader    bool
+	bom         bool
+	quoteAll    bool
+	crlf        bool // --line-ending crlf
+	where       []whereFilter
+	fields      []string
+	orderBy     []orderClause
+	dedupBy     string // --dedup-by; dedupKeep picks the first or last duplicate
+	dedupKeep   string
+	missing     string // --missing-field; only top-level documents without it are kept
+	explode     string // --explode; see explodeRows
 	withTypes   bool
+	prettyJSON  bool
+	bigQuery    bool // --bigquery-json; see bigQueryRecord
 	sanitizer   *sanitizer
+	flatten     bool
+	geoColumns  bool
+	onCollision string
+	rename      map[string]string // --rename: column → new name
+	compute     []computedColumn  // --compute; see computeColumns
+	stream      bool
+	concurrency int
+	partitions  int // --partitions; see partitionedScan
+	failFast    bool
+	noSpinner   bool // set internally when per-collection spinners would clash
+
+	// includeTimestamps adds the snapshot create and update times as columns.
+	includeTimestamps bool
+
+	// pathColumns adds the collection and ID of every parent document as
+	// columns. parentLevels is the number of parents of the collection being
+	// exported, set per sub-collection by exportSubCollectionTree.
+	pathColumns  bool
+	parentLevels int
+
+	// resolveRefs replaces references with the documents they point to;
+	// exportDatabase sets resolver, which reads them. resolveField picks the
+	// field of those documents to inline.
+	resolveRefs  bool
+	resolveField string
+	resolver     *refResolver
+
+	// columnOrder holds the --schema-file columns, which replace the field
+	// union as the header; see schemaHeader.
+	columnOrder  []string
+	schemaStrict bool
+
+	// excludeFields holds the --exclude-fields columns, which shapeRecord drops.
+	excludeFields map[string]bool
+
+	// hashFields and redactFields hold the fields masked by maskFields.
+	hashFields   map[string]bool
+	redactFields map[string]bool
+
+	// casts maps the --cast fields to the type castFields converts them to.
+	casts map[string]string
+
+	// flattenArrays expands arrays into columns indexed up to maxArrayIndex;
+	// see columnSet.indexed.
+	flattenArrays bool
+	maxArrayIndex int
+
+	// sanitizeNames makes collection names safe in file names; see outputName.
+	sanitizeNames bool
+
+	// singleFile writes every collection to one CSV; see combinedOutput.
+	singleFile bool
+
+	// splitDocuments writes a JSON file per document; see documentWriter.
+	splitDocuments bool
+
+	// append adds rows to existing output files; see openAppendWriter.
+	append bool
+
+	// emptyFiles writes an output file for collections without documents;
+	// see writeEmptyCollection.
+	emptyFiles bool
+
+	// baseline is the directory of a previous export to write only the
+	// changes from; see baselineWriter.
+	baseline string
+
+	// resume checkpoints top-level exports so an interrupted run can continue.
+	resume          bool
+	checkpointEvery int
+
+	// validate reports fields with mixed types; see typeReport.
+	validate   bool
+	validation *typeReport // set by runExport with --validate
+
+	// maxFileSize splits output files into parts; see splitWriter.
+	maxFileSize int64
+
+	// checksumFiles writes a .sha256 file next to every output file; see
+	// checksumSet.
+	checksumFiles bool
+}
+
+// validSummaryFormats enumerates the values accepted by --summary-format.
+var validSummaryFormats = map[string]bool{
+	"table": true, "csv": true, "tsv": true, "none": true,
+}
+
+var validFormats = map[string]bool{
+	"csv": true, "tsv": true, "jsonl": true, "parquet": true,
+}
+
+// parseDelimiter parses the --delimiter flag value into a single rune.
+// The two-character escape `\t` is accepted as a tab.
+func parseDelimiter(raw string) (rune, error) {
+	if raw == `\t` {
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(raw) != 1 {
+		return 0, fmt.Errorf("invalid --delimiter %q: must be exactly one character", raw)
+	}
+	r, _ := utf8.DecodeRuneInString(raw)
+	if r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid --delimiter %q: cannot be a quote, newline, or invalid character", raw)
+	}
+	return r, nil
 }
 
 // validateConnectionFlags ensures at least one of --project or --emulator is provided.
+// Without --emulator, an emulator host already set in FIRESTORE_EMULATOR_HOST is used.
 func validateConnectionFlags(cmd *cobra.Command) (project, database, emulator string, err error) {
 	f := cmd.Flags()
 	project, _ = f.GetString("project")
 	emulator, _ = f.GetString("emulator")
 	database, _ = f.GetString("database")
 
+	if emulator == "" {
+		emulator = os.Getenv(emulatorHostEnv)
+	}
+
 	if project == "" && emulator == "" {
 		return "", "", "", fmt.Errorf("at least one of --project or --emulator must be provided")
 	}
 	return project, database, emulator, nil
 }
 
+// normalizeFlagName maps flag aliases to their canonical names: --key-file is
+// accepted for --credentials.
+func normalizeFlagName(f *pflag.FlagSet, name string) pflag.NormalizedName {
+	if name == "key-file" {
+		name = "credentials"
+	}
+	return pflag.NormalizedName(name)
+}
+
+// credentialsFromFlags returns the validated --credentials key file path, or ""
+// to use Application Default Credentials.
+func credentialsFromFlags(cmd *cobra.Command) (string, error) {
+	keyFile, _ := cmd.Flags().GetString("credentials")
+	if keyFile == "" {
+		return "", nil
+	}
+	if err := validateCredentialsFile(keyFile); err != nil {
+		return "", fmt.Errorf("invalid --credentials: %w", err)
+	}
+	if env := os.Getenv(credentialsEnv); env != "" && filepath.Clean(env) != filepath.Clean(keyFile) {
+		return "", fmt.Errorf("--credentials %q conflicts with %s=%q; unset one of them", keyFile, credentialsEnv, env)
+	}
+	return keyFile, nil
+}
+
+// validateCredentialsFile checks that keyFile is a readable service account key
+// file before any connection is attempted.
+func validateCredentialsFile(keyFile string) error {
+	b, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	var key struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return fmt.Errorf("%s is not a JSON key file: %w", keyFile, err)
+	}
+	if key.Type != "service_account" {
+		return fmt.Errorf("%s is not a service account key (type %q)", keyFile, key.Type)
+	}
+	return nil
+}
+
+// clientOptions returns the Google API client options for the given key file.
+func clientOptions(credentials string) []option.ClientOption {
+	if credentials == "" {
+		return nil
+	}
+	return []option.ClientOption{option.WithAuthCredentialsFile(option.ServiceAccount, credentials)}
+}
+
 // newFirestoreClient creates a Firestore client, handling emulator configuration.
-func newFirestoreClient(ctx context.Context, project, database, emulator string) (*firestore.Client, error) {
+// An empty credentials path uses Application Default Credentials.
+func newFirestoreClient(ctx context.Context, project, database, emulator, credentials string, endpoint endpointOptions) (*firestore.Client, error) {
 	if emulator != "" {
-		os.Setenv("FIRESTORE_EMULATOR_HOST", emulator)
+		os.Setenv(emulatorHostEnv, emulator)
 		if project == "" {
 			project = defaultEmulatorProject
 		}
 	}
-	return firestore.NewClientWithDatabase(ctx, project, database)
+	opts := append(clientOptions(credentials), endpoint.clientOptions()...)
+	return firestore.NewClientWithDatabase(ctx, project, database, opts...)
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -314,149 +999,1125 @@ func run(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	credentials, err := credentialsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	endpoint, err := endpointFromFlags(cmd, emulator, credentials)
+	if err != nil {
+		return err
+	}
+
+	databases, err := parseDatabases(database)
+	if err != nil {
+		return err
+	}
 
 	f := cmd.Flags()
 	collections, _ := f.GetString("collections")
+	collectionsFile, _ := f.GetString("collections-file")
+	excludeFlag, _ := f.GetString("exclude")
+	collectionGroup, _ := f.GetString("collection-group")
+	idsFlag, _ := f.GetString("ids")
+	idStart, _ := f.GetString("id-start")
+	idEnd, _ := f.GetString("id-end")
+	idCodec, _ := f.GetString("id-codec")
+	idStartTime, _ := f.GetString("id-start-time")
+	idEndTime, _ := f.GetString("id-end-time")
 	limit, _ := f.GetInt("limit")
+	limitPerFlag, _ := f.GetString("limit-per")
 	childLimit, _ := f.GetInt("child-limit")
 	maxDepth, _ := f.GetInt("depth")
 	output, _ := f.GetString("output")
+	format, _ := f.GetString("format")
+	filePrefix, _ := f.GetString("file-prefix")
+	fileSuffix, _ := f.GetString("file-suffix")
+	sanitizeNames, _ := f.GetBool("sanitize-names")
+	compression, _ := f.GetString("compression")
+	maxFileSizeFlag, _ := f.GetString("max-file-size")
+	gzip, _ := f.GetBool("gzip")
+	delimiterFlag, _ := f.GetString("delimiter")
+	noHeader, _ := f.GetBool("no-header")
+	bom, _ := f.GetBool("bom")
+	quoteAll, _ := f.GetBool("quote-all")
+	lineEnding, _ := f.GetString("line-ending")
+	timeFormat, _ := f.GetString("time-format")
+	nullValue, _ := f.GetString("null-value")
+	arrayFormat, _ := f.GetString("array-format")
+	arrayDelimiter, _ := f.GetString("array-delimiter")
+	numberFormat, _ := f.GetString("number-format")
+	groupNumbers, _ := f.GetBool("group-numbers")
+	locale, _ := f.GetString("locale")
+	floatPrecision, _ := f.GetInt("float-precision")
+	refFormat, _ := f.GetString("ref-format")
+	bytesFormat, _ := f.GetString("bytes-format")
+	resolveRefs, _ := f.GetBool("resolve-refs")
+	resolveRefField, _ := f.GetString("resolve-ref-field")
+	maxCellSize, _ := f.GetInt("max-cell-size")
+	whereFlags, _ := f.GetStringArray("where")
+	modifiedSince, _ := f.GetString("modified-since")
+	modifiedField, _ := f.GetString("modified-field")
+	fieldsFlag, _ := f.GetString("fields")
+	orderByFlag, _ := f.GetString("order-by")
+	excludeFieldsFlag, _ := f.GetString("exclude-fields")
+	dedupBy, _ := f.GetString("dedup-by")
+	dedupKeep, _ := f.GetString("dedup-keep")
+	missingField, _ := f.GetString("missing-field")
+	explode, _ := f.GetString("explode")
+	schemaFile, _ := f.GetString("schema-file")
+	schemaStrict, _ := f.GetBool("schema-strict")
+	concurrency, _ := f.GetInt("concurrency")
+	partitions, _ := f.GetInt("partitions")
+	failFast, _ := f.GetBool("fail-fast")
+	maxRetries, _ := f.GetInt("max-retries")
+	timeout, _ := f.GetDuration("timeout")
+	pageSize, _ := f.GetInt("page-size")
+	rateLimit, _ := f.GetInt("rate-limit")
+	dryRun, _ := f.GetBool("dry-run")
+	preview, _ := f.GetInt("preview")
+	manifest, _ := f.GetBool("manifest")
+	checksums, _ := f.GetBool("checksums")
+	errorsFile, _ := f.GetString("errors-file")
+	onFileFlag, _ := f.GetString("on-file")
+	onCompleteFlag, _ := f.GetString("on-complete")
+	summaryFormat, _ := f.GetString("summary-format")
+	summaryTotals, _ := f.GetBool("summary-totals")
+	resume, _ := f.GetBool("resume")
+	appendFlag, _ := f.GetBool("append")
+	emptyFiles, _ := f.GetBool("empty-files")
+	baselineDir, _ := f.GetString("baseline")
+	checkpointEvery, _ := f.GetInt("checkpoint-every")
+	flatten, _ := f.GetBool("flatten")
+	flattenArrays, _ := f.GetBool("flatten-arrays")
+	maxArrayIndex, _ := f.GetInt("max-array-index")
+	geoColumns, _ := f.GetBool("geopoint-columns")
+	onCollision, _ := f.GetString("on-collision")
+	renameFlag, _ := f.GetString("rename")
+	computeFlags, _ := f.GetStringArray("compute")
+	emitSchema, _ := f.GetBool("emit-schema")
+	validate, _ := f.GetBool("validate")
+	stream, _ := f.GetBool("stream")
+	singleFile, _ := f.GetBool("single-file")
+	splitDocuments, _ := f.GetBool("split-documents")
 	withTypes, _ := f.GetBool("with-types")
+	prettyJSON, _ := f.GetBool("pretty-json")
+	bigQuery, _ := f.GetBool("bigquery-json")
+	includeTimestamps, _ := f.GetBool("include-timestamps")
+	pathColumns, _ := f.GetBool("path-columns")
 	sanitizeFlag, _ := f.GetString("sanitize")
+	hashFieldsFlag, _ := f.GetString("hash-fields")
+	redactFieldsFlag, _ := f.GetString("redact-fields")
+	castFlag, _ := f.GetString("cast")
 	seed, _ := f.GetInt64("seed")
 
-	var san *sanitizer
-	if sanitizeFlag != "" {
-		cfg, err := parseSanitizeConfig(sanitizeFlag)
-		if err != nil {
-			return fmt.Errorf("invalid --sanitize config: %w", err)
-		}
-		san = newSanitizer(cfg, seed)
-	}
-
-	return runExport(exportConfig{
-		project:     project,
-		database:    database,
-		emulator:    emulator,
-		collections: collections,
-		limit:       limit,
-		childLimit:  childLimit,
-		maxDepth:    maxDepth,
-		output:      output,
-		withTypes:   withTypes,
-		sanitizer:   san,
-	})
-}
-
-func runExport(cfg exportConfig) error {
-	fmt.Fprintln(os.Stderr)
-	displayProject := cfg.project
-	if cfg.emulator != "" {
-		displayProject = fmt.Sprintf("emulator @ %s", cfg.emulator)
+	if collections != "" && collectionsFile != "" {
+		return fmt.Errorf("--collections and --collections-file can't be combined")
 	}
-	printInfo("Connecting to %s (database: %s)", bold(displayProject), bold(cfg.database))
-
-	if err := os.MkdirAll(cfg.output, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory %q: %w", cfg.output, err)
+	if collectionGroup != "" {
+		switch {
+		case collections != "":
+			return fmt.Errorf("--collection-group can't be combined with --collections")
+		case collectionsFile != "":
+			return fmt.Errorf("--collection-group can't be combined with --collections-file")
+		case excludeFlag != "":
+			return fmt.Errorf("--collection-group can't be combined with --exclude")
+		case strings.Contains(collectionGroup, "/"):
+			return fmt.Errorf("invalid --collection-group %q: must be a collection ID, not a path", collectionGroup)
+		}
 	}
-
-	ctx := context.Background()
-	client, err := newFirestoreClient(ctx, cfg.project, cfg.database, cfg.emulator)
+	ids, err := parseDocumentIDs(idsFlag)
 	if err != nil {
-		return fmt.Errorf("failed to create Firestore client: %w", err)
+		return fmt.Errorf("invalid --ids: %w", err)
 	}
-	defer client.Close()
-
-	collNames, err := resolveCollections(ctx, client, cfg.collections)
-	if err != nil {
-		return fmt.Errorf("failed to resolve collections: %w", err)
+	if idCodec != "" || idStartTime != "" || idEndTime != "" {
+		if idStart != "" || idEnd != "" {
+			return fmt.Errorf("--id-start-time and --id-end-time can't be combined with --id-start or --id-end")
+		}
+		if idStart, idEnd, err = idTimeBounds(idCodec, idStartTime, idEndTime); err != nil {
+			return err
+		}
 	}
-
-	printInfo("Found %d collection(s): %s", len(collNames), strings.Join(collNames, ", "))
-	fmt.Fprintln(os.Stderr)
-
-	var results []exportResult
-	for _, name := range collNames {
-		results = append(results, exportCollectionTree(ctx, client, name, cfg.limit, cfg.childLimit, cfg.maxDepth, cfg.output, cfg.withTypes, cfg.sanitizer)...)
+	if isGCSURL(output) {
+		if _, _, err := parseGCSURL(output); err != nil {
+			return fmt.Errorf("invalid --output: %w", err)
+		}
 	}
-
-	printSummaryTable(results)
-
-	var failed []string
-	for _, r := range results {
-		if r.err != nil {
-			failed = append(failed, r.collection)
+	if output == stdoutOutput && !singleFile && !f.Changed("depth") {
+		// Sub-collections would need files of their own.
+		maxDepth = 0
+	}
+	for flag, v := range map[string]string{"--file-prefix": filePrefix, "--file-suffix": fileSuffix} {
+		if strings.ContainsAny(v, `/\`) {
+			return fmt.Errorf("invalid %s %q: must not contain path separators", flag, v)
 		}
 	}
-
-	if len(failed) > 0 {
-		fmt.Fprintf(os.Stderr, "\n%s Export completed with %d error(s). Failed: %s\n",
-			red("FAILED"), len(failed), strings.Join(failed, ", "))
-		return fmt.Errorf("export failed for %d collection(s)", len(failed))
+	if !validSummaryFormats[summaryFormat] {
+		return fmt.Errorf("invalid --summary-format value %q: must be one of table, csv, tsv, none", summaryFormat)
 	}
-
-	fmt.Fprintf(os.Stderr, "\n%s All %d collection(s) exported successfully.\n",
-		green("✓"), len(results))
-	return nil
-}
-
-func resolveCollections(ctx context.Context, client *firestore.Client, flagValue string) ([]string, error) {
-	if flagValue != "" {
-		parts := strings.Split(flagValue, ",")
-		for i := range parts {
-			parts[i] = strings.TrimSpace(parts[i])
+	if !validFormats[format] {
+		return fmt.Errorf("invalid --format value %q: must be one of csv, tsv, jsonl, parquet", format)
+	}
+	if splitDocuments {
+		if f.Changed("format") && format != "jsonl" {
+			return fmt.Errorf("--split-documents writes JSON files; it can't be combined with --format %s", format)
 		}
-		return parts, nil
+		format = "jsonl"
 	}
-
-	var names []string
-	iter := client.Collections(ctx)
-	for {
-		colRef, err := iter.Next()
-		if err == iterator.Done {
-			break
+	if bigQuery {
+		if f.Changed("format") && format != "jsonl" {
+			return fmt.Errorf("--bigquery-json writes JSON Lines; it can't be combined with --format %s", format)
 		}
-		if err != nil {
-			return nil, fmt.Errorf("listing collections: %w", err)
+		if f.Changed("time-format") {
+			return fmt.Errorf("--bigquery-json writes timestamps as BigQuery loads them; it can't be combined with --time-format")
 		}
-		names = append(names, colRef.ID)
+		format, timeFormat = "jsonl", bigQueryTimeFormat
 	}
-	if len(names) == 0 {
-		return nil, fmt.Errorf("no collections found in database")
+	delimiter, err := parseDelimiter(delimiterFlag)
+	if err != nil {
+		return err
 	}
-	return names, nil
-}
-
-// exportCollectionTree exports a top-level collection and recursively exports its sub-collections.
-func exportCollectionTree(ctx context.Context, client *firestore.Client, name string, limit, childLimit, maxDepth int, outputDir string, withTypes bool, san *sanitizer) []exportResult {
-	colRef := client.Collection(name)
-	recurse := maxDepth != 0
-
-	result, docRefs := readAndExportCollection(ctx, colRef, name, 0, limit, recurse, outputDir, withTypes, san)
-	results := []exportResult{result}
-	if result.err != nil || !recurse {
-		return results
+	if format == "tsv" && f.Changed("delimiter") {
+		return fmt.Errorf("--delimiter doesn't apply to --format tsv, which always separates fields with tabs")
 	}
-
-	subCols := discoverSubCollections(ctx, docRefs)
-	for _, subName := range sortedKeys(subCols) {
-		parentRefs := subCols[subName]
-		displayPath := name + "/" + subName
-		nextDepth := maxDepth
-		if nextDepth > 0 {
-			nextDepth--
+	switch arrayFormat {
+	case "json":
+		arrayDelimiter = ""
+	case "delimited":
+		if arrayDelimiter == "" {
+			return fmt.Errorf("--array-delimiter must not be empty with --array-format delimited")
 		}
-		results = append(results, exportSubCollectionTree(ctx, parentRefs, subName, displayPath, 1, nextDepth, childLimit, outputDir, withTypes, san)...)
+	default:
+		return fmt.Errorf("invalid --array-format value %q: must be one of json, delimited", arrayFormat)
 	}
-
-	return results
-}
-
-// exportSubCollectionTree recursively exports an aggregated sub-collection and its children.
-func exportSubCollectionTree(ctx context.Context, parentRefs []*firestore.DocumentRef, subColName, displayPath string, depth, maxDepth, childLimit int, outputDir string, withTypes bool, san *sanitizer) []exportResult {
-	recurse := maxDepth != 0
-
-	result, docRefs := readAndExportAggregated(ctx, parentRefs, subColName, displayPath, depth, childLimit, recurse, outputDir, withTypes, san)
+	switch numberFormat {
+	case numberFormatNative, numberFormatFloat, numberFormatInt:
+	default:
+		return fmt.Errorf("invalid --number-format value %q: must be one of native, always-float, always-int", numberFormat)
+	}
+	var grouping *numberGrouping
+	if groupNumbers {
+		if format != "csv" && format != "tsv" {
+			return fmt.Errorf("--group-numbers only applies to CSV and TSV output")
+		}
+		if grouping, err = newNumberGrouping(locale); err != nil {
+			return err
+		}
+		if format == "csv" && !quoteAll && strings.ContainsRune(grouping.separators(), delimiter) {
+			return fmt.Errorf("--group-numbers with --locale %s writes %q in numbers, the CSV delimiter; add --quote-all or choose another --delimiter", locale, delimiter)
+		}
+		if arrayDelimiter != "" && strings.Contains(grouping.separators(), arrayDelimiter) {
+			return fmt.Errorf("--group-numbers with --locale %s writes %q in numbers, the --array-delimiter; choose another one", locale, arrayDelimiter)
+		}
+	} else if f.Changed("locale") {
+		return fmt.Errorf("--locale needs --group-numbers")
+	}
+	if floatPrecision < -1 {
+		return fmt.Errorf("invalid --float-precision %d: must be -1 or more", floatPrecision)
+	}
+	switch refFormat {
+	case refFormatPath, refFormatRelative, refFormatID:
+	default:
+		return fmt.Errorf("invalid --ref-format value %q: must be one of path, relative, id", refFormat)
+	}
+	switch bytesFormat {
+	case bytesFormatBase64, bytesFormatHex, bytesFormatSkip:
+	default:
+		return fmt.Errorf("invalid --bytes-format value %q: must be one of base64, hex, skip", bytesFormat)
+	}
+	if bytesFormat != bytesFormatBase64 {
+		switch {
+		case format == "parquet":
+			return fmt.Errorf("--bytes-format %s doesn't apply to --format parquet, which writes bytes as binary", bytesFormat)
+		case bigQuery:
+			return fmt.Errorf("--bigquery-json needs --bytes-format base64, which BigQuery decodes into BYTES")
+		}
+	}
+	if resolveRefField != "" && !resolveRefs {
+		return fmt.Errorf("--resolve-ref-field needs --resolve-refs")
+	}
+	if maxArrayIndex < 0 {
+		return fmt.Errorf("invalid --max-array-index %d: must not be negative", maxArrayIndex)
+	}
+	if f.Changed("max-array-index") && !flattenArrays {
+		return fmt.Errorf("--max-array-index needs --flatten-arrays")
+	}
+	if maxCellSize < 0 {
+		return fmt.Errorf("invalid --max-cell-size %d: must not be negative", maxCellSize)
+	}
+	if noHeader && format != "csv" && format != "tsv" {
+		return fmt.Errorf("--no-header only applies to CSV and TSV output")
+	}
+	if bom && format != "csv" && format != "tsv" {
+		return fmt.Errorf("--bom only applies to CSV and TSV output")
+	}
+	if quoteAll && format != "csv" {
+		return fmt.Errorf("--quote-all only applies to CSV output")
+	}
+	switch lineEnding {
+	case "lf":
+	case "crlf":
+		if format != "csv" && format != "tsv" {
+			return fmt.Errorf("--line-ending only applies to CSV and TSV output")
+		}
+	default:
+		return fmt.Errorf("invalid --line-ending value %q: must be one of lf, crlf", lineEnding)
+	}
+	if prettyJSON && format != "jsonl" {
+		// Newlines would break CSV rows, so cells always stay compact.
+		printWarn("--pretty-json only applies to --format jsonl; ignoring it")
+		prettyJSON = false
+	}
+	if gzip {
+		if f.Changed("compression") && compression != "gzip" {
+			return fmt.Errorf("--gzip can't be combined with --compression %s", compression)
+		}
+		compression = "gzip"
+	}
+	if _, ok := codecs[compression]; !ok && compression != compressionNone {
+		return fmt.Errorf("invalid --compression value %q: must be one of %s", compression, strings.Join(codecNames(), ", "))
+	}
+	if compression == compressionNone {
+		compression = ""
+	}
+	if compression != "" && format == "parquet" {
+		return fmt.Errorf("--compression doesn't apply to Parquet output, which is compressed with Snappy")
+	}
+	var maxFileSize int64
+	if maxFileSizeFlag != "" {
+		if maxFileSize, err = parseByteSize(maxFileSizeFlag); err != nil {
+			return fmt.Errorf("invalid --max-file-size: %w", err)
+		}
+	}
+	where, err := parseWhereFilters(whereFlags)
+	if err != nil {
+		return err
+	}
+	if modifiedSince != "" {
+		wf, err := modifiedSinceFilter(modifiedSince, modifiedField)
+		if err != nil {
+			return err
+		}
+		where = append(where, wf)
+	} else if modifiedField != "" {
+		return fmt.Errorf("--modified-field is only used with --modified-since")
+	}
+	fields, err := parseFieldList(fieldsFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --fields: %w", err)
+	}
+	hashFields, redactFields, err := parseMaskFields(hashFieldsFlag, redactFieldsFlag)
+	if err != nil {
+		return err
+	}
+	casts, err := parseCast(castFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --cast: %w", err)
+	}
+	excludeFields, err := parseFieldList(excludeFieldsFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --exclude-fields: %w", err)
+	}
+	orderBy, err := parseOrderBy(orderByFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --order-by: %w", err)
+	}
+	limitPer, err := parseLimitPer(limitPerFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --limit-per: %w", err)
+	}
+	if preview < 0 {
+		return fmt.Errorf("invalid --preview %d: must not be negative", preview)
+	}
+	if preview > 0 {
+		limit, childLimit = previewLimit(limit, preview), previewLimit(childLimit, preview)
+		for name, n := range limitPer {
+			limitPer[name] = previewLimit(n, preview)
+		}
+	}
+	rename, err := parseRename(renameFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --rename: %w", err)
+	}
+	compute, err := parseCompute(computeFlags)
+	if err != nil {
+		return fmt.Errorf("invalid --compute: %w", err)
+	}
+	onFile, err := parseHook("--on-file", onFileFlag)
+	if err != nil {
+		return err
+	}
+	onComplete, err := parseHook("--on-complete", onCompleteFlag)
+	if err != nil {
+		return err
+	}
+	if onCollision != onCollisionError && onCollision != onCollisionSuffix {
+		return fmt.Errorf("invalid --on-collision value %q: must be one of error, suffix", onCollision)
+	}
+	if partitions < 0 {
+		return fmt.Errorf("invalid --partitions %d: must not be negative", partitions)
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("invalid --concurrency %d: must be at least 1", concurrency)
+	}
+	if maxRetries < 0 {
+		return fmt.Errorf("invalid --max-retries %d: must not be negative", maxRetries)
+	}
+	if pageSize < 0 {
+		return fmt.Errorf("invalid --page-size %d: must not be negative", pageSize)
+	}
+	if rateLimit < 0 {
+		return fmt.Errorf("invalid --rate-limit %d: must not be negative", rateLimit)
+	}
+	if timeout < 0 {
+		return fmt.Errorf("invalid --timeout %s: must not be negative", timeout)
+	}
+	var columnOrder []string
+	if schemaFile != "" {
+		if columnOrder, err = readSchemaFile(schemaFile); err != nil {
+			return err
+		}
+	}
+
+	var san *sanitizer
+	if sanitizeFlag != "" {
+		cfg, err := parseSanitizeConfig(sanitizeFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --sanitize config: %w", err)
+		}
+		san = newSanitizer(cfg, seed)
+	}
+
+	cfg := exportConfig{
+		project:     project,
+		database:    strings.Join(databases, ","),
+		databases:   databases,
+		emulator:    emulator,
+		emulatorEnv: emulator != "" && !f.Changed("emulator"),
+		credentials: credentials,
+		endpoint:    endpoint,
+		collections: collections,
+		collFile:    collectionsFile,
+		exclude:     splitList(excludeFlag),
+		group:       collectionGroup,
+		ids:         ids,
+		idStart:     idStart,
+		idEnd:       idEnd,
+		idCodec:     idCodec,
+		limit:       limit,
+		limitPer:    limitPer,
+		childLimit:  childLimit,
+		maxDepth:    maxDepth,
+		output:      output,
+		format:      format,
+		filePrefix:  filePrefix,
+		fileSuffix:  fileSuffix,
+		compression: compression,
+		delimiter:   delimiter,
+		noHeader:    noHeader,
+		bom:         bom,
+		quoteAll:    quoteAll,
+		crlf:        lineEnding == "crlf",
+		timeFormat:  resolveTimeFormat(timeFormat),
+		nullValue:   nullValue,
+		arrayDelim:  arrayDelimiter,
+		numberFmt:   numberFormat,
+		grouping:    grouping,
+		roundFloats: floatPrecision >= 0,
+		floatPrec:   floatPrecision,
+		refFormat:   refFormat,
+		bytesFormat: bytesFormat,
+		maxCellSize: maxCellSize,
+		where:       where,
+		fields:      fields,
+		orderBy:     orderBy,
+		dedupBy:     dedupBy,
+		dedupKeep:   dedupKeep,
+		missing:     missingField,
+		explode:     explode,
+		columnOrder: columnOrder,
+		withTypes:   withTypes,
+		prettyJSON:  prettyJSON,
+		bigQuery:    bigQuery,
+		sanitizer:   san,
+		flatten:     flatten,
+		geoColumns:  geoColumns,
+		onCollision: onCollision,
+		rename:      rename,
+		compute:     compute,
+		emitSchema:  emitSchema,
+		stream:      stream,
+		concurrency: concurrency,
+		partitions:  partitions,
+		failFast:    failFast,
+		maxRetries:  maxRetries,
+		timeout:     timeout,
+		pageSize:    pageSize,
+		limiter:     newReadLimiter(rateLimit),
+		dryRun:      dryRun || preview > 0,
+		preview:     preview,
+		manifest:    manifest,
+		errorsFile:  errorsFile,
+		onFile:      onFile,
+		onComplete:  onComplete,
+		summary:     summaryFormat,
+		totals:      summaryTotals,
+
+		includeTimestamps: includeTimestamps,
+		pathColumns:       pathColumns,
+		schemaStrict:      schemaStrict,
+		resolveRefs:       resolveRefs,
+		resolveField:      resolveRefField,
+		excludeFields:     fieldNameSet(excludeFields),
+		hashFields:        hashFields,
+		redactFields:      redactFields,
+		casts:             casts,
+		flattenArrays:     flattenArrays,
+		maxArrayIndex:     maxArrayIndex,
+		sanitizeNames:     sanitizeNames,
+		singleFile:        singleFile,
+		splitDocuments:    splitDocuments,
+		append:            appendFlag,
+		emptyFiles:        emptyFiles,
+		baseline:          baselineDir,
+		resume:            resume,
+		checkpointEvery:   checkpointEvery,
+		validate:          validate,
+		maxFileSize:       maxFileSize,
+		checksumFiles:     checksums,
+	}
+	if cfg.preview > 0 {
+		if err := validatePreview(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.output == stdoutOutput {
+		if err := validateStdout(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.resume {
+		if err := validateResume(cfg); err != nil {
+			return err
+		}
+	}
+	if err := validateDedup(cfg); err != nil {
+		return err
+	}
+	if cfg.missing != "" {
+		if err := validateMissingField(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.partitions > 1 {
+		if err := validatePartitions(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.bigQuery {
+		if err := validateBigQuery(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.explode != "" {
+		if err := validateExplode(cfg); err != nil {
+			return err
+		}
+	}
+	if len(cfg.columnOrder) > 0 {
+		if err := validateSchemaFile(cfg); err != nil {
+			return err
+		}
+	}
+	if len(cfg.compute) > 0 {
+		if err := validateCompute(cfg); err != nil {
+			return err
+		}
+	}
+	if len(cfg.ids) > 0 {
+		if err := validateIDs(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.idStart != "" || cfg.idEnd != "" {
+		if err := validateIDRange(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.singleFile {
+		if err := validateSingleFile(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.pathColumns {
+		if err := validatePathColumns(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.splitDocuments {
+		if err := validateSplitDocuments(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.append {
+		if err := validateAppend(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.emptyFiles {
+		if err := validateEmptyFiles(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.onFile != nil {
+		if err := validateOnFile(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.baseline != "" {
+		if err := validateBaseline(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.maxFileSize > 0 {
+		if err := validateMaxFileSize(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.checksumFiles {
+		if err := validateChecksums(cfg); err != nil {
+			return err
+		}
+	}
+	return runExport(cfg)
+}
+
+func runExport(cfg exportConfig) error {
+	printText("\n")
+	if cfg.emulatorEnv {
+		printInfo("Using the emulator at %s from %s", bold(cfg.emulator), emulatorHostEnv)
+	}
+
+	ctx, cancel := exportContext(cfg.timeout)
+	defer cancel()
+	if cfg.preview > 0 {
+		printInfo("Preview: up to %d document(s) per collection are read and no files are written", cfg.preview)
+	} else if cfg.dryRun {
+		printInfo("Dry run: documents are read but no files are written")
+	} else if isGCSURL(cfg.output) {
+		gcs, err := newGCSOutput(ctx, cfg.output, cfg.credentials)
+		if err != nil {
+			return fmt.Errorf("failed to set up output %q: %w", cfg.output, err)
+		}
+		defer gcs.close()
+		cfg.gcs = gcs
+		printInfo("Writing output to %s", bold(cfg.output))
+	} else if cfg.output == stdoutOutput {
+		printInfo("Writing output to stdout")
+	} else if err := os.MkdirAll(cfg.output, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %q: %w", cfg.output, err)
+	}
+
+	if cfg.validate {
+		cfg.validation = newTypeReport()
+	}
+	if (cfg.manifest || cfg.checksumFiles) && !cfg.dryRun {
+		cfg.checksums = newChecksumSet(cfg.checksumFiles)
+	}
+
+	var results []exportResult
+	for i, db := range cfg.databases {
+		dbCfg := cfg
+		dbCfg.database = db
+		if len(cfg.databases) > 1 {
+			dbCfg.dbPrefix = databaseFilePrefix(db)
+		}
+		dbResults, err := exportDatabase(ctx, dbCfg)
+		if err != nil {
+			if len(cfg.databases) == 1 {
+				return err
+			}
+			// Keep what the other databases exported; this one is reported
+			// as a single failed result for all of its collections.
+			printErr("Failed to export database %q: %v", db, err)
+			dbResults = []exportResult{{collection: "*", err: err}}
+		}
+		if cfg.checksums != nil {
+			for j := range dbResults {
+				dbResults[j].sha256 = cfg.checksums.get(dbResults[j].filePath)
+				dbResults[j].partSums = cfg.checksums.getAll(dbResults[j].parts)
+			}
+		}
+		if len(cfg.databases) > 1 {
+			for j := range dbResults {
+				dbResults[j].database = db
+			}
+		}
+		results = append(results, dbResults...)
+		if i < len(cfg.databases)-1 && (ctx.Err() != nil || (cfg.failFast && hasFailure(dbResults))) {
+			printInfo("Stopping; %d database(s) not exported", len(cfg.databases)-i-1)
+			break
+		}
+	}
+
+	switch cfg.summary {
+	case "csv", "tsv":
+		if err := writeSummaryCSV(os.Stdout, results, cfg.summary == "tsv"); err != nil {
+			return fmt.Errorf("failed to write summary: %w", err)
+		}
+	case "none":
+	default:
+		printSummaryTable(results, cfg.totals)
+	}
+	if cfg.validation != nil {
+		cfg.validation.print()
+	}
+
+	if cfg.errorsFile != "" {
+		if err := writeErrorsFile(cfg.errorsFile, results); err != nil {
+			return fmt.Errorf("failed to write errors file: %w", err)
+		}
+	}
+
+	if cfg.manifest && !cfg.dryRun {
+		manifestPath, err := writeManifest(buildManifest(results, cfg, time.Now()), cfg)
+		if err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+		printInfo("Wrote manifest → %s", manifestPath)
+	}
+
+	var failed, hookFailed []string
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, resultName(r))
+		}
+		if r.hookErr != nil {
+			hookFailed = append(hookFailed, resultName(r))
+		}
+	}
+	completeErr := runCompleteHook(ctx, len(failed), cfg)
+
+	if len(failed) > 0 {
+		printDone(false, "Export completed with %d error(s). Failed: %s",
+			len(failed), strings.Join(failed, ", "))
+		err := fmt.Errorf("export failed for %d collection(s)", len(failed))
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %w", err, context.Cause(ctx))
+		}
+		if len(failed) < len(results) {
+			return &partialError{err}
+		}
+		return err
+	}
+
+	// The files were written, so failed hooks only make the run partial.
+	if len(hookFailed) > 0 {
+		printDone(false, "All %d collection(s) exported, but --on-file failed for %d: %s",
+			len(results), len(hookFailed), strings.Join(hookFailed, ", "))
+		return &partialError{fmt.Errorf("--on-file failed for %d collection(s)", len(hookFailed))}
+	}
+	if completeErr != nil {
+		printDone(false, "All %d collection(s) exported, but --on-complete failed", len(results))
+		return &partialError{fmt.Errorf("--on-complete failed: %w", completeErr)}
+	}
+
+	printDone(true, "All %d collection(s) exported successfully.", len(results))
+	return nil
+}
+
+// exportDatabase exports the collections of cfg.database, connecting with a
+// client of its own.
+func exportDatabase(ctx context.Context, cfg exportConfig) ([]exportResult, error) {
+	displayProject := describeConnection(cfg.project, cfg.emulator, cfg.endpoint)
+	printInfo("Connecting to %s (database: %s)", bold(displayProject), bold(cfg.database))
+
+	client, err := newFirestoreClient(ctx, cfg.project, cfg.database, cfg.emulator, cfg.credentials, cfg.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	defer client.Close()
+
+	if cfg.singleFile && !cfg.dryRun {
+		cfg.combined = newCombinedOutput()
+	}
+	if cfg.resolveRefs {
+		cfg.resolver = newRefResolver(client, cfg.resolveField, cfg.limiter)
+	}
+
+	var results []exportResult
+	if cfg.group != "" {
+		printInfo("Exporting collection group %q", cfg.group)
+		printText("\n")
+		results = []exportResult{exportCollectionGroup(ctx, client, cfg.group, cfg)}
+	} else {
+		collNames, err := resolveCollections(ctx, client, cfg.collections, cfg.collFile, cfg.exclude)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve collections: %w", err)
+		}
+		if cfg.output == stdoutOutput && !cfg.singleFile && len(collNames) != 1 {
+			return nil, fmt.Errorf("--output - writes a single collection to stdout, but %d matched: %s; narrow --collections or use --single-file",
+				len(collNames), strings.Join(collNames, ", "))
+		}
+
+		printInfo("Found %d collection(s): %s", len(collNames), strings.Join(collNames, ", "))
+		printText("\n")
+
+		results = exportCollections(ctx, client, collNames, cfg)
+	}
+
+	if cfg.combined != nil {
+		writeCombined(results, cfg)
+	}
+	return results, nil
+}
+
+// resultName names the collection of r in messages, with its database when
+// several are exported.
+func resultName(r exportResult) string {
+	if r.database == "" {
+		return r.collection
+	}
+	return fmt.Sprintf("%s (%s)", r.collection, r.database)
+}
+
+// resolveCollections returns the top-level collections to export: the
+// --collections list or the names in the --collections-file file if given,
+// otherwise every collection in the database except those named in exclude.
+// Entries containing glob characters (*, ?, [) are matched against the
+// database's collections.
+func resolveCollections(ctx context.Context, client *firestore.Client, flagValue, file string, exclude []string) ([]string, error) {
+	var parts []string
+	switch {
+	case flagValue != "":
+		parts = strings.Split(flagValue, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+	case file != "":
+		var err error
+		if parts, err = readCollectionsFile(file); err != nil {
+			return nil, err
+		}
+	}
+	if len(parts) > 0 {
+		if len(exclude) > 0 {
+			printInfo("--exclude only applies when exporting all collections; ignoring it")
+		}
+		hasPattern := false
+		for _, p := range parts {
+			hasPattern = hasPattern || isGlobPattern(p)
+		}
+		if !hasPattern {
+			return parts, nil
+		}
+		all, err := listCollections(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		return expandCollectionPatterns(parts, all)
+	}
+
+	names, err := listCollections(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no collections found in database")
+	}
+	if len(exclude) > 0 {
+		var missing []string
+		names, missing = excludeCollections(names, exclude)
+		for _, name := range missing {
+			printInfo("Excluded collection %q does not exist", name)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("all collections are excluded by --exclude")
+		}
+	}
+	return names, nil
+}
+
+// readCollectionsFile reads a --collections-file: one collection name or
+// pattern per line. Lines are trimmed; blank lines and lines starting with #
+// are skipped.
+func readCollectionsFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading --collections-file: %w", err)
+	}
+	var names []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("--collections-file %s lists no collections", path)
+	}
+	return names, nil
+}
+
+// listCollections returns the IDs of all top-level collections.
+func listCollections(ctx context.Context, client *firestore.Client) ([]string, error) {
+	var names []string
+	iter := client.Collections(ctx)
+	for {
+		colRef, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing collections: %w", err)
+		}
+		names = append(names, colRef.ID)
+	}
+	return names, nil
+}
+
+// isGlobPattern reports whether a --collections entry uses path.Match syntax.
+func isGlobPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// expandCollectionPatterns replaces each glob entry with the collections in
+// all that match it. Plain names are kept as given. Each collection appears
+// once, and a pattern that matches nothing is an error.
+func expandCollectionPatterns(entries, all []string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, entry := range entries {
+		if !isGlobPattern(entry) {
+			add(entry)
+			continue
+		}
+		matched := false
+		for _, name := range all {
+			ok, err := path.Match(entry, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid collection pattern %q: %w", entry, err)
+			}
+			if ok {
+				matched = true
+				add(name)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("collection pattern %q matches no collections", entry)
+		}
+	}
+	return names, nil
+}
+
+// excludeCollections removes the excluded names from names, keeping order.
+// It also returns the excluded names that weren't in names, to catch typos.
+func excludeCollections(names, exclude []string) (kept, missing []string) {
+	skip := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		skip[name] = true
+	}
+	found := make(map[string]bool, len(exclude))
+	for _, name := range names {
+		if skip[name] {
+			found[name] = true
+			continue
+		}
+		kept = append(kept, name)
+	}
+	for _, name := range exclude {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	return kept, missing
+}
+
+// splitList splits a comma-separated flag value, trimming spaces and dropping
+// blank entries.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// exportCollections exports each named top-level collection tree, running up to
+// cfg.concurrency trees in parallel. Results keep the order of names regardless
+// of which tree finishes first.
+func exportCollections(ctx context.Context, client *firestore.Client, names []string, cfg exportConfig) []exportResult {
+	if cfg.concurrency <= 1 || len(names) <= 1 {
+		var results []exportResult
+		for i, name := range names {
+			if ctx.Err() != nil {
+				reportStopped(ctx, len(names)-i)
+				break
+			}
+			tree := exportCollectionTree(ctx, client, name, cfg)
+			results = append(results, tree...)
+			if cfg.failFast && hasFailure(tree) {
+				reportStopped(ctx, len(names)-i-1)
+				break
+			}
+		}
+		return results
+	}
+
+	// With --fail-fast the first failure cancels the exports still running
+	// and keeps the remaining collections from starting, as a signal or
+	// --timeout does through the parent context.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Per-collection spinners can't share one terminal line, so show a single
+	// aggregate progress line instead.
+	cfg.noSpinner = true
+	sp := newSpinner(fmt.Sprintf("Exporting... 0/%d collections done", len(names)), true)
+	sp.Start()
+
+	type treeResult struct {
+		index   int
+		results []exportResult
+	}
+	jobs := make(chan int)
+	out := make(chan treeResult, len(names))
+
+	workers := min(cfg.concurrency, len(names))
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				tree := exportCollectionTree(ctx, client, names[i], cfg)
+				if cfg.failFast && hasFailure(tree) {
+					cancel()
+				}
+				out <- treeResult{index: i, results: tree}
+			}
+		}()
+	}
+	go func() {
+		for i := range names {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(out)
+	}()
+
+	trees := make([][]exportResult, len(names))
+	done := 0
+	for tr := range out {
+		trees[tr.index] = tr.results
+		done++
+		sp.SetSuffix(fmt.Sprintf("Exporting... %d/%d collections done", done, len(names)))
+	}
+	sp.Stop()
+	if done < len(names) {
+		reportStopped(ctx, len(names)-done)
+	}
+
+	var results []exportResult
+	for _, tree := range trees {
+		results = append(results, tree...)
+	}
+	return results
+}
+
+// hasFailure reports whether any collection in results failed to export.
+func hasFailure(results []exportResult) bool {
+	for _, r := range results {
+		if r.err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// exportCollectionTree exports a top-level collection and recursively exports its sub-collections.
+// A --limit-per entry for the collection replaces cfg.limit.
+func exportCollectionTree(ctx context.Context, client *firestore.Client, name string, cfg exportConfig) []exportResult {
+	if n, ok := cfg.limitPer[name]; ok {
+		cfg.limit = n
+	}
+	colRef := client.Collection(name)
+	recurse := cfg.maxDepth != 0
+
+	result, docRefs := readAndExportCollection(ctx, client, colRef, name, 0, recurse, cfg)
+	runFileHook(ctx, &result, cfg)
+	results := []exportResult{result}
+	if result.err != nil || !recurse {
+		return results
+	}
+
+	subCols := discoverSubCollections(ctx, docRefs)
+	for _, subName := range sortedKeys(subCols) {
+		parentRefs := subCols[subName]
+		displayPath := name + "/" + subName
+		nextDepth := cfg.maxDepth
+		if nextDepth > 0 {
+			nextDepth--
+		}
+		results = append(results, exportSubCollectionTree(ctx, parentRefs, subName, displayPath, 1, nextDepth, cfg)...)
+		if (cfg.failFast && hasFailure(results)) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return results
+}
+
+// exportCollectionGroup exports the documents of every collection with the
+// given ID, wherever it sits in the database, into a single {id} output file.
+// The top-level options (--where, --limit) apply to the whole group, and
+// sub-collections of its documents are not exported.
+func exportCollectionGroup(ctx context.Context, client *firestore.Client, id string, cfg exportConfig) exportResult {
+	if n, ok := cfg.limitPer[id]; ok {
+		cfg.limit = n
+	}
+	query := applyFieldSelection(applyWhereFilters(client.CollectionGroup(id).Query, cfg.where), cfg.fields)
+	query = applyOrderBy(query, cfg.orderBy, cfg.where)
+	if cfg.limit > 0 {
+		query = query.Limit(cfg.limit)
+	}
+	if verbosity >= verboseQueries {
+		printDebugFor(id, "Query for collection group %q: %s", id, describeQuery(cfg.where, cfg.orderBy, cfg.fields, cfg.limit))
+	}
+	scan := queryScan(ctx, []firestore.Query{query}, cfg.limit, cfg)
+	if cfg.partitions > 1 {
+		refs := func(cursors []string) []any { return groupCursors(client, cursors) }
+		scan = partitionedScan(ctx, client, id, query, refs, id, cfg)
+	}
+	result, _ := readAndExport(ctx, nil, scan, id, 0, false, cfg)
+	runFileHook(ctx, &result, cfg)
+	return result
+}
+
+// exportSubCollectionTree recursively exports an aggregated sub-collection and its children.
+// maxDepth is the remaining depth budget below this sub-collection; cfg.maxDepth
+// is not consulted.
+func exportSubCollectionTree(ctx context.Context, parentRefs []*firestore.DocumentRef, subColName, displayPath string, depth, maxDepth int, cfg exportConfig) []exportResult {
+	recurse := maxDepth != 0
+	if cfg.pathColumns {
+		// Every document of the sub-collection has depth parents.
+		cfg.parentLevels = depth
+	}
+
+	result, docRefs := readAndExportAggregated(ctx, parentRefs, subColName, displayPath, depth, recurse, cfg)
+	runFileHook(ctx, &result, cfg)
 	results := []exportResult{result}
 	if result.err != nil || !recurse {
 		return results
@@ -470,199 +2131,636 @@ func exportSubCollectionTree(ctx context.Context, parentRefs []*firestore.Docume
 		if nextDepth > 0 {
 			nextDepth--
 		}
-		results = append(results, exportSubCollectionTree(ctx, refs, subSubName, subDisplayPath, depth+1, nextDepth, childLimit, outputDir, withTypes, san)...)
+		results = append(results, exportSubCollectionTree(ctx, refs, subSubName, subDisplayPath, depth+1, nextDepth, cfg)...)
+		if (cfg.failFast && hasFailure(results)) || ctx.Err() != nil {
+			break
+		}
 	}
 
 	return results
 }
 
-// readAndExportCollection reads documents from a single collection ref and writes a CSV.
-// If recurse is true, it returns the document refs for sub-collection discovery.
-func readAndExportCollection(ctx context.Context, colRef *firestore.CollectionRef, displayPath string, depth, limit int, recurse bool, outputDir string, withTypes bool, san *sanitizer) (exportResult, []*firestore.DocumentRef) {
-	sp := newSpinner(fmt.Sprintf("Reading %q... 0 documents", displayPath))
-	sp.Start()
+// readAndExportCollection reads documents from a single collection ref and writes
+// them to an output file. If recurse is true, it returns the document refs for
+// sub-collection discovery.
+func readAndExportCollection(ctx context.Context, client *firestore.Client, colRef *firestore.CollectionRef, displayPath string, depth int, recurse bool, cfg exportConfig) (exportResult, []*firestore.DocumentRef) {
+	if cfg.resume {
+		return resumeAndExport(ctx, colRef, displayPath, depth, cfg)
+	}
+	if len(cfg.ids) > 0 {
+		if verbosity >= verboseQueries {
+			printDebugFor(displayPath, "Query for %q: %d document ID(s), %s", displayPath, len(cfg.ids), describeQuery(cfg.where, cfg.orderBy, cfg.fields, 0))
+		}
+		// No colRefs: an empty result shouldn't walk the whole collection.
+		return readAndExport(ctx, nil, idScan(ctx, colRef, cfg.ids, cfg), displayPath, depth, recurse, cfg)
+	}
+	query := applyFieldSelection(applyWhereFilters(colRef.Query, cfg.where), cfg.fields)
+	query = applyIDRange(applyOrderBy(query, cfg.orderBy, cfg.where), cfg)
+	if cfg.limit > 0 {
+		query = query.Limit(cfg.limit)
+	}
+	if verbosity >= verboseQueries {
+		desc := describeQuery(cfg.where, cfg.orderBy, cfg.fields, cfg.limit)
+		if cfg.idStart != "" || cfg.idEnd != "" {
+			desc += ", " + describeIDRange(cfg)
+		}
+		printDebugFor(displayPath, "Query for %q: %s", displayPath, desc)
+	}
+	scan := queryScan(ctx, []firestore.Query{query}, cfg.limit, cfg)
+	if cfg.partitions > 1 {
+		toIDs := func(cursors []string) []any { return collectionCursors(cursors, colRef.ID, cfg) }
+		scan = partitionedScan(ctx, client, colRef.ID, query, toIDs, displayPath, cfg)
+	}
+	return readAndExport(ctx, []*firestore.CollectionRef{colRef}, scan, displayPath, depth, recurse, cfg)
+}
 
-	query := colRef.Query
-	if limit > 0 {
-		query = query.Limit(limit)
+// readAndExportAggregated reads documents from a sub-collection across multiple parent documents
+// and writes them into a single output file.
+func readAndExportAggregated(ctx context.Context, parentRefs []*firestore.DocumentRef, subColName, displayPath string, depth int, recurse bool, cfg exportConfig) (exportResult, []*firestore.DocumentRef) {
+	colRefs := make([]*firestore.CollectionRef, len(parentRefs))
+	queries := make([]firestore.Query, len(parentRefs))
+	for i, parentRef := range parentRefs {
+		colRefs[i] = parentRef.Collection(subColName)
+		queries[i] = applyOrderBy(applyFieldSelection(colRefs[i].Query, cfg.fields), cfg.orderBy, nil)
+		if cfg.childLimit > 0 {
+			queries[i] = queries[i].Limit(cfg.childLimit)
+		}
+	}
+	if verbosity >= verboseQueries {
+		printDebugFor(displayPath, "Query for %q under each of %d parent(s): %s", displayPath, len(parentRefs), describeQuery(nil, cfg.orderBy, cfg.fields, cfg.childLimit))
 	}
+	return readAndExport(ctx, colRefs, queryScan(ctx, queries, cfg.childLimit, cfg), displayPath, depth, recurse, cfg)
+}
 
-	iter := query.Documents(ctx)
-	defer iter.Stop()
+// readAndExport reads the documents scan returns, collecting them into a single
+// output file for displayPath. colRefs are the collections scanned; they are
+// used to find virtual documents when the scan returns no data.
+func readAndExport(ctx context.Context, colRefs []*firestore.CollectionRef, scan scanFunc, displayPath string, depth int, recurse bool, cfg exportConfig) (exportResult, []*firestore.DocumentRef) {
+	if cfg.stream && !cfg.dryRun {
+		return streamAndExport(ctx, colRefs, scan, displayPath, depth, recurse, cfg)
+	}
+
+	sp := newSpinner(fmt.Sprintf("Reading %q... 0 documents", displayPath), !cfg.noSpinner)
+	sp.Start()
 
 	fieldSet := make(map[string]struct{})
 	var docs []docRecord
 	var docRefs []*firestore.DocumentRef
-
-	count := 0
-	for {
-		snap, err := iter.Next()
-		if err == iterator.Done {
-			break
+	// --dedup-by and --missing-field apply to the documents --where and
+	// --order-by apply to.
+	dedup := cfg.dedupBy != "" && depth == 0
+	missing := cfg.missing != "" && depth == 0
+	checkIDs := cfg.idCodec != "" && depth == 0
+	kept, rows := 0, 0
+
+	count, err := scan(sp, fmt.Sprintf("Reading %q...", displayPath), func(snap *firestore.DocumentSnapshot) error {
+		if verbosity >= verboseDocuments {
+			debugDocument(displayPath, snap)
+		}
+		if checkIDs && !keepDocumentID(displayPath, snap.Ref.ID, cfg, true) {
+			return nil
 		}
+		raw := snap.Data()
+		if missing && !lacksField(raw, cfg.missing) {
+			return nil
+		}
+		kept++
+		if err := cfg.resolver.resolve(ctx, raw); err != nil {
+			return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+		}
+		records, err := prepareRows(raw, documentPath(snap.Ref), cfg)
 		if err != nil {
-			sp.Stop()
-			printErr("Failed to export %q: %v", displayPath, err)
-			return exportResult{collection: displayPath, depth: depth, err: err}, nil
+			return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
 		}
-		data := snap.Data()
-		for k := range data {
-			fieldSet[k] = struct{}{}
+		rows += len(records)
+		for _, data := range records {
+			for k := range data {
+				fieldSet[k] = struct{}{}
+			}
+			if !cfg.dryRun || dedup || cfg.validate || cfg.preview > 0 {
+				// A dry run only reports counts, so documents aren't kept unless
+				// duplicates or mixed types have to be found first, or are
+				// printed by --preview.
+				docs = append(docs, newDocRecord(snap, data))
+			}
 		}
-		docs = append(docs, docRecord{path: documentPath(snap.Ref), data: data})
 		if recurse {
 			docRefs = append(docRefs, snap.Ref)
 		}
-		count++
-		sp.SetSuffix(fmt.Sprintf("Reading %q... %s documents", displayPath, fmtInt(count)))
+		return nil
+	})
+	sp.Stop()
+	if cfg.partitions > 1 && depth == 0 {
+		// Partitions are read concurrently; restore the order of one query.
+		sort.SliceStable(docs, func(i, j int) bool { return comparePaths(docs[i].path, docs[j].path) < 0 })
+	}
+	if err != nil && ctx.Err() != nil && len(docs) > 0 && !cfg.dryRun && cfg.combined == nil {
+		// Cut short by a signal or --timeout: keep what was read.
+		if filePath, parts, writeErr := writeCollectionFiles(docs, fieldSet, displayPath, cfg); writeErr == nil {
+			result := stoppedResult(displayPath, depth, len(docs), len(headerFields(fieldSet, cfg)), filePath, err)
+			result.parts, result.files = parts, documentFiles(len(docs), cfg)
+			return result, nil
+		}
+	}
+	if err != nil {
+		printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
+		return exportResult{collection: displayPath, depth: depth, err: err}, nil
 	}
 
-	sp.Stop()
+	if missing {
+		reportMissingField(displayPath, kept, count, cfg.missing)
+	}
+	if missing || checkIDs {
+		count = kept
+	}
+	if cfg.explode != "" {
+		// Counts are of rows, as they are for the exports that aren't dry runs.
+		count = rows
+	}
+	if count == 0 {
+		return emptyCollectionResult(ctx, colRefs, displayPath, depth, recurse, cfg)
+	}
 
-	if len(docs) == 0 {
-		// Even if there are no documents with data, there may be virtual
-		// documents that act as containers for sub-collections. List document
-		// refs so the caller can still discover sub-collections.
-		if recurse {
-			refIter := colRef.DocumentRefs(ctx)
-			for {
-				ref, err := refIter.Next()
-				if err == iterator.Done {
-					break
+	if dedup {
+		var dropped int
+		if docs, dropped = dedupDocs(docs, cfg.dedupBy, cfg.dedupKeep); dropped > 0 {
+			printInfoFor(displayPath, "Dropped %s duplicate(s) by %q from %q, keeping the %s of each", fmtInt(dropped), cfg.dedupBy, displayPath, cfg.dedupKeep)
+			count = len(docs)
+			// Fields only the dropped documents had no longer get a column.
+			fieldSet = make(map[string]struct{})
+			for _, doc := range docs {
+				for k := range doc.data {
+					fieldSet[k] = struct{}{}
 				}
-				if err != nil {
-					printErr("Failed to list document refs for %q: %v", displayPath, err)
-					break
+			}
+		}
+	}
+
+	if cfg.validation != nil {
+		cfg.validation.add(inferSchema(docs, displayPath))
+	}
+
+	if cfg.dryRun {
+		if cfg.preview > 0 {
+			printPreview(displayPath, docs, fieldSet, cfg)
+		}
+		fieldCount := len(headerFields(fieldSet, cfg))
+		printOKFor(displayPath, "Scanned %q — %s docs, %d fields (dry-run)", displayPath, fmtInt(count), fieldCount)
+		return exportResult{
+			collection: displayPath,
+			depth:      depth,
+			docCount:   count,
+			fieldCount: fieldCount,
+			filePath:   "(dry-run)",
+		}, docRefs
+	}
+
+	if cfg.combined != nil {
+		// The documents are written with every other collection's by writeCombined.
+		cfg.combined.add(displayPath, docs, fieldSet)
+		fieldCount := len(headerFields(fieldSet, cfg))
+		printOKFor(displayPath, "Read %q — %s docs, %d fields", displayPath, fmtInt(len(docs)), fieldCount)
+		return exportResult{collection: displayPath, depth: depth, docCount: len(docs), fieldCount: fieldCount}, docRefs
+	}
+
+	filePath, parts, err := writeCollectionFiles(docs, fieldSet, displayPath, cfg)
+	if err == nil && cfg.emitSchema {
+		_, err = writeSchemaFile(inferSchema(docs, displayPath), displayPath, cfg)
+	}
+	if err == nil && cfg.bigQuery {
+		_, err = writeBigQuerySchema(inferBigQuerySchema(docs), displayPath, cfg)
+	}
+	if err != nil {
+		printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
+		return exportResult{collection: displayPath, depth: depth, err: err}, nil
+	}
+
+	fieldCount := len(headerFields(fieldSet, cfg))
+	printOKFor(displayPath, "Exported %q — %s docs, %d fields → %s", displayPath, fmtInt(len(docs)), fieldCount, describeOutput(filePath, parts, documentFiles(len(docs), cfg)))
+
+	return exportResult{
+		collection: displayPath,
+		depth:      depth,
+		docCount:   len(docs),
+		fieldCount: fieldCount,
+		filePath:   filePath,
+		parts:      parts,
+		files:      documentFiles(len(docs), cfg),
+	}, docRefs
+}
+
+// streamAndExport is the --stream variant of readAndExport. Rows are written as
+// documents arrive instead of being held in memory. Formats with a fixed header
+// (CSV) need the field union up front, so they first make a discovery scan of
+// the same documents that only keeps field names, unless --fields fixes the header.
+// Parquet always makes the pass, since its columns are typed.
+func streamAndExport(ctx context.Context, colRefs []*firestore.CollectionRef, scan scanFunc, displayPath string, depth int, recurse bool, cfg exportConfig) (exportResult, []*firestore.DocumentRef) {
+	var fieldSet map[string]struct{}
+	var docRefs []*firestore.DocumentRef
+	collectRef := func(snap *firestore.DocumentSnapshot) {
+		if recurse {
+			docRefs = append(docRefs, snap.Ref)
+		}
+	}
+
+	missing := cfg.missing != "" && depth == 0
+	checkIDs := cfg.idCodec != "" && depth == 0
+	// Skipped IDs are reported by the pass that writes the documents.
+	skip := func(snap *firestore.DocumentSnapshot, warn bool) bool {
+		if checkIDs && !keepDocumentID(displayPath, snap.Ref.ID, cfg, warn) {
+			return true
+		}
+		return missing && !lacksField(snap.Data(), cfg.missing)
+	}
+
+	var schema *collectionSchema
+	discover := cfg.format == "parquet" || (cfg.format != "jsonl" && !fixedHeader(cfg))
+	if discover {
+		fieldSet = make(map[string]struct{})
+		types := newSchemaBuilder()
+		kept := 0
+		sp := newSpinner(fmt.Sprintf("Scanning fields in %q... 0 documents", displayPath), !cfg.noSpinner)
+		sp.Start()
+		count, err := scan(sp, fmt.Sprintf("Scanning fields in %q...", displayPath), func(snap *firestore.DocumentSnapshot) error {
+			if skip(snap, false) {
+				return nil
+			}
+			kept++
+			raw := snap.Data()
+			if err := cfg.resolver.resolve(ctx, raw); err != nil {
+				return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+			}
+			records, err := shapeRows(raw, documentPath(snap.Ref), cfg)
+			if err != nil {
+				return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+			}
+			for _, data := range records {
+				for k := range data {
+					fieldSet[k] = struct{}{}
 				}
-				docRefs = append(docRefs, ref)
+				types.add(documentPath(snap.Ref), data)
+			}
+			collectRef(snap)
+			return nil
+		})
+		sp.Stop()
+		if err != nil {
+			printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
+			return exportResult{collection: displayPath, depth: depth, err: err}, nil
+		}
+		if missing || checkIDs {
+			count = kept
+		}
+		if count == 0 {
+			return emptyCollectionResult(ctx, colRefs, displayPath, depth, recurse, cfg)
+		}
+		s := types.build(displayPath)
+		schema = &s
+	}
+
+	// The output file is created on the first document, so an empty collection
+	// leaves no file behind even without a discovery pass. A failed export
+	// doesn't commit its Cloud Storage objects; see abortableUploads.
+	writerCfg, abort := abortableUploads(cfg)
+	defer abort()
+	var rw recordWriter
+	var filePath string
+	var sb *schemaBuilder
+	if cfg.emitSchema || cfg.validation != nil {
+		sb = newSchemaBuilder()
+	}
+	var bq *bigQuerySchema
+	if cfg.bigQuery {
+		bq = newBigQuerySchema()
+	}
+	// written is the reported document count: the rows actually written, which
+	// can differ from the discovery pass if documents change in between.
+	// kept counts the documents, which differ from the rows with --explode.
+	written, kept := 0, 0
+	sp := newSpinner(fmt.Sprintf("Writing %q... 0 documents", displayPath), !cfg.noSpinner)
+	sp.Start()
+	total, err := scan(sp, fmt.Sprintf("Writing %q...", displayPath), func(snap *firestore.DocumentSnapshot) error {
+		if verbosity >= verboseDocuments {
+			debugDocument(displayPath, snap)
+		}
+		if skip(snap, true) {
+			return nil
+		}
+		if rw == nil {
+			var err error
+			if rw, filePath, err = newRecordWriter(fieldSet, schema, displayPath, writerCfg); err != nil {
+				return err
+			}
+		}
+		raw := snap.Data()
+		if err := cfg.resolver.resolve(ctx, raw); err != nil {
+			return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+		}
+		records, err := prepareRows(raw, documentPath(snap.Ref), cfg)
+		if err != nil {
+			return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+		}
+		for _, data := range records {
+			if err := rw.write(newDocRecord(snap, data)); err != nil {
+				return err
+			}
+			if sb != nil {
+				sb.add(documentPath(snap.Ref), data)
 			}
+			if bq != nil {
+				bq.add(data)
+			}
+			written++
 		}
-		if len(docRefs) == 0 {
-			printInfo("Collection %q is empty, skipping.", displayPath)
-		} else {
-			printInfo("Collection %q has no documents with data, checking sub-collections...", displayPath)
+		kept++
+		if !discover {
+			collectRef(snap)
 		}
-		return exportResult{collection: displayPath, depth: depth}, docRefs
-	}
-
-	if san != nil {
-		for i := range docs {
-			san.sanitizeRecord(docs[i].data)
+		return nil
+	})
+	sp.Stop()
+	var parts []string
+	if rw != nil {
+		if err != nil && ctx.Err() == nil {
+			// An interrupted export keeps what it wrote; see stoppedResult.
+			abort()
 		}
+		if closeErr := rw.close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("closing %s: %w", filePath, closeErr)
+		}
+		parts = writtenFiles(rw)
+	}
+	if err != nil && ctx.Err() != nil && written > 0 {
+		result := stoppedResult(displayPath, depth, written, len(headerFields(fieldSet, cfg)), filePath, err)
+		result.parts, result.files = parts, documentFiles(written, cfg)
+		return result, nil
 	}
-
-	filePath, err := writeCollectionCSV(docs, fieldSet, displayPath, outputDir, withTypes)
 	if err != nil {
-		printErr("Failed to export %q: %v", displayPath, err)
+		printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
 		return exportResult{collection: displayPath, depth: depth, err: err}, nil
 	}
 
-	printOK("Exported %q — %s docs, %d fields → %s", displayPath, fmtInt(len(docs)), len(fieldSet), filePath)
+	if missing {
+		reportMissingField(displayPath, kept, total, cfg.missing)
+	}
+	if written == 0 {
+		// Either the collection is empty or every document disappeared between
+		// the two passes.
+		return emptyCollectionResult(ctx, colRefs, displayPath, depth, recurse, cfg)
+	}
+	if sb != nil {
+		schema := sb.build(displayPath)
+		if cfg.validation != nil {
+			cfg.validation.add(schema)
+		}
+		if cfg.emitSchema {
+			if _, err := writeSchemaFile(schema, displayPath, cfg); err != nil {
+				printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
+				return exportResult{collection: displayPath, depth: depth, err: err}, nil
+			}
+		}
+	}
+	if bq != nil {
+		if _, err := writeBigQuerySchema(bq, displayPath, cfg); err != nil {
+			printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
+			return exportResult{collection: displayPath, depth: depth, err: err}, nil
+		}
+	}
+
+	fieldCount := len(headerFields(fieldSet, cfg))
+	printOKFor(displayPath, "Exported %q — %s docs, %d fields → %s", displayPath, fmtInt(written), fieldCount, describeOutput(filePath, parts, documentFiles(written, cfg)))
 
 	return exportResult{
 		collection: displayPath,
 		depth:      depth,
-		docCount:   len(docs),
-		fieldCount: len(fieldSet),
+		docCount:   written,
+		fieldCount: fieldCount,
 		filePath:   filePath,
+		parts:      parts,
+		files:      documentFiles(written, cfg),
 	}, docRefs
 }
 
-// readAndExportAggregated reads documents from a sub-collection across multiple parent documents
-// and writes them into a single CSV.
-func readAndExportAggregated(ctx context.Context, parentRefs []*firestore.DocumentRef, subColName, displayPath string, depth, childLimit int, recurse bool, outputDir string, withTypes bool, san *sanitizer) (exportResult, []*firestore.DocumentRef) {
-	sp := newSpinner(fmt.Sprintf("Reading %q... 0 documents", displayPath))
-	sp.Start()
+// scanFunc reads the documents of a collection, calling fn for each one, and
+// returns how many were read. Progress is shown on sp after label.
+type scanFunc func(sp *spinner, label string, fn func(snap *firestore.DocumentSnapshot) error) (int, error)
 
-	fieldSet := make(map[string]struct{})
-	var docs []docRecord
-	var docRefs []*firestore.DocumentRef
+// queryScan returns a scanFunc that runs queries with scanDocuments.
+func queryScan(ctx context.Context, queries []firestore.Query, limit int, cfg exportConfig) scanFunc {
+	return func(sp *spinner, label string, fn func(snap *firestore.DocumentSnapshot) error) (int, error) {
+		return scanDocuments(ctx, queries, limit, cfg.pageSize, cfg.maxRetries, cfg.limiter, sp, label, fn)
+	}
+}
 
+// scanDocuments runs each query in turn and calls fn for every document,
+// updating the spinner with a running count prefixed by label. It returns the
+// number of documents read. limit is the per-query limit already applied to
+// the queries (0 = none); scanQuery needs it to resume after a retry.
+func scanDocuments(ctx context.Context, queries []firestore.Query, limit, pageSize, maxRetries int, limiter *rate.Limiter, sp *spinner, label string, fn func(snap *firestore.DocumentSnapshot) error) (int, error) {
+	// With a single limited query the limit bounds the total, so progress is
+	// shown as a bar; otherwise only the running count is known.
+	total := 0
+	if limit > 0 && len(queries) == 1 {
+		total = limit
+	}
 	count := 0
-	for _, parentRef := range parentRefs {
-		colRef := parentRef.Collection(subColName)
-		query := colRef.Query
-		if childLimit > 0 {
-			query = query.Limit(childLimit)
+	for _, query := range queries {
+		err := scanQuery(ctx, query, limit, pageSize, maxRetries, limiter, func(snap *firestore.DocumentSnapshot) error {
+			// Documents of a page already fetched are not read once ctx is
+			// cancelled.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(snap); err != nil {
+				return err
+			}
+			count++
+			if total > 0 {
+				sp.SetSuffix(fmt.Sprintf("%s %s", label, progressBar(count, total)))
+			} else {
+				sp.SetSuffix(fmt.Sprintf("%s %s documents", label, fmtInt(count)))
+			}
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// scanQuery calls fn for every document of query. Transient errors from the
+// iterator are retried up to maxRetries consecutive times with exponential
+// backoff, resuming after the last document read so nothing is read twice.
+// Errors returned by fn are never retried. With a pageSize, the query is run
+// in pages of at most pageSize documents, each starting after the last one.
+// A non-nil limiter paces the reads; see waitForRead.
+func scanQuery(ctx context.Context, query firestore.Query, limit, pageSize, maxRetries int, limiter *rate.Limiter, fn func(snap *firestore.DocumentSnapshot) error) error {
+	var last *firestore.DocumentSnapshot
+	read, attempt := 0, 0
+	for {
+		q := query
+		if last != nil {
+			q = query.StartAfter(last)
+		}
+		if n := pageLimit(limit, read, pageSize); n > 0 {
+			q = q.Limit(n)
 		}
 
-		iter := query.Documents(ctx)
+		iter := q.Documents(ctx)
+		var err error
+		pageRead := 0
 		for {
-			snap, err := iter.Next()
-			if err == iterator.Done {
+			var snap *firestore.DocumentSnapshot
+			if err = waitForRead(ctx, limiter); err != nil {
 				break
 			}
-			if err != nil {
+			if snap, err = iter.Next(); err != nil {
+				break
+			}
+			if err := fn(snap); err != nil {
 				iter.Stop()
-				sp.Stop()
-				printErr("Failed to export %q: %v", displayPath, err)
-				return exportResult{collection: displayPath, depth: depth, err: err}, nil
+				return err
 			}
-			data := snap.Data()
-			for k := range data {
-				fieldSet[k] = struct{}{}
+			last = snap
+			read++
+			pageRead++
+			attempt = 0
+		}
+		iter.Stop()
+
+		if limit > 0 && read >= limit {
+			return nil
+		}
+		if err == iterator.Done {
+			if pageSize > 0 && pageRead >= pageSize {
+				// A full page; there may be more documents after it.
+				continue
 			}
-			docs = append(docs, docRecord{path: documentPath(snap.Ref), data: data})
-			if recurse {
-				docRefs = append(docRefs, snap.Ref)
+			return nil
+		}
+		if !isRetryable(err) {
+			return err
+		}
+		if attempt >= maxRetries {
+			if maxRetries > 0 {
+				return fmt.Errorf("giving up after %d retries: %w", maxRetries, err)
 			}
-			count++
-			sp.SetSuffix(fmt.Sprintf("Reading %q... %s documents", displayPath, fmtInt(count)))
+			return err
+		}
+		attempt++
+		delay := retryDelay(attempt)
+		printInfo("Transient error (%v), retrying in %s (%d/%d)...", status.Code(err), delay, attempt, maxRetries)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
 		}
-		iter.Stop()
 	}
+}
 
-	sp.Stop()
+// pageLimit returns the limit for the next query run by scanQuery: what is
+// left of limit after read documents, capped at pageSize. 0 means no limit.
+func pageLimit(limit, read, pageSize int) int {
+	n := 0
+	if limit > 0 {
+		n = limit - read
+	}
+	if pageSize > 0 && (n == 0 || pageSize < n) {
+		n = pageSize
+	}
+	return n
+}
 
-	if len(docs) == 0 {
-		// Even if there are no documents with data, there may be virtual
-		// documents that act as containers for sub-collections.
-		if recurse {
-			for _, parentRef := range parentRefs {
-				colRef := parentRef.Collection(subColName)
-				refIter := colRef.DocumentRefs(ctx)
-				for {
-					ref, err := refIter.Next()
-					if err == iterator.Done {
-						break
-					}
-					if err != nil {
-						printErr("Failed to list document refs for %q: %v", displayPath, err)
-						break
-					}
-					docRefs = append(docRefs, ref)
+// emptyCollectionResult reports a collection whose queries returned no
+// documents. There may still be virtual documents that act as containers for
+// sub-collections, so when recursing it lists document refs and returns them
+// for sub-collection discovery. With --empty-files the collection still gets
+// an output file; see writeEmptyCollection.
+func emptyCollectionResult(ctx context.Context, colRefs []*firestore.CollectionRef, displayPath string, depth int, recurse bool, cfg exportConfig) (exportResult, []*firestore.DocumentRef) {
+	var docRefs []*firestore.DocumentRef
+	if recurse {
+		for _, colRef := range colRefs {
+			refIter := colRef.DocumentRefs(ctx)
+			for {
+				ref, err := refIter.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					printErrFor(displayPath, "Failed to list document refs for %q: %v", displayPath, err)
+					break
 				}
+				docRefs = append(docRefs, ref)
 			}
 		}
-		if len(docRefs) == 0 {
-			printInfo("Collection %q is empty, skipping.", displayPath)
-		} else {
-			printInfo("Collection %q has no documents with data, checking sub-collections...", displayPath)
-		}
-		return exportResult{collection: displayPath, depth: depth}, docRefs
 	}
-
-	if san != nil {
-		for i := range docs {
-			san.sanitizeRecord(docs[i].data)
+	result := exportResult{collection: displayPath, depth: depth}
+	if cfg.emptyFiles && !cfg.dryRun && cfg.combined == nil {
+		filePath, fields, err := writeEmptyCollection(displayPath, cfg)
+		if err != nil {
+			printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
+			return exportResult{collection: displayPath, depth: depth, err: err}, docRefs
 		}
+		printInfoFor(displayPath, "Collection %q is empty, wrote %s without rows", displayPath, filePath)
+		result.filePath, result.fieldCount = filePath, fields
+	} else if len(docRefs) == 0 {
+		printInfoFor(displayPath, "Collection %q is empty, skipping.", displayPath)
 	}
-
-	filePath, err := writeCollectionCSV(docs, fieldSet, displayPath, outputDir, withTypes)
-	if err != nil {
-		printErr("Failed to export %q: %v", displayPath, err)
-		return exportResult{collection: displayPath, depth: depth, err: err}, nil
+	if len(docRefs) > 0 {
+		printInfoFor(displayPath, "Collection %q has no documents with data, checking sub-collections...", displayPath)
 	}
+	return result, docRefs
+}
 
-	printOK("Exported %q — %s docs, %d fields → %s", displayPath, fmtInt(len(docs)), len(fieldSet), filePath)
+// prepareRecord applies the configured per-document transformations to the data
+// read from Firestore for the document at docPath and returns the record to
+// export: --sanitize, then --cast, then shapeRecord.
+func prepareRecord(data map[string]any, docPath string, cfg exportConfig) (map[string]any, error) {
+	if cfg.sanitizer != nil {
+		cfg.sanitizer.sanitizeLocked(data)
+	}
+	return shapeRecord(castFields(data, docPath, cfg), cfg)
+}
 
-	return exportResult{
-		collection: displayPath,
-		depth:      depth,
-		docCount:   len(docs),
-		fieldCount: len(fieldSet),
-		filePath:   filePath,
-	}, docRefs
+// shapeRecord applies the transformations that decide which columns a record
+// produces. Unlike prepareRecord it has no side effects, so the streaming
+// field-discovery pass can call it without consuming sanitizer randomness.
+//
+// --hash-fields and --redact-fields are applied first, so a masked map is a
+// single column. --flatten expands nested maps into dotted keys
+// (address.city), at any depth; arrays and other values are kept as-is, and
+// an empty nested map is kept under its own key so the field doesn't
+// disappear from the output.
+// --flatten-arrays likewise expands arrays into indexed keys (tags.0), up to
+// --max-array-index.
+// --geopoint-columns replaces each GeoPoint field loc with numeric loc.lat and
+// loc.lng fields, which with --flatten also covers GeoPoints nested in maps.
+// Fields that end up in the same column are handled per --on-collision.
+// --compute columns are then evaluated against the masked fields, and
+// --bigquery-json renames the result for BigQuery. The input map is not
+// modified.
+func shapeRecord(data map[string]any, cfg exportConfig) (map[string]any, error) {
+	data = maskFields(data, cfg)
+	if !cfg.flatten && !cfg.flattenArrays && !cfg.geoColumns && len(cfg.rename) == 0 && len(cfg.excludeFields) == 0 && len(cfg.compute) == 0 && !cfg.bigQuery {
+		// Field names are unique, so only the reserved columns can collide.
+		collides := false
+		for _, col := range reservedColumns(cfg) {
+			if _, ok := data[col]; ok {
+				collides = true
+			}
+		}
+		if !collides {
+			return data, nil
+		}
+	}
+	cols := newColumnSet(len(data), cfg)
+	if err := cols.addFields(nil, data); err != nil {
+		return nil, err
+	}
+	computeColumns(cols.out, data, cfg)
+	if cfg.bigQuery {
+		return bigQueryRecord(cols.out, cfg)
+	}
+	return cols.out, nil
 }
 
 // discoverSubCollections finds all sub-collections across the given document refs.
@@ -686,63 +2784,6 @@ func discoverSubCollections(ctx context.Context, docRefs []*firestore.DocumentRe
 	return subCols
 }
 
-// writeCollectionCSV writes document records to a CSV file.
-func writeCollectionCSV(docs []docRecord, fieldSet map[string]struct{}, displayPath, outputDir string, withTypes bool) (string, error) {
-	fields := make([]string, 0, len(fieldSet))
-	for k := range fieldSet {
-		fields = append(fields, k)
-	}
-	sort.Strings(fields)
-	headers := append([]string{"__path__"}, fields...)
-	if withTypes {
-		headers = append(headers, "__fs_types__")
-	}
-
-	filePath := filepath.Join(outputDir, filepath.FromSlash(displayPath)+".csv")
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return "", fmt.Errorf("creating directory for %s: %w", filePath, err)
-	}
-
-	f, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("creating file %s: %w", filePath, err)
-	}
-	defer f.Close()
-
-	w := csv.NewWriter(f)
-	defer w.Flush()
-
-	if err := w.Write(headers); err != nil {
-		return "", fmt.Errorf("writing header: %w", err)
-	}
-
-	for _, doc := range docs {
-		row := make([]string, len(headers))
-		row[0] = doc.path
-		typeMap := make(map[string]string, len(fields))
-		for i, h := range fields {
-			val, ok := doc.data[h]
-			if !ok || val == nil {
-				row[i+1] = ""
-				continue
-			}
-			row[i+1] = formatValue(val)
-			if withTypes {
-				typeMap[h] = typeLabel(val)
-			}
-		}
-		if withTypes {
-			b, _ := json.Marshal(typeMap)
-			row[len(row)-1] = string(b)
-		}
-		if err := w.Write(row); err != nil {
-			return "", fmt.Errorf("writing row: %w", err)
-		}
-	}
-
-	return filePath, nil
-}
-
 func sortedKeys[V any](m map[string]V) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
@@ -752,50 +2793,186 @@ func sortedKeys[V any](m map[string]V) []string {
 	return keys
 }
 
-func printSummaryTable(results []exportResult) {
-	if len(results) == 0 {
+// printSummaryTable prints the run summary to stderr, one row per collection.
+// With totals and more than one collection, a last row sums the docs and
+// sizes; field counts aren't added up, since collections often share fields.
+func printSummaryTable(results []exportResult, totals bool) {
+	if len(results) == 0 || logJSON {
 		return
 	}
 
-	// Calculate column widths
-	colW, docW, fldW, fileW := len("Collection"), len("Docs"), len("Fields"), len("Output File")
+	dbW := 0 // no Database column unless several databases were exported
 	rows := make([][]string, len(results))
 	for i, r := range results {
-		fp := r.filePath
+		if r.database != "" {
+			dbW = max(dbW, len("Database"), len(r.database))
+		}
+		fp := describeOutput(r.filePath, r.parts, r.files)
 		if fp == "" {
 			fp = "-"
+		} else if r.partial {
+			fp += " " + partialNote
+		} else if r.hookErr != nil {
+			fp += " " + hookNote
 		}
 		docs := fmtInt(r.docCount)
 		fields := fmtInt(r.fieldCount)
+		size := "-"
+		if n, ok := outputSize(r); ok {
+			size = fmtBytes(n)
+		}
 		indent := strings.Repeat("  ", r.depth)
 		displayName := indent + r.collection
-		rows[i] = []string{displayName, docs, fields, fp}
-		if len(displayName) > colW {
-			colW = len(displayName)
-		}
-		if len(docs) > docW {
-			docW = len(docs)
-		}
-		if len(fields) > fldW {
-			fldW = len(fields)
+		rows[i] = []string{displayName, docs, fields, size, fp}
+	}
+	widths := columnWidths([]string{"Collection", "Docs", "Fields", "Size", "Output File"}, rows)
+	colW, docW, fldW, sizeW, fileW := widths[0], widths[1], widths[2], widths[3], widths[4]
+	var total []string
+	if totals && len(results) > 1 {
+		docs := 0
+		var size int64
+		sized := false
+		for _, r := range results {
+			docs += r.docCount
+			if n, ok := outputSize(r); ok {
+				size += n
+				sized = true
+			}
 		}
-		if len(fp) > fileW {
-			fileW = len(fp)
+		total = []string{fmt.Sprintf("Total (%d collection(s))", len(results)), fmtInt(docs), ""}
+		if sized {
+			total[2] = fmtBytes(size)
 		}
+		colW = max(colW, len(total[0]))
+		docW = max(docW, len(total[1]))
+		sizeW = max(sizeW, len(total[2]))
 	}
 
 	fmt.Fprintln(os.Stderr)
 	// Header
-	fmt.Fprintf(os.Stderr, " %-*s  %*s  %*s  %-*s\n",
-		colW, bold("Collection"), docW, bold("Docs"), fldW, bold("Fields"), fileW, bold("Output File"))
+	if dbW > 0 {
+		fmt.Fprintf(os.Stderr, " %-*s ", dbW, bold("Database"))
+	}
+	fmt.Fprintf(os.Stderr, " %-*s  %*s  %*s  %*s  %-*s\n",
+		colW, bold("Collection"), docW, bold("Docs"), fldW, bold("Fields"), sizeW, bold("Size"), fileW, bold("Output File"))
 	// Separator
-	fmt.Fprintf(os.Stderr, " %s  %s  %s  %s\n",
-		faint(strings.Repeat("─", colW)), faint(strings.Repeat("─", docW)), faint(strings.Repeat("─", fldW)), faint(strings.Repeat("─", fileW)))
+	separator := func() {
+		if dbW > 0 {
+			fmt.Fprintf(os.Stderr, " %s ", faint(strings.Repeat("─", dbW)))
+		}
+		fmt.Fprintf(os.Stderr, " %s  %s  %s  %s  %s\n",
+			faint(strings.Repeat("─", colW)), faint(strings.Repeat("─", docW)), faint(strings.Repeat("─", fldW)), faint(strings.Repeat("─", sizeW)), faint(strings.Repeat("─", fileW)))
+	}
+	separator()
 	// Rows
+	for i, row := range rows {
+		if dbW > 0 {
+			fmt.Fprintf(os.Stderr, " %-*s ", dbW, results[i].database)
+		}
+		fmt.Fprintf(os.Stderr, " %-*s  %*s  %*s  %*s  %-*s\n",
+			colW, row[0], docW, row[1], fldW, row[2], sizeW, row[3], fileW, row[4])
+	}
+	if total != nil {
+		separator()
+		if dbW > 0 {
+			fmt.Fprintf(os.Stderr, " %-*s ", dbW, "")
+		}
+		// Padded before bold() adds its escape codes, which would count
+		// towards the width. The fields and file columns stay empty.
+		line := fmt.Sprintf(" %s  %s", bold(fmt.Sprintf("%-*s", colW, total[0])), bold(fmt.Sprintf("%*s", docW, total[1])))
+		if total[2] != "" {
+			line += fmt.Sprintf("  %*s  %s", fldW, "", bold(fmt.Sprintf("%*s", sizeW, total[2])))
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// outputSize returns the size in bytes of the local files a collection was
+// written to, after any compression: its file, or all of its --max-file-size
+// parts. It reports false for stdout, Cloud Storage and --split-documents
+// output, and when nothing was written.
+func outputSize(r exportResult) (int64, bool) {
+	if r.filePath == "" || r.filePath == stdoutPath || r.files > 0 || isGCSURL(r.filePath) {
+		return 0, false
+	}
+	files := r.parts
+	if len(files) == 0 {
+		files = []string{r.filePath}
+	}
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return 0, false
+		}
+		size += info.Size()
+	}
+	return size, true
+}
+
+// columnWidths returns the width of each column of a terminal table: its
+// longest cell or header, in runes.
+func columnWidths(header []string, rows [][]string) []int {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = utf8.RuneCountInString(h)
+	}
 	for _, row := range rows {
-		fmt.Fprintf(os.Stderr, " %-*s  %*s  %*s  %-*s\n",
-			colW, row[0], docW, row[1], fldW, row[2], fileW, row[3])
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	return widths
+}
+
+// writeSummaryCSV writes the run summary to w as CSV, or TSV when tab is set,
+// for --summary-format csv and tsv. Unlike printSummaryTable it goes to
+// stdout, so it can be piped into other tools while logs stay on stderr.
+func writeSummaryCSV(w io.Writer, results []exportResult, tab bool) error {
+	cw := csv.NewWriter(w)
+	if tab {
+		cw.Comma = '\t'
+	}
+	// A database column leads when several databases were exported, and a
+	// parts column follows with --max-file-size.
+	withDB, withParts := false, false
+	for _, r := range results {
+		withDB = withDB || r.database != ""
+		withParts = withParts || len(r.parts) > 0
+	}
+	header := []string{"collection", "depth", "docs", "fields", "file", "error"}
+	if withDB {
+		header = append([]string{"database"}, header...)
+	}
+	if withParts {
+		header = append(header, "parts")
+	}
+	if err := cw.Write(header); err != nil {
+		return err
 	}
+	for _, r := range results {
+		var errMsg string
+		if r.err != nil {
+			errMsg = r.err.Error()
+		}
+		if r.partial {
+			errMsg = partialNote + ": " + errMsg
+		} else if r.hookErr != nil {
+			errMsg = hookNote + ": " + r.hookErr.Error()
+		}
+		row := []string{r.collection, strconv.Itoa(r.depth), strconv.Itoa(r.docCount), strconv.Itoa(r.fieldCount), r.filePath, errMsg}
+		if withDB {
+			row = append([]string{r.database}, row...)
+		}
+		if withParts {
+			row = append(row, strconv.Itoa(len(r.parts)))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
 }
 
 // typeLabel returns the Firestore type label for a value.
@@ -826,71 +3003,313 @@ func typeLabel(v any) string {
 	}
 }
 
+// timeFormatPresets maps the named --time-format presets to Go layouts.
+// The epoch formats are handled separately since they are not layouts.
+var timeFormatPresets = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"date":        time.DateOnly,
+	"datetime":    time.DateTime,
+}
+
+// Values of --time-format that write timestamps as numbers: epoch seconds
+// (also accepted as "epoch-s") and epoch milliseconds.
+const (
+	timeFormatUnix   = "unix"
+	timeFormatMillis = "epoch-ms"
+)
+
+// isEpochFormat reports whether timestamps in the resolved --time-format
+// format are written as integers.
+func isEpochFormat(format string) bool {
+	return format == timeFormatUnix || format == timeFormatMillis
+}
+
+// Values accepted by --number-format.
+const (
+	numberFormatNative = "native"
+	numberFormatFloat  = "always-float"
+	numberFormatInt    = "always-int"
+)
+
+// Values accepted by --ref-format.
+const (
+	refFormatPath     = "path"
+	refFormatRelative = "relative"
+	refFormatID       = "id"
+)
+
+// Values accepted by --bytes-format.
+const (
+	bytesFormatBase64 = "base64"
+	bytesFormatHex    = "hex"
+	bytesFormatSkip   = "skip"
+)
+
+// resolveTimeFormat turns a --time-format value into a Go layout, expanding
+// named presets. Anything else is used as a layout verbatim, so an invalid
+// layout is written out literally, as time.Format does.
+func resolveTimeFormat(raw string) string {
+	if raw == "" {
+		return time.RFC3339Nano
+	}
+	if raw == "epoch-s" {
+		return timeFormatUnix
+	}
+	if layout, ok := timeFormatPresets[raw]; ok {
+		return layout
+	}
+	return raw
+}
+
+// valueFormatter converts Firestore values for output. The zero value uses
+// the default representations documented in the README.
+type valueFormatter struct {
+	timeFormat  string // Go layout, timeFormatUnix or timeFormatMillis; empty means RFC3339Nano
+	nullValue   string // CSV cell for null or missing fields
+	arrayDelim  string // joins scalar arrays in CSV cells; empty means JSON
+	numberFmt   string // --number-format; empty means numberFormatNative
+	grouping    *numberGrouping
+	roundFloats bool // round floats to floatPrec decimal places (--float-precision)
+	floatPrec   int
+	refFormat   string // --ref-format; empty means refFormatPath
+	bytesFormat string // --bytes-format; empty means bytesFormatBase64
+	maxCellSize int    // CSV cells longer than this many bytes are truncated; 0 means no limit
+}
+
+// newValueFormatter returns the valueFormatter of cfg's formatting options.
+// Every writer and --preview builds its formatter here, so a new option can't
+// be left out of one of them.
+func newValueFormatter(cfg exportConfig) valueFormatter {
+	return valueFormatter{
+		timeFormat:  cfg.timeFormat,
+		nullValue:   cfg.nullValue,
+		arrayDelim:  cfg.arrayDelim,
+		numberFmt:   cfg.numberFmt,
+		grouping:    cfg.grouping,
+		roundFloats: cfg.roundFloats,
+		floatPrec:   cfg.floatPrec,
+		refFormat:   cfg.refFormat,
+		bytesFormat: cfg.bytesFormat,
+		maxCellSize: cfg.maxCellSize,
+	}
+}
+
+// truncatedMarker is appended to cells cut short by --max-cell-size.
+const truncatedMarker = "…[truncated]"
+
+// nestedArrayWarning makes sure the --array-format delimited fallback to JSON
+// is only reported once per run.
+var nestedArrayWarning sync.Once
+
+// lossyIntWarning reports, once per run, that --number-format always-int
+// dropped the fractional part of a float.
+var lossyIntWarning sync.Once
+
+// formatTime returns a timestamp as a string, or as int64 epoch seconds for
+// timeFormatUnix and epoch milliseconds for timeFormatMillis. Nested values
+// get the same format through convertForJSON.
+func (vf valueFormatter) formatTime(t time.Time) any {
+	switch vf.timeFormat {
+	case "":
+		return t.Format(time.RFC3339Nano)
+	case timeFormatUnix:
+		return t.Unix()
+	case timeFormatMillis:
+		return t.UnixMilli()
+	default:
+		return t.Format(vf.timeFormat)
+	}
+}
+
 func formatValue(v any) string {
+	return valueFormatter{}.formatValue(v)
+}
+
+func (vf valueFormatter) formatValue(v any) string {
 	switch val := v.(type) {
 	case nil:
-		return ""
+		return vf.nullValue
 	case bool:
 		if val {
 			return "true"
 		}
 		return "false"
 	case int64:
-		return strconv.FormatInt(val, 10)
+		if vf.numberFmt == numberFormatFloat {
+			return vf.grouping.format(strconv.FormatInt(val, 10) + ".0")
+		}
+		return vf.grouping.format(strconv.FormatInt(val, 10))
 	case float64:
-		return strconv.FormatFloat(val, 'f', -1, 64)
+		return vf.grouping.format(vf.formatFloat(val))
 	case string:
 		return val
 	case time.Time:
-		return val.Format(time.RFC3339Nano)
+		return fmt.Sprint(vf.formatTime(val))
 	case *latlng.LatLng:
-		b, _ := json.Marshal(map[string]float64{
+		b, _ := marshalSorted(map[string]float64{
 			"lat": val.GetLatitude(),
 			"lng": val.GetLongitude(),
 		})
 		return string(b)
 	case []byte:
-		return base64.StdEncoding.EncodeToString(val)
+		return vf.formatBytes(val)
 	case *firestore.DocumentRef:
-		return val.Path
+		return vf.formatRef(val)
 	case []any:
-		b, _ := json.Marshal(convertForJSON(v))
+		if vf.arrayDelim != "" {
+			if s, ok := vf.joinScalars(val); ok {
+				return s
+			}
+			nestedArrayWarning.Do(func() {
+				printInfo("Arrays holding maps or arrays are written as JSON, even with --array-format delimited")
+			})
+		}
+		b, _ := marshalSorted(vf.convertForJSON(v))
 		return string(b)
 	case map[string]any:
-		b, _ := json.Marshal(convertForJSON(v))
+		b, _ := marshalSorted(vf.convertForJSON(v))
 		return string(b)
 	default:
 		return fmt.Sprintf("%v", v)
 	}
 }
 
+// formatFloat renders a float with vf.precision() decimal places, according to
+// vf.numberFmt: always-float keeps a ".0" on whole numbers, and always-int
+// drops the fractional part, warning once if that loses information. NaN and
+// infinities are written as is.
+func (vf valueFormatter) formatFloat(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if vf.numberFmt == numberFormatInt {
+		t := math.Trunc(f)
+		if t != f {
+			lossyIntWarning.Do(func() {
+				printWarn("--number-format always-int dropped the fractional part of float values, e.g. %v", f)
+			})
+		}
+		if t == 0 {
+			t = 0 // no "-0"
+		}
+		return strconv.FormatFloat(t, 'f', 0, 64)
+	}
+	s := strconv.FormatFloat(f, 'f', vf.precision(), 64)
+	if vf.numberFmt == numberFormatFloat && !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// precision returns the precision argument for strconv.FormatFloat: -1 (the
+// fewest digits that round-trip) unless --float-precision is set.
+func (vf valueFormatter) precision() int {
+	if vf.roundFloats {
+		return vf.floatPrec
+	}
+	return -1
+}
+
+// roundFloat rounds f to --float-precision decimal places for JSON output,
+// going through the decimal string so that 0.30000000000000004 becomes 0.3.
+func (vf valueFormatter) roundFloat(f float64) float64 {
+	if !vf.roundFloats || math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	r, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'f', vf.floatPrec, 64), 64)
+	return r
+}
+
+// formatRef renders a document reference according to vf.refFormat.
+func (vf valueFormatter) formatRef(ref *firestore.DocumentRef) string {
+	switch vf.refFormat {
+	case refFormatID:
+		return ref.ID
+	case refFormatRelative:
+		return documentPath(ref)
+	default:
+		return ref.Path
+	}
+}
+
+// formatBytes encodes a Bytes value according to vf.bytesFormat. Skipped
+// values are empty; convertForJSON makes them null instead.
+func (vf valueFormatter) formatBytes(b []byte) string {
+	switch vf.bytesFormat {
+	case bytesFormatHex:
+		return hex.EncodeToString(b)
+	case bytesFormatSkip:
+		return ""
+	default:
+		return base64.StdEncoding.EncodeToString(b)
+	}
+}
+
+// truncate cuts a formatted cell down to vf.maxCellSize bytes, backing off to a
+// rune boundary, and appends truncatedMarker. It reports whether s was cut.
+func (vf valueFormatter) truncate(s string) (string, bool) {
+	if vf.maxCellSize <= 0 || len(s) <= vf.maxCellSize {
+		return s, false
+	}
+	n := vf.maxCellSize
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + truncatedMarker, true
+}
+
+// joinScalars joins the elements of an array with vf.arrayDelim. It reports
+// false if an element is a map, an array, or a GeoPoint, which can't be
+// written as a plain value.
+func (vf valueFormatter) joinScalars(arr []any) (string, bool) {
+	parts := make([]string, len(arr))
+	for i, elem := range arr {
+		switch elem.(type) {
+		case []any, map[string]any, *latlng.LatLng:
+			return "", false
+		}
+		parts[i] = vf.formatValue(elem)
+	}
+	return strings.Join(parts, vf.arrayDelim), true
+}
+
 func convertForJSON(v any) any {
+	return valueFormatter{}.convertForJSON(v)
+}
+
+func (vf valueFormatter) convertForJSON(v any) any {
 	switch val := v.(type) {
 	case nil:
 		return nil
-	case bool, int64, float64, string:
+	case float64:
+		return vf.roundFloat(val)
+	case bool, int64, string:
 		return val
 	case time.Time:
-		return val.Format(time.RFC3339Nano)
+		return vf.formatTime(val)
 	case *latlng.LatLng:
 		return map[string]float64{
 			"lat": val.GetLatitude(),
 			"lng": val.GetLongitude(),
 		}
 	case []byte:
-		return base64.StdEncoding.EncodeToString(val)
+		if vf.bytesFormat == bytesFormatSkip {
+			return nil
+		}
+		return vf.formatBytes(val)
 	case *firestore.DocumentRef:
-		return val.Path
+		return vf.formatRef(val)
 	case []any:
 		out := make([]any, len(val))
 		for i, elem := range val {
-			out[i] = convertForJSON(elem)
+			out[i] = vf.convertForJSON(elem)
 		}
 		return out
 	case map[string]any:
 		out := make(map[string]any, len(val))
 		for k, elem := range val {
-			out[k] = convertForJSON(elem)
+			out[k] = vf.convertForJSON(elem)
 		}
 		return out
 	default:
@@ -1026,6 +3445,7 @@ func parseCSVFile(path string) ([]importRecord, error) {
 	}
 
 	headers := rows[0]
+	headers[0] = strings.TrimPrefix(headers[0], utf8BOM)
 
 	// Find special column indices
 	pathIdx := -1
@@ -1038,18 +3458,22 @@ func parseCSVFile(path string) ([]importRecord, error) {
 			typesIdx = i
 		}
 	}
+	// Document timestamps are set by Firestore and can't be imported.
+	// __collection__, from --single-file, is implied by __path__.
+	// So are the parent columns of --path-columns.
+	skip := map[string]bool{"__create_time__": true, "__update_time__": true, "__collection__": true}
 	if pathIdx < 0 {
 		return nil, fmt.Errorf("CSV file %s is missing required __path__ column", path)
 	}
 
-	// Identify data field columns (exclude __path__ and __fs_types__)
+	// Identify data field columns (exclude __path__, __fs_types__ and timestamps)
 	type fieldCol struct {
 		name string
 		idx  int
 	}
 	var dataFields []fieldCol
 	for i, h := range headers {
-		if i == pathIdx || i == typesIdx {
+		if i == pathIdx || i == typesIdx || skip[h] || isParentColumn(h) {
 			continue
 		}
 		dataFields = append(dataFields, fieldCol{name: h, idx: i})
@@ -1121,12 +3545,14 @@ func parseCSVFile(path string) ([]importRecord, error) {
 }
 
 type importConfig struct {
-	project    string
-	database   string
-	emulator   string
-	inputs     []string
-	onConflict string
-	dryRun     bool
+	project     string
+	database    string
+	emulator    string
+	credentials string
+	endpoint    endpointOptions // --endpoint and --no-auth
+	inputs      []string
+	onConflict  string
+	dryRun      bool
 }
 
 var validConflictStrategies = map[string]bool{
@@ -1138,23 +3564,36 @@ func runImportCmd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := requireSingleDatabase(database, "import"); err != nil {
+		return err
+	}
 
 	f := cmd.Flags()
 	inputs, _ := f.GetStringSlice("input")
 	onConflict, _ := f.GetString("on-conflict")
 	dryRun, _ := f.GetBool("dry-run")
+	credentials, err := credentialsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	endpoint, err := endpointFromFlags(cmd, emulator, credentials)
+	if err != nil {
+		return err
+	}
 
 	if !validConflictStrategies[onConflict] {
 		return fmt.Errorf("invalid --on-conflict value %q: must be one of skip, overwrite, merge, fail", onConflict)
 	}
 
 	return runImport(importConfig{
-		project:    project,
-		database:   database,
-		emulator:   emulator,
-		inputs:     inputs,
-		onConflict: onConflict,
-		dryRun:     dryRun,
+		project:     project,
+		database:    database,
+		emulator:    emulator,
+		credentials: credentials,
+		endpoint:    endpoint,
+		inputs:      inputs,
+		onConflict:  onConflict,
+		dryRun:      dryRun,
 	})
 }
 
@@ -1211,7 +3650,7 @@ type importSummary struct {
 }
 
 func runImport(cfg importConfig) error {
-	fmt.Fprintln(os.Stderr)
+	printText("\n")
 
 	// Step 1: Discover CSV files
 	csvFiles, err := discoverCSVFiles(cfg.inputs)
@@ -1222,17 +3661,14 @@ func runImport(cfg importConfig) error {
 		return fmt.Errorf("no CSV files found in the specified inputs")
 	}
 
-	displayProject := cfg.project
-	if cfg.emulator != "" {
-		displayProject = fmt.Sprintf("emulator @ %s", cfg.emulator)
-	}
+	displayProject := describeConnection(cfg.project, cfg.emulator, cfg.endpoint)
 	mode := cfg.onConflict
 	if cfg.dryRun {
 		mode += " (dry-run)"
 	}
 	printInfo("Importing to %s (database: %s, conflict: %s)", bold(displayProject), bold(cfg.database), bold(mode))
 	printInfo("Found %d CSV file(s)", len(csvFiles))
-	fmt.Fprintln(os.Stderr)
+	printText("\n")
 
 	// Step 2: Parse all CSV files
 	var allRecords []importRecord
@@ -1254,7 +3690,7 @@ func runImport(cfg importConfig) error {
 	ctx := context.Background()
 	var client *firestore.Client
 	if !cfg.dryRun {
-		client, err = newFirestoreClient(ctx, cfg.project, cfg.database, cfg.emulator)
+		client, err = newFirestoreClient(ctx, cfg.project, cfg.database, cfg.emulator, cfg.credentials, cfg.endpoint)
 		if err != nil {
 			return fmt.Errorf("failed to create Firestore client: %w", err)
 		}
@@ -1277,7 +3713,7 @@ func runImport(cfg importConfig) error {
 		if len(conflicts) > 0 {
 			printErr("Found %d existing document(s) — aborting import:", len(conflicts))
 			for _, p := range conflicts {
-				fmt.Fprintf(os.Stderr, "  - %s\n", p)
+				printText("  - %s\n", p)
 			}
 			return fmt.Errorf("import aborted: %d conflicting document(s)", len(conflicts))
 		}
@@ -1311,7 +3747,7 @@ func runImport(cfg importConfig) error {
 			_, err := docRef.Get(ctx)
 			if err == nil {
 				// Document exists, skip it
-				fmt.Fprintf(os.Stderr, "  %s  %s (already exists, skipped)\n", faint("⊘"), rec.path)
+				printText("  %s  %s (already exists, skipped)\n", faint("⊘"), rec.path)
 				summary.skipped++
 				continue
 			}
@@ -1334,8 +3770,8 @@ func runImport(cfg importConfig) error {
 	}
 
 	// Step 6: Print summary
-	fmt.Fprintln(os.Stderr)
 	if cfg.dryRun {
+		printText("\n")
 		// Group by collection for dry-run report
 		collections := make(map[string]int)
 		for _, rec := range allRecords {
@@ -1348,10 +3784,9 @@ func runImport(cfg importConfig) error {
 		}
 		printInfo("Dry-run summary:")
 		for _, col := range sortedKeys(collections) {
-			fmt.Fprintf(os.Stderr, "  %s: %d document(s)\n", col, collections[col])
+			printText("  %s: %d document(s)\n", col, collections[col])
 		}
-		fmt.Fprintf(os.Stderr, "\n%s Would import %d document(s) total. No changes were made.\n",
-			green("✓"), summary.dryRun)
+		printDone(true, "Would import %d document(s) total. No changes were made.", summary.dryRun)
 	} else {
 		parts := []string{fmt.Sprintf("%d written", summary.written)}
 		if summary.skipped > 0 {
@@ -1360,12 +3795,15 @@ func runImport(cfg importConfig) error {
 		if summary.failed > 0 {
 			parts = append(parts, fmt.Sprintf("%d failed", summary.failed))
 		}
-		fmt.Fprintf(os.Stderr, "%s Import complete: %s (total: %d)\n",
-			green("✓"), strings.Join(parts, ", "), summary.total)
+		printDone(true, "Import complete: %s (total: %d)", strings.Join(parts, ", "), summary.total)
 	}
 
 	if summary.failed > 0 {
-		return fmt.Errorf("import completed with %d error(s)", summary.failed)
+		err := fmt.Errorf("import completed with %d error(s)", summary.failed)
+		if summary.written > 0 {
+			return &partialError{err}
+		}
+		return err
 	}
 	return nil
 }
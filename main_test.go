@@ -7,13 +7,17 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/firestore"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"google.golang.org/genproto/googleapis/type/latlng"
 )
@@ -63,6 +67,49 @@ func TestFmtInt(t *testing.T) {
 	}
 }
 
+func TestFmtBytes(t *testing.T) {
+	tests := []struct {
+		input int64
+		want  string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1000, "1.0 KB"},
+		{340_000, "340 KB"},
+		{999_499, "999 KB"},
+		{999_500, "1.0 MB"},
+		{1_234_567, "1.2 MB"},
+		{9_960_000, "10 MB"},
+		{5_000_000_000_000, "5.0 TB"},
+	}
+	for _, tt := range tests {
+		if got := fmtBytes(tt.input); got != tt.want {
+			t.Errorf("fmtBytes(%d) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	base := fmt.Errorf("export failed for 1 collection(s)")
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"failure", base, exitFailure},
+		{"partial", &partialError{base}, exitPartial},
+		{"wrapped partial", fmt.Errorf("running export: %w", &partialError{base}), exitPartial},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got := (&partialError{base}).Error(); got != base.Error() {
+		t.Errorf("partialError.Error() = %q, want %q", got, base.Error())
+	}
+}
+
 func TestFormatValue(t *testing.T) {
 	fixedTime := time.Date(2024, 6, 15, 12, 30, 0, 0, time.UTC)
 
@@ -176,6 +223,250 @@ func TestConvertForJSON_Map(t *testing.T) {
 	}
 }
 
+func TestResolveTimeFormat(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"", time.RFC3339Nano},
+		{"rfc3339nano", time.RFC3339Nano},
+		{"rfc3339", time.RFC3339},
+		{"date", "2006-01-02"},
+		{"datetime", "2006-01-02 15:04:05"},
+		{"unix", timeFormatUnix},
+		{"epoch-s", timeFormatUnix},
+		{"epoch-ms", timeFormatMillis},
+		{"02/01/2006", "02/01/2006"},
+	}
+	for _, tt := range tests {
+		if got := resolveTimeFormat(tt.raw); got != tt.want {
+			t.Errorf("resolveTimeFormat(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestValueFormatter_TimeFormat(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 123, time.UTC)
+	tests := []struct {
+		name       string
+		format     string
+		wantCSV    string
+		wantJSON   any
+		wantNested string
+	}{
+		{"default", "", "2024-01-15T10:30:00.000000123Z", "2024-01-15T10:30:00.000000123Z", `{"at":"2024-01-15T10:30:00.000000123Z"}`},
+		{"layout", time.DateTime, "2024-01-15 10:30:00", "2024-01-15 10:30:00", `{"at":"2024-01-15 10:30:00"}`},
+		{"unix", timeFormatUnix, "1705314600", int64(1705314600), `{"at":1705314600}`},
+		{"epoch-ms", timeFormatMillis, "1705314600000", int64(1705314600000), `{"at":1705314600000}`},
+		{"literal", "not a layout", "not a layout", "not a layout", `{"at":"not a layout"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vf := valueFormatter{timeFormat: tt.format}
+			if got := vf.formatValue(ts); got != tt.wantCSV {
+				t.Errorf("formatValue() = %q, want %q", got, tt.wantCSV)
+			}
+			if got := vf.convertForJSON(ts); got != tt.wantJSON {
+				t.Errorf("convertForJSON() = %v (%T), want %v (%T)", got, got, tt.wantJSON, tt.wantJSON)
+			}
+			if got := vf.formatValue(map[string]any{"at": ts}); got != tt.wantNested {
+				t.Errorf("formatValue(map) = %s, want %s", got, tt.wantNested)
+			}
+		})
+	}
+}
+
+func TestValueFormatter_EpochMillisNested(t *testing.T) {
+	vf := valueFormatter{timeFormat: timeFormatMillis}
+	at := time.Date(2024, 1, 15, 10, 30, 0, 250_000_000, time.UTC)
+	v := []any{at, map[string]any{"seen": []any{at}}}
+	if got, want := vf.formatValue(v), `[1705314600250,{"seen":[1705314600250]}]`; got != want {
+		t.Errorf("formatValue() = %s, want %s", got, want)
+	}
+}
+
+func TestValueFormatter_DelimitedArrays(t *testing.T) {
+	vf := valueFormatter{arrayDelim: "|", nullValue: `\N`}
+	tests := []struct {
+		name string
+		in   []any
+		want string
+	}{
+		{"strings", []any{"a", "b", "c"}, "a|b|c"},
+		{"mixed scalars", []any{int64(1), 2.5, true, nil}, `1|2.5|true|\N`},
+		{"empty", []any{}, ""},
+		{"nested map", []any{"a", map[string]any{"b": int64(1)}}, `["a",{"b":1}]`},
+		{"nested array", []any{[]any{"a"}}, `[["a"]]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vf.formatValue(tt.in); got != tt.want {
+				t.Errorf("formatValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Arrays nested in maps are part of a JSON cell and stay JSON.
+	if got := vf.formatValue(map[string]any{"tags": []any{"a", "b"}}); got != `{"tags":["a","b"]}` {
+		t.Errorf("formatValue(map) = %s, want JSON array", got)
+	}
+}
+
+func TestValueFormatter_NumberFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		in     any
+		want   string
+	}{
+		{numberFormatNative, int64(5), "5"},
+		{numberFormatNative, 5.0, "5"},
+		{numberFormatNative, 2.5, "2.5"},
+		{numberFormatFloat, int64(5), "5.0"},
+		{numberFormatFloat, 5.0, "5.0"},
+		{numberFormatFloat, 2.5, "2.5"},
+		{numberFormatInt, int64(5), "5"},
+		{numberFormatInt, 5.0, "5"},
+		{numberFormatInt, -0.5, "0"},
+		{numberFormatInt, 1e20, "100000000000000000000"},
+		{numberFormatInt, math.Inf(1), "+Inf"},
+	}
+	for _, tt := range tests {
+		vf := valueFormatter{numberFmt: tt.format}
+		if got := vf.formatValue(tt.in); got != tt.want {
+			t.Errorf("%s: formatValue(%v) = %q, want %q", tt.format, tt.in, got, tt.want)
+		}
+	}
+
+	// Elements of delimited arrays follow the same rule.
+	vf := valueFormatter{numberFmt: numberFormatFloat, arrayDelim: "|"}
+	if got := vf.formatValue([]any{int64(1), 2.0}); got != "1.0|2.0" {
+		t.Errorf("formatValue(array) = %q, want 1.0|2.0", got)
+	}
+}
+
+func TestValueFormatter_FloatPrecision(t *testing.T) {
+	// Variables, so the sum is computed in float64 rather than as a constant.
+	a, b := 0.1, 0.2
+	vf := valueFormatter{roundFloats: true, floatPrec: 2}
+	if got := vf.formatValue(a + b); got != "0.30" {
+		t.Errorf("formatValue(0.1+0.2) = %q, want 0.30", got)
+	}
+	if got := vf.formatValue(int64(7)); got != "7" {
+		t.Errorf("formatValue(int) = %q, want integers unchanged", got)
+	}
+	got := vf.convertForJSON(map[string]any{"price": 19.999, "qty": int64(2)})
+	if want := map[string]any{"price": 20.0, "qty": int64(2)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("convertForJSON() = %v, want %v", got, want)
+	}
+
+	if got := (valueFormatter{}).formatValue(a + b); got != "0.30000000000000004" {
+		t.Errorf("formatValue() without --float-precision = %q, want full precision", got)
+	}
+	if got := (valueFormatter{roundFloats: true}).formatValue(2.5); got != "2" {
+		t.Errorf("formatValue(2.5) with precision 0 = %q, want 2", got)
+	}
+}
+
+func TestValueFormatter_RefFormat(t *testing.T) {
+	ref := &firestore.DocumentRef{
+		Path: "projects/p/databases/(default)/documents/users/u1/orders/o1",
+		ID:   "o1",
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", ref.Path},
+		{refFormatPath, ref.Path},
+		{refFormatRelative, "users/u1/orders/o1"},
+		{refFormatID, "o1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			vf := valueFormatter{refFormat: tt.format}
+			if got := vf.formatValue(ref); got != tt.want {
+				t.Errorf("formatValue() = %q, want %q", got, tt.want)
+			}
+			// References nested in arrays and maps follow the same rule.
+			got := vf.convertForJSON(map[string]any{"refs": []any{ref}})
+			want := map[string]any{"refs": []any{tt.want}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("convertForJSON() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestValueFormatter_BytesFormat(t *testing.T) {
+	b := []byte{0xde, 0xad, 0xbe, 0xef}
+	tests := []struct {
+		format string
+		want   string
+		json   any
+	}{
+		{"", "3q2+7w==", "3q2+7w=="},
+		{bytesFormatBase64, "3q2+7w==", "3q2+7w=="},
+		{bytesFormatHex, "deadbeef", "deadbeef"},
+		{bytesFormatSkip, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			vf := valueFormatter{bytesFormat: tt.format, nullValue: "NULL"}
+			if got := vf.formatValue(b); got != tt.want {
+				t.Errorf("formatValue() = %q, want %q", got, tt.want)
+			}
+			got := vf.convertForJSON(map[string]any{"blobs": []any{b}})
+			want := map[string]any{"blobs": []any{tt.json}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("convertForJSON() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestValueFormatter_Truncate(t *testing.T) {
+	vf := valueFormatter{maxCellSize: 5}
+	tests := []struct {
+		name string
+		in   string
+		want string
+		cut  bool
+	}{
+		{"short", "abc", "abc", false},
+		{"at limit", "abcde", "abcde", false},
+		{"long", "abcdefgh", "abcde" + truncatedMarker, true},
+		// "é" is two bytes at offsets 4-5, so cutting at 5 backs off to 4.
+		{"multi-byte rune", "abcdéf", "abcd" + truncatedMarker, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cut := vf.truncate(tt.in)
+			if got != tt.want || cut != tt.cut {
+				t.Errorf("truncate(%q) = %q, %v; want %q, %v", tt.in, got, cut, tt.want, tt.cut)
+			}
+		})
+	}
+
+	if got, cut := (valueFormatter{}).truncate("abcdefgh"); got != "abcdefgh" || cut {
+		t.Errorf("truncate() without a limit = %q, %v; want the value unchanged", got, cut)
+	}
+}
+
+func TestNewValueFormatter(t *testing.T) {
+	cfg := exportConfig{
+		timeFormat: timeFormatMillis, nullValue: `\N`, arrayDelim: "|", numberFmt: numberFormatFloat,
+		grouping: &numberGrouping{}, roundFloats: true, floatPrec: 2, refFormat: refFormatID,
+		bytesFormat: bytesFormatHex, maxCellSize: 10,
+	}
+	// Every field is set, so one the constructor misses stays zero.
+	vf := reflect.ValueOf(newValueFormatter(cfg))
+	for i := range vf.NumField() {
+		if vf.Field(i).IsZero() {
+			t.Errorf("newValueFormatter() doesn't set %s", vf.Type().Field(i).Name)
+		}
+	}
+}
+
 func TestSortedKeys(t *testing.T) {
 	tests := []struct {
 		name string
@@ -204,6 +495,70 @@ func TestSortedKeys(t *testing.T) {
 	}
 }
 
+func TestExcludeCollections(t *testing.T) {
+	kept, missing := excludeCollections([]string{"users", "_internal", "orders", "audit_logs"}, []string{"audit_logs", "_internal", "audit_log"})
+	if !reflect.DeepEqual(kept, []string{"users", "orders"}) {
+		t.Errorf("kept = %v, want [users orders]", kept)
+	}
+	if !reflect.DeepEqual(missing, []string{"audit_log"}) {
+		t.Errorf("missing = %v, want [audit_log]", missing)
+	}
+}
+
+func TestExpandCollectionPatterns(t *testing.T) {
+	all := []string{"tenant_a_orders", "tenant_b_orders", "tenant_a_users", "products"}
+
+	got, err := expandCollectionPatterns([]string{"products", "tenant_*_orders", "tenant_a_*"}, all)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"products", "tenant_a_orders", "tenant_b_orders", "tenant_a_users"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandCollectionPatterns() = %v, want %v", got, want)
+	}
+
+	if _, err := expandCollectionPatterns([]string{"tenant_?_invoices"}, all); err == nil || !strings.Contains(err.Error(), "matches no collections") {
+		t.Errorf("expected no-match error, got %v", err)
+	}
+	if _, err := expandCollectionPatterns([]string{"tenant_[a"}, all); err == nil || !strings.Contains(err.Error(), "invalid collection pattern") {
+		t.Errorf("expected bad pattern error, got %v", err)
+	}
+}
+
+func TestReadCollectionsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collections.txt")
+	content := "# generated\nusers\n\n  orders  \r\n\t# tenants\ntenant_*\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readCollectionsFile(path)
+	if err != nil {
+		t.Fatalf("readCollectionsFile() error = %v", err)
+	}
+	if want := []string{"users", "orders", "tenant_*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("readCollectionsFile() = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("# nothing here\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readCollectionsFile(path); err == nil || !strings.Contains(err.Error(), "lists no collections") {
+		t.Errorf("expected empty file error, got %v", err)
+	}
+	if _, err := readCollectionsFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	if got := splitList(" a, b,,c ,"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("splitList() = %v, want [a b c]", got)
+	}
+	if got := splitList(""); got != nil {
+		t.Errorf("splitList(\"\") = %v, want nil", got)
+	}
+}
+
 func TestWriteCollectionCSV_Basic(t *testing.T) {
 	tmpDir := t.TempDir()
 	docs := []docRecord{
@@ -212,7 +567,7 @@ func TestWriteCollectionCSV_Basic(t *testing.T) {
 	}
 	fieldSet := map[string]struct{}{"name": {}, "age": {}}
 
-	filePath, err := writeCollectionCSV(docs, fieldSet, "users", tmpDir, false)
+	filePath, err := writeCollectionCSV(docs, fieldSet, "users", exportConfig{output: tmpDir})
 	if err != nil {
 		t.Fatalf("writeCollectionCSV() error = %v", err)
 	}
@@ -245,7 +600,7 @@ func TestWriteCollectionCSV_EmptyDocs(t *testing.T) {
 	tmpDir := t.TempDir()
 	fieldSet := map[string]struct{}{"a": {}}
 
-	filePath, err := writeCollectionCSV(nil, fieldSet, "empty", tmpDir, false)
+	filePath, err := writeCollectionCSV(nil, fieldSet, "empty", exportConfig{output: tmpDir})
 	if err != nil {
 		t.Fatalf("writeCollectionCSV() error = %v", err)
 	}
@@ -263,7 +618,7 @@ func TestWriteCollectionCSV_NestedPath(t *testing.T) {
 	}
 	fieldSet := map[string]struct{}{"total": {}}
 
-	filePath, err := writeCollectionCSV(docs, fieldSet, "users/orders", tmpDir, false)
+	filePath, err := writeCollectionCSV(docs, fieldSet, "users/orders", exportConfig{output: tmpDir})
 	if err != nil {
 		t.Fatalf("writeCollectionCSV() error = %v", err)
 	}
@@ -286,7 +641,7 @@ func TestWriteCollectionCSV_MissingFields(t *testing.T) {
 	}
 	fieldSet := map[string]struct{}{"a": {}, "b": {}}
 
-	filePath, err := writeCollectionCSV(docs, fieldSet, "sparse", tmpDir, false)
+	filePath, err := writeCollectionCSV(docs, fieldSet, "sparse", exportConfig{output: tmpDir})
 	if err != nil {
 		t.Fatalf("writeCollectionCSV() error = %v", err)
 	}
@@ -308,6 +663,30 @@ func TestWriteCollectionCSV_MissingFields(t *testing.T) {
 	}
 }
 
+func TestWriteCollectionCSV_NullValue(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "c/doc1", data: map[string]any{"a": nil, "b": ""}},
+		{path: "c/doc2", data: map[string]any{"b": "val_b", "c": map[string]any{"x": nil}}},
+	}
+	fieldSet := map[string]struct{}{"a": {}, "b": {}, "c": {}}
+
+	filePath, err := writeCollectionCSV(docs, fieldSet, "c", exportConfig{output: tmpDir, nullValue: `\N`})
+	if err != nil {
+		t.Fatalf("writeCollectionCSV() error = %v", err)
+	}
+
+	records := readCSV(t, filePath)
+	want := [][]string{
+		{"__path__", "a", "b", "c"},
+		{"c/doc1", `\N`, "", `\N`},
+		{"c/doc2", `\N`, "val_b", `{"x":null}`},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
 func TestWriteCollectionCSV_SpecialCharacters(t *testing.T) {
 	tmpDir := t.TempDir()
 	docs := []docRecord{
@@ -316,7 +695,7 @@ func TestWriteCollectionCSV_SpecialCharacters(t *testing.T) {
 	}
 	fieldSet := map[string]struct{}{"text": {}}
 
-	filePath, err := writeCollectionCSV(docs, fieldSet, "special", tmpDir, false)
+	filePath, err := writeCollectionCSV(docs, fieldSet, "special", exportConfig{output: tmpDir})
 	if err != nil {
 		t.Fatalf("writeCollectionCSV() error = %v", err)
 	}
@@ -393,7 +772,7 @@ func TestWriteCollectionCSV_WithTypes(t *testing.T) {
 		"name": {}, "age": {}, "active": {}, "score": {}, "joined": {}, "tags": {},
 	}
 
-	filePath, err := writeCollectionCSV(docs, fieldSet, "things", tmpDir, true)
+	filePath, err := writeCollectionCSV(docs, fieldSet, "things", exportConfig{output: tmpDir, withTypes: true})
 	if err != nil {
 		t.Fatalf("writeCollectionCSV() error = %v", err)
 	}
@@ -445,7 +824,7 @@ func TestWriteCollectionCSV_WithoutTypes(t *testing.T) {
 	}
 	fieldSet := map[string]struct{}{"name": {}}
 
-	filePath, err := writeCollectionCSV(docs, fieldSet, "col", tmpDir, false)
+	filePath, err := writeCollectionCSV(docs, fieldSet, "col", exportConfig{output: tmpDir})
 	if err != nil {
 		t.Fatalf("writeCollectionCSV() error = %v", err)
 	}
@@ -459,6 +838,203 @@ func TestWriteCollectionCSV_WithoutTypes(t *testing.T) {
 	}
 }
 
+func TestWriteCollectionCSV_Delimiter(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "col/doc1", data: map[string]any{"price": float64(1.5), "note": "a;b"}},
+	}
+	fieldSet := map[string]struct{}{"price": {}, "note": {}}
+
+	filePath, err := writeCollectionCSV(docs, fieldSet, "col", exportConfig{output: tmpDir, delimiter: ';'})
+	if err != nil {
+		t.Fatalf("writeCollectionCSV() error = %v", err)
+	}
+
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	want := "__path__;note;price\ncol/doc1;\"a;b\";1.5\n"
+	if string(raw) != want {
+		t.Errorf("file content = %q, want %q", string(raw), want)
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    rune
+		wantErr bool
+	}{
+		{",", ',', false},
+		{";", ';', false},
+		{"|", '|', false},
+		{`\t`, '\t', false},
+		{"\t", '\t', false},
+		{"§", '§', false},
+		{"", 0, true},
+		{";;", 0, true},
+		{`"`, 0, true},
+		{"\n", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseDelimiter(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseDelimiter(%q) expected error, got %q", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDelimiter(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseDelimiter(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShapeRecord_Flatten(t *testing.T) {
+	input := map[string]any{
+		"name": "Alice",
+		"address": map[string]any{
+			"city": "Berlin",
+			"geo":  map[string]any{"lat": float64(52.5), "lng": float64(13.4)},
+		},
+		"tags":  []any{"a", map[string]any{"nested": true}},
+		"empty": map[string]any{},
+	}
+	got, err := shapeRecord(input, exportConfig{flatten: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"name":            "Alice",
+		"address.city":    "Berlin",
+		"address.geo.lat": float64(52.5),
+		"address.geo.lng": float64(13.4),
+		"tags":            []any{"a", map[string]any{"nested": true}},
+		"empty":           map[string]any{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord(flatten) = %v, want %v", got, want)
+	}
+}
+
+func TestPrepareRecord_Flatten(t *testing.T) {
+	data := map[string]any{"a": map[string]any{"b": int64(1)}}
+
+	if got, _ := prepareRecord(data, "users/alice", exportConfig{}); !reflect.DeepEqual(got, data) {
+		t.Errorf("without --flatten, record should be unchanged, got %v", got)
+	}
+	got, _ := prepareRecord(data, "users/alice", exportConfig{flatten: true})
+	if _, ok := got["a.b"]; !ok || len(got) != 1 {
+		t.Errorf("with --flatten, got %v, want only key a.b", got)
+	}
+}
+
+func TestShapeRecord_GeoPoints(t *testing.T) {
+	geo := &latlng.LatLng{Latitude: 52.52, Longitude: 13.405}
+	data := map[string]any{"name": "Berlin", "loc": geo}
+
+	got, _ := shapeRecord(data, exportConfig{geoColumns: true})
+	want := map[string]any{"name": "Berlin", "loc.lat": 52.52, "loc.lng": 13.405}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord(geo) = %v, want %v", got, want)
+	}
+	if data["loc"] != geo || len(data) != 2 {
+		t.Errorf("shapeRecord(geo) modified its input: %v", data)
+	}
+
+	// Nested GeoPoints are only split after --flatten.
+	nested := map[string]any{"office": map[string]any{"loc": geo}}
+	if got, _ := shapeRecord(nested, exportConfig{geoColumns: true}); !reflect.DeepEqual(got, nested) {
+		t.Errorf("shapeRecord(geo, nested) = %v, want unchanged", got)
+	}
+	got, _ = shapeRecord(nested, exportConfig{flatten: true, geoColumns: true})
+	want = map[string]any{"office.loc.lat": 52.52, "office.loc.lng": 13.405}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord(flatten+geo) = %v, want %v", got, want)
+	}
+}
+
+func TestWriteCollectionJSONL(t *testing.T) {
+	tmpDir := t.TempDir()
+	fixedTime := time.Date(2024, 6, 15, 12, 30, 0, 0, time.UTC)
+	docs := []docRecord{
+		{path: "users/doc1", data: map[string]any{
+			"name":    "Alice",
+			"age":     int64(30),
+			"joined":  fixedTime,
+			"tags":    []any{"a", "b"},
+			"address": map[string]any{"city": "Berlin"},
+		}},
+		{path: "users/doc2", data: map[string]any{"name": "Bob"}},
+	}
+	fieldSet := map[string]struct{}{"name": {}, "age": {}, "joined": {}, "tags": {}, "address": {}}
+
+	filePath, err := writeCollection(docs, fieldSet, "users", exportConfig{output: tmpDir, format: "jsonl"})
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+
+	expected := filepath.Join(tmpDir, "users.jsonl")
+	if filePath != expected {
+		t.Errorf("filePath = %q, want %q", filePath, expected)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &obj); err != nil {
+		t.Fatalf("line 1 is not valid JSON: %v", err)
+	}
+	if obj["__path__"] != "users/doc1" {
+		t.Errorf("__path__ = %v, want users/doc1", obj["__path__"])
+	}
+	if obj["joined"] != fixedTime.Format(time.RFC3339Nano) {
+		t.Errorf("joined = %v, want %s", obj["joined"], fixedTime.Format(time.RFC3339Nano))
+	}
+	// Arrays and maps stay native JSON values rather than encoded strings.
+	if tags, ok := obj["tags"].([]any); !ok || len(tags) != 2 {
+		t.Errorf("tags = %v (%T), want 2-element array", obj["tags"], obj["tags"])
+	}
+	if addr, ok := obj["address"].(map[string]any); !ok || addr["city"] != "Berlin" {
+		t.Errorf("address = %v (%T), want map with city=Berlin", obj["address"], obj["address"])
+	}
+
+	// Documents only contain their own fields, not the collection union.
+	var obj2 map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &obj2); err != nil {
+		t.Fatalf("line 2 is not valid JSON: %v", err)
+	}
+	if _, ok := obj2["age"]; ok {
+		t.Error("doc2 should not have an 'age' key")
+	}
+}
+
+func TestWriteCollection_DefaultFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "col/doc1", data: map[string]any{"a": "b"}}}
+
+	filePath, err := writeCollection(docs, map[string]struct{}{"a": {}}, "col", exportConfig{output: tmpDir})
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if filePath != filepath.Join(tmpDir, "col.csv") {
+		t.Errorf("filePath = %q, want CSV output by default", filePath)
+	}
+}
+
 // readCSV is a test helper that reads all records from a CSV file.
 func readCSV(t *testing.T, path string) [][]string {
 	t.Helper()
@@ -485,6 +1061,14 @@ func newTestCommand() *cobra.Command {
 	pf.StringP("project", "p", "", "GCP project ID")
 	pf.StringP("emulator", "e", "", "Firestore emulator host")
 	pf.StringP("database", "d", "(default)", "Firestore database name")
+	pf.String("credentials", "", "")
+	pf.String("endpoint", "", "")
+	pf.Bool("no-auth", false, "")
+	pf.BoolP("quiet", "q", false, "")
+	pf.CountP("verbose", "v", "")
+	pf.String("log-format", "text", "")
+	pf.String("config", "", "")
+	root.SetGlobalNormalizationFunc(normalizeFlagName)
 
 	exportCmd := &cobra.Command{
 		Use:          "export",
@@ -505,13 +1089,99 @@ func newTestCommand() *cobra.Command {
 	}
 	ef := exportCmd.Flags()
 	ef.StringP("collections", "c", "", "")
+	ef.String("collections-file", "", "")
+	ef.String("exclude", "", "")
+	ef.String("ids", "", "")
+	ef.String("id-start", "", "")
+	ef.String("id-end", "", "")
+	ef.String("id-codec", "", "")
+	ef.String("id-start-time", "", "")
+	ef.String("id-end-time", "", "")
+	ef.String("collection-group", "", "")
 	ef.IntP("limit", "l", 0, "")
+	ef.String("limit-per", "", "")
 	ef.Int("child-limit", 0, "")
 	ef.Int("depth", -1, "")
+	ef.StringArray("where", nil, "")
+	ef.String("modified-since", "", "")
+	ef.String("modified-field", "", "")
+	ef.String("fields", "", "")
+	ef.String("exclude-fields", "", "")
+	ef.String("order-by", "", "")
+	ef.String("dedup-by", "", "")
+	ef.String("dedup-keep", dedupKeepLast, "")
+	ef.String("missing-field", "", "")
+	ef.String("explode", "", "")
+	ef.String("schema-file", "", "")
+	ef.Bool("schema-strict", true, "")
 	ef.StringP("output", "o", ".", "")
+	ef.StringP("format", "f", "csv", "")
+	ef.String("file-prefix", "", "")
+	ef.String("file-suffix", "", "")
+	ef.Bool("sanitize-names", true, "")
+	ef.String("compression", compressionNone, "")
+	ef.String("max-file-size", "", "")
+	ef.Bool("gzip", false, "")
+	ef.String("delimiter", ",", "")
+	ef.Bool("no-header", false, "")
+	ef.Bool("bom", false, "")
+	ef.Bool("quote-all", false, "")
+	ef.String("line-ending", "lf", "")
+	ef.String("time-format", "rfc3339nano", "")
+	ef.String("null-value", "", "")
+	ef.String("array-format", "json", "")
+	ef.String("array-delimiter", "|", "")
+	ef.String("number-format", numberFormatNative, "")
+	ef.Bool("group-numbers", false, "")
+	ef.String("locale", "en", "")
+	ef.Int("float-precision", -1, "")
+	ef.String("ref-format", refFormatPath, "")
+	ef.String("bytes-format", bytesFormatBase64, "")
+	ef.Bool("resolve-refs", false, "")
+	ef.String("resolve-ref-field", "", "")
+	ef.Int("max-cell-size", 0, "")
+	ef.Bool("pretty-json", false, "")
+	ef.Bool("bigquery-json", false, "")
 	ef.Bool("with-types", false, "")
+	ef.Bool("emit-schema", false, "")
+	ef.Bool("validate", false, "")
+	ef.Bool("flatten", false, "")
+	ef.Bool("flatten-arrays", false, "")
+	ef.Int("max-array-index", 9, "")
+	ef.Bool("geopoint-columns", false, "")
+	ef.Bool("include-timestamps", false, "")
+	ef.Bool("path-columns", false, "")
+	ef.String("rename", "", "")
+	ef.StringArray("compute", nil, "")
+	ef.String("on-collision", "error", "")
+	ef.Int("page-size", 0, "")
+	ef.Duration("timeout", 0, "")
+	ef.Bool("split-documents", false, "")
+	ef.Bool("single-file", false, "")
+	ef.Bool("stream", false, "")
+	ef.String("hash-fields", "", "")
+	ef.String("redact-fields", "", "")
+	ef.String("cast", "", "")
 	ef.String("sanitize", "", "")
 	ef.Int64("seed", 0, "")
+	ef.IntP("concurrency", "j", 1, "")
+	ef.Int("partitions", 0, "")
+	ef.Bool("fail-fast", false, "")
+	ef.Bool("empty-files", false, "")
+	ef.Bool("append", false, "")
+	ef.String("baseline", "", "")
+	ef.Bool("resume", false, "")
+	ef.Int("checkpoint-every", 1000, "")
+	ef.String("summary-format", "table", "")
+	ef.Bool("summary-totals", true, "")
+	ef.Bool("manifest", false, "")
+	ef.Bool("checksums", false, "")
+	ef.String("errors-file", "", "")
+	ef.String("on-file", "", "")
+	ef.String("on-complete", "", "")
+	ef.Bool("dry-run", false, "")
+	ef.Int("preview", 0, "")
+	ef.Int("max-retries", 3, "")
 
 	importCmd := &cobra.Command{
 		Use:          "import",
@@ -545,10 +1215,233 @@ func newTestCommand() *cobra.Command {
 	return root
 }
 
+func TestSpinner_Disabled(t *testing.T) {
+	sp := newSpinner("working", false)
+	sp.Start()
+	sp.SetSuffix("still working")
+	sp.Stop()
+	// Stop on a disabled spinner must not close the channel, so a second Stop is safe.
+	sp.Stop()
+	select {
+	case <-sp.done:
+		t.Error("disabled spinner should not close its done channel")
+	default:
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	quiet = true
+	t.Cleanup(func() { quiet = false })
+
+	out := captureStderr(t, func() {
+		printInfo("info")
+		printOK("ok")
+		printErr("boom")
+	})
+	if strings.Contains(out, "info") || strings.Contains(out, "ok") {
+		t.Errorf("quiet output should not contain INFO/OK lines, got %q", out)
+	}
+	if !strings.Contains(out, "boom") {
+		t.Errorf("quiet output should still contain errors, got %q", out)
+	}
+	if sp := newSpinner("working", true); sp.enabled {
+		t.Error("spinner should be disabled when quiet")
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	tests := []struct {
+		limit, read, pageSize int
+		want                  int
+	}{
+		{0, 0, 0, 0},
+		{10, 4, 0, 6},
+		{0, 500, 100, 100},
+		{250, 200, 100, 50},
+		{250, 0, 100, 100},
+	}
+	for _, tt := range tests {
+		if got := pageLimit(tt.limit, tt.read, tt.pageSize); got != tt.want {
+			t.Errorf("pageLimit(%d, %d, %d) = %d, want %d", tt.limit, tt.read, tt.pageSize, got, tt.want)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		done, total int
+		want        string
+	}{
+		{0, 10, "[>                   ] 0% (0/10)"},
+		{4200, 10000, "[========>           ] 42% (4,200/10,000)"},
+		{10, 10, "[====================] 100% (10/10)"},
+		{12, 10, "[====================] 100% (10/10)"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.done, tt.total); got != tt.want {
+			t.Errorf("progressBar(%d, %d) = %q, want %q", tt.done, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestDisableColorsIfNeeded(t *testing.T) {
+	noColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	t.Setenv("NO_COLOR", "1")
+	color.NoColor = false
+	disableColorsIfNeeded()
+	if !color.NoColor {
+		t.Error("colors should be disabled when NO_COLOR is set")
+	}
+}
+
+func TestHasFailure(t *testing.T) {
+	ok := exportResult{collection: "users"}
+	failed := exportResult{collection: "orders", err: fmt.Errorf("boom")}
+	if hasFailure([]exportResult{ok, ok}) {
+		t.Error("hasFailure() = true for successful results")
+	}
+	if !hasFailure([]exportResult{ok, failed}) {
+		t.Error("hasFailure() = false with a failed result")
+	}
+}
+
+func TestWriteSummaryCSV(t *testing.T) {
+	results := []exportResult{
+		{collection: "users", docCount: 1200, fieldCount: 4, filePath: "out/users.csv"},
+		{collection: "users/orders", depth: 1, err: fmt.Errorf("permission denied")},
+		{collection: "events", docCount: 3, fieldCount: 2, filePath: "out/events.csv", err: fmt.Errorf("--timeout of 1m0s exceeded"), partial: true},
+	}
+
+	var buf bytes.Buffer
+	if err := writeSummaryCSV(&buf, results, false); err != nil {
+		t.Fatalf("writeSummaryCSV() error = %v", err)
+	}
+	want := "collection,depth,docs,fields,file,error\n" +
+		"users,0,1200,4,out/users.csv,\n" +
+		"users/orders,1,0,0,,permission denied\n" +
+		"events,0,3,2,out/events.csv,__partial__: --timeout of 1m0s exceeded\n"
+	if buf.String() != want {
+		t.Errorf("CSV summary = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := writeSummaryCSV(&buf, results[:1], true); err != nil {
+		t.Fatalf("writeSummaryCSV(tsv) error = %v", err)
+	}
+	if want := "collection\tdepth\tdocs\tfields\tfile\terror\nusers\t0\t1200\t4\tout/users.csv\t\n"; buf.String() != want {
+		t.Errorf("TSV summary = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	multi := []exportResult{
+		{database: "(default)", collection: "users", docCount: 2, fieldCount: 1, filePath: "out/default_users.csv"},
+		{database: "tenant-a", collection: "users", docCount: 1, fieldCount: 1, filePath: "out/tenant-a_users.csv"},
+	}
+	if err := writeSummaryCSV(&buf, multi, false); err != nil {
+		t.Fatalf("writeSummaryCSV(databases) error = %v", err)
+	}
+	want = "database,collection,depth,docs,fields,file,error\n" +
+		"(default),users,0,2,1,out/default_users.csv,\n" +
+		"tenant-a,users,0,1,1,out/tenant-a_users.csv,\n"
+	if buf.String() != want {
+		t.Errorf("CSV summary with databases = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSetLogFormat_JSON(t *testing.T) {
+	noColor := color.NoColor
+	if err := setLogFormat("json"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		setLogFormat("text")
+		color.NoColor = noColor
+	})
+
+	out := captureStderr(t, func() {
+		printDebugFor("users", "Query for %q", "users")
+		printOKFor("users", "Exported %q", "users")
+		printText("\n  - %s\n", "users/a")
+		printDone(false, "Export failed")
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4: %q", len(lines), out)
+	}
+	want := []logEntry{
+		{Level: "debug", Msg: `Query for "users"`, Collection: "users"},
+		{Level: "ok", Msg: `Exported "users"`, Collection: "users"},
+		{Level: "info", Msg: "- users/a"},
+		{Level: "error", Msg: "Export failed"},
+	}
+	for i, line := range lines {
+		var got logEntry
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d is not JSON: %q", i, line)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, got.TS); err != nil {
+			t.Errorf("line %d: bad ts %q", i, got.TS)
+		}
+		got.TS = ""
+		if got != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if sp := newSpinner("working", true); sp.enabled {
+		t.Error("spinner should be disabled with JSON logs")
+	}
+}
+
+func TestSetLogFormat_Invalid(t *testing.T) {
+	if err := setLogFormat("xml"); err == nil {
+		t.Fatal("expected error for unknown log format")
+	}
+}
+
+// captureStderr returns what fn writes to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stderr
+	os.Stderr = w
+	fn()
+	os.Stderr = orig
+	w.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRunExport_EmulatorFromEnvironment(t *testing.T) {
+	t.Setenv(emulatorHostEnv, "localhost:1")
+	dir := t.TempDir()
+	for _, fromEnv := range []bool{true, false} {
+		cfg := exportConfig{
+			databases:   []string{"(default)"},
+			emulator:    "localhost:1",
+			emulatorEnv: fromEnv,
+			collFile:    filepath.Join(dir, "missing.txt"),
+			output:      dir,
+		}
+		out := captureStderr(t, func() { runExport(cfg) })
+		if got := strings.Contains(out, "from "+emulatorHostEnv); got != fromEnv {
+			t.Errorf("emulatorEnv %v: logged the environment variable = %v:\n%s", fromEnv, got, out)
+		}
+	}
+}
+
 func TestValidateConnectionFlags(t *testing.T) {
 	tests := []struct {
 		name         string
 		args         []string
+		env          string
 		wantErr      string
 		wantProject  string
 		wantEmulator string
@@ -574,9 +1467,22 @@ func TestValidateConnectionFlags(t *testing.T) {
 			args:         []string{"export", "-e", "localhost:8686"},
 			wantEmulator: "localhost:8686",
 		},
+		{
+			name:         "emulator from environment",
+			args:         []string{"export"},
+			env:          "localhost:9090",
+			wantEmulator: "localhost:9090",
+		},
+		{
+			name:         "emulator flag overrides environment",
+			args:         []string{"export", "-e", "localhost:8686"},
+			env:          "localhost:9090",
+			wantEmulator: "localhost:8686",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(emulatorHostEnv, tt.env)
 			// Use a custom RunE to capture validateConnectionFlags return values.
 			var gotProject, gotEmulator string
 			cmd := newTestCommand()
@@ -651,7 +1557,7 @@ func TestNewFirestoreClient_ProjectDefault(t *testing.T) {
 			// newFirestoreClient will try to connect; we just verify side effects.
 			// The client creation may fail without a real emulator, but that's OK —
 			// we're testing the env-var and project-default logic.
-			_, _ = newFirestoreClient(context.Background(), tt.project, "(default)", tt.emulator)
+			_, _ = newFirestoreClient(context.Background(), tt.project, "(default)", tt.emulator, "", endpointOptions{})
 
 			envVal := os.Getenv("FIRESTORE_EMULATOR_HOST")
 			if tt.wantEnvSet {
@@ -667,6 +1573,85 @@ func TestNewFirestoreClient_ProjectDefault(t *testing.T) {
 	}
 }
 
+func TestValidateCredentialsFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if err := validateCredentialsFile(write("sa.json", `{"type":"service_account"}`)); err != nil {
+		t.Errorf("service account key: unexpected error %v", err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"missing file", filepath.Join(dir, "missing.json"), "no such file"},
+		{"not JSON", write("bad.json", "not json"), "not a JSON key file"},
+		{"wrong type", write("user.json", `{"type":"authorized_user"}`), `not a service account key (type "authorized_user")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCredentialsFile(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateCredentialsFile() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCredentialsFromFlags(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "sa.json")
+	if err := os.WriteFile(keyFile, []byte(`{"type":"service_account"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     string
+		want    string
+		wantErr string
+	}{
+		{name: "not set", args: []string{"export", "-p", "p"}},
+		{name: "credentials", args: []string{"export", "-p", "p", "--credentials", keyFile}, want: keyFile},
+		{name: "key-file alias", args: []string{"export", "-p", "p", "--key-file", keyFile}, want: keyFile},
+		{name: "same as env", args: []string{"export", "-p", "p", "--credentials", keyFile}, env: keyFile, want: keyFile},
+		{name: "conflicts with env", args: []string{"export", "-p", "p", "--credentials", keyFile}, env: "/other/key.json", wantErr: "conflicts with GOOGLE_APPLICATION_CREDENTIALS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(credentialsEnv, tt.env)
+			var got string
+			var gotErr error
+			cmd := newTestCommand()
+			exportCmd, _, _ := cmd.Find([]string{"export"})
+			exportCmd.RunE = func(cmd *cobra.Command, args []string) error {
+				got, gotErr = credentialsFromFlags(cmd)
+				return nil
+			}
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" {
+				if gotErr == nil || !strings.Contains(gotErr.Error(), tt.wantErr) {
+					t.Errorf("credentialsFromFlags() error = %v, want containing %q", gotErr, tt.wantErr)
+				}
+				return
+			}
+			if gotErr != nil || got != tt.want {
+				t.Errorf("credentialsFromFlags() = %q, %v; want %q", got, gotErr, tt.want)
+			}
+		})
+	}
+}
+
 func TestCastValue(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -888,6 +1873,63 @@ users/bob,Bob,25,false
 	}
 }
 
+func TestParseCSVFile_SkipsTimestamps(t *testing.T) {
+	tmpDir := t.TempDir()
+	csvPath := filepath.Join(tmpDir, "test.csv")
+	content := "__path__,__create_time__,__update_time__,name\nusers/alice,2024-01-02T03:04:05Z,2024-06-07T08:09:10Z,Alice\n"
+	if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test CSV: %v", err)
+	}
+
+	records, err := parseCSVFile(csvPath)
+	if err != nil {
+		t.Fatalf("parseCSVFile() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	if want := map[string]any{"name": "Alice"}; !reflect.DeepEqual(records[0].data, want) {
+		t.Errorf("data = %v, want %v", records[0].data, want)
+	}
+}
+
+func TestParseCSVFile_SkipsParentColumns(t *testing.T) {
+	tmpDir := t.TempDir()
+	csvPath := filepath.Join(tmpDir, "test.csv")
+	content := "__path__,__parent_collection__,__parent_id__,status\nusers/alice/orders/1,users,alice,paid\n"
+	if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test CSV: %v", err)
+	}
+
+	records, err := parseCSVFile(csvPath)
+	if err != nil {
+		t.Fatalf("parseCSVFile() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	if want := map[string]any{"status": "paid"}; !reflect.DeepEqual(records[0].data, want) {
+		t.Errorf("data = %v, want %v", records[0].data, want)
+	}
+}
+
+func TestParseCSVFile_BOM(t *testing.T) {
+	tmpDir := t.TempDir()
+	csvPath := filepath.Join(tmpDir, "test.csv")
+	content := utf8BOM + "__path__,name\nusers/alice,Alice\n"
+	if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test CSV: %v", err)
+	}
+
+	records, err := parseCSVFile(csvPath)
+	if err != nil {
+		t.Fatalf("parseCSVFile() error = %v", err)
+	}
+	if len(records) != 1 || records[0].path != "users/alice" {
+		t.Errorf("records = %+v, want users/alice", records)
+	}
+}
+
 func TestParseCSVFile_MissingPath(t *testing.T) {
 	tmpDir := t.TempDir()
 	csvPath := filepath.Join(tmpDir, "test.csv")
@@ -1075,3 +2117,76 @@ func TestExportSanitizeFlag(t *testing.T) {
 		}
 	})
 }
+
+func TestPrintSummaryTable_Totals(t *testing.T) {
+	results := []exportResult{
+		{collection: "users", docCount: 999999, fieldCount: 12, filePath: "out/users.csv"},
+		{collection: "orders", depth: 1, docCount: 1, fieldCount: 3, filePath: "out/users/orders.csv"},
+	}
+	out := captureStderr(t, func() { printSummaryTable(results, true) })
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")[1:]
+	if len(lines) != 6 {
+		t.Fatalf("got %d lines, want header, separator, 2 rows, separator and total: %q", len(lines), out)
+	}
+	total := lines[5]
+	if fields := strings.Fields(total); len(fields) != 4 || fields[3] != "1,000,000" {
+		t.Errorf("total row = %q, want 1,000,000 docs", total)
+	}
+	// The totals are wider than every row, so the columns widen to fit them.
+	docsEnd := strings.Index(total, "1,000,000") + len("1,000,000")
+	if end := strings.Index(lines[2], "999,999") + len("999,999"); end != docsEnd {
+		t.Errorf("docs column ends at %d in %q, want %d as in the total row %q", end, lines[2], docsEnd, total)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(total), "Total (2 collection(s))") {
+		t.Errorf("total row = %q, want the number of collections", total)
+	}
+
+	if fields := strings.Fields(lines[2]); fields[3] != "-" {
+		t.Errorf("row = %q, want - for the size of a missing file", lines[2])
+	}
+
+	out = captureStderr(t, func() { printSummaryTable(results, false) })
+	if strings.Contains(out, "Total") {
+		t.Errorf("summary without totals has a total row:\n%s", out)
+	}
+	out = captureStderr(t, func() { printSummaryTable(results[:1], true) })
+	if strings.Contains(out, "Total") {
+		t.Errorf("summary of a single collection has a total row:\n%s", out)
+	}
+}
+
+func TestPrintSummaryTable_Size(t *testing.T) {
+	dir := t.TempDir()
+	users := filepath.Join(dir, "users.csv")
+	parts := []string{filepath.Join(dir, "orders-1.csv"), filepath.Join(dir, "orders-2.csv")}
+	for file, size := range map[string]int{users: 340_000, parts[0]: 1000, parts[1]: 500} {
+		if err := os.WriteFile(file, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := []exportResult{
+		{collection: "users", docCount: 10, filePath: users},
+		{collection: "orders", docCount: 2, filePath: parts[0], parts: parts},
+		{collection: "teams", docCount: 1, filePath: "gs://bucket/teams.csv"},
+	}
+	out := captureStderr(t, func() { printSummaryTable(results, true) })
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")[1:]
+	if len(lines) != 7 {
+		t.Fatalf("got %d lines: %q", len(lines), out)
+	}
+	for i, want := range []string{"340 KB", "1.5 KB", "-"} {
+		if !strings.Contains(lines[2+i], " "+want+"  ") {
+			t.Errorf("row %q, want size %s", lines[2+i], want)
+		}
+	}
+	// The sizes are right-aligned under the header, the total included.
+	end := strings.Index(lines[0], "Size") + len("Size")
+	for _, line := range []string{lines[2], lines[6]} {
+		if i := strings.Index(line, " KB"); i+len(" KB") != end {
+			t.Errorf("size in %q ends at %d, want %d", line, i+len(" KB"), end)
+		}
+	}
+	if !strings.Contains(lines[6], "342 KB") {
+		t.Errorf("total row = %q, want 342 KB", lines[6])
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// exportManifest is the content of the manifest.json file written with --manifest.
+type exportManifest struct {
+	Project     string          `json:"project"`
+	Database    string          `json:"database"`
+	Emulator    string          `json:"emulator,omitempty"`
+	Timestamp   string          `json:"timestamp"`
+	Success     bool            `json:"success"`
+	Collections []manifestEntry `json:"collections"`
+}
+
+// manifestEntry is the manifest record for one exported collection.
+type manifestEntry struct {
+	Database   string   `json:"database,omitempty"` // Set when several databases are exported
+	Collection string   `json:"collection"`
+	Depth      int      `json:"depth"`
+	Documents  int      `json:"documents"`
+	Fields     int      `json:"fields"`
+	File       string   `json:"file,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	ErrorCode  string   `json:"error_code,omitempty"`  // gRPC status code of Error; see errorCode
+	Partial    bool     `json:"partial,omitempty"`     // File holds only the documents read before Error
+	Parts      []string `json:"parts,omitempty"`       // Every file written with --max-file-size; File is the first
+	SHA256     string   `json:"sha256,omitempty"`      // Digest of File as written, after compression
+	PartSHA256 []string `json:"part_sha256,omitempty"` // Digests of Parts, in order
+}
+
+// buildManifest summarizes the export results. Success is false if any
+// collection failed, matching the command's exit status.
+func buildManifest(results []exportResult, cfg exportConfig, now time.Time) exportManifest {
+	project := cfg.project
+	if project == "" && cfg.emulator != "" {
+		project = defaultEmulatorProject
+	}
+	m := exportManifest{
+		Project:     project,
+		Database:    cfg.database,
+		Emulator:    cfg.emulator,
+		Timestamp:   now.UTC().Format(time.RFC3339),
+		Success:     true,
+		Collections: make([]manifestEntry, 0, len(results)),
+	}
+	for _, r := range results {
+		e := manifestEntry{
+			Database:   r.database,
+			Collection: r.collection,
+			Depth:      r.depth,
+			Documents:  r.docCount,
+			Fields:     r.fieldCount,
+			File:       r.filePath,
+			Partial:    r.partial,
+			Parts:      r.parts,
+			SHA256:     r.sha256,
+			PartSHA256: r.partSums,
+		}
+		if r.err != nil {
+			e.Error = r.err.Error()
+			e.ErrorCode = errorCode(r.err)
+			m.Success = false
+		}
+		m.Collections = append(m.Collections, e)
+	}
+	return m
+}
+
+// writeManifest writes manifest.json to the output directory (or GCS prefix)
+// and returns its path.
+func writeManifest(m exportManifest, cfg exportConfig) (string, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding manifest: %w", err)
+	}
+	f, filePath, err := openOutputFile("manifest", ".json", cfg)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return "", fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("closing %s: %w", filePath, err)
+	}
+	return filePath, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildManifest(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	results := []exportResult{
+		{collection: "users", docCount: 3, fieldCount: 4, filePath: "out/users.csv", sha256: "abc"},
+		{collection: "users/orders", depth: 1, err: errors.New("permission denied")},
+		{collection: "events", docCount: 2, filePath: "out/events.csv", err: errors.New("interrupted by interrupt"), partial: true},
+	}
+
+	got := buildManifest(results, exportConfig{emulator: "localhost:8686", database: "(default)"}, now)
+	want := exportManifest{
+		Project:   defaultEmulatorProject,
+		Database:  "(default)",
+		Emulator:  "localhost:8686",
+		Timestamp: "2024-01-15T10:30:00Z",
+		Success:   false,
+		Collections: []manifestEntry{
+			{Collection: "users", Documents: 3, Fields: 4, File: "out/users.csv", SHA256: "abc"},
+			{Collection: "users/orders", Depth: 1, Error: "permission denied", ErrorCode: "Unknown"},
+			{Collection: "events", Documents: 2, File: "out/events.csv", Error: "interrupted by interrupt", ErrorCode: "Unknown", Partial: true},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildManifest() = %+v, want %+v", got, want)
+	}
+
+	if m := buildManifest(results[:1], exportConfig{project: "p"}, now); !m.Success || m.Project != "p" {
+		t.Errorf("buildManifest(successful run) = %+v, want success for project p", m)
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := exportManifest{Project: "p", Success: true, Collections: []manifestEntry{}}
+
+	filePath, err := writeManifest(m, exportConfig{output: tmpDir, compression: "gzip"})
+	if err != nil {
+		t.Fatalf("writeManifest() error = %v", err)
+	}
+	if filePath != filepath.Join(tmpDir, "manifest.json") {
+		t.Errorf("filePath = %q, want manifest.json under output dir", filePath)
+	}
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got exportManifest
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("manifest = %+v, want %+v", got, m)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// redactedValue replaces the values of --redact-fields.
+const redactedValue = "***"
+
+// maskFields returns data with the values of --hash-fields replaced by the
+// SHA-256 hex digest of their string form (as written to CSV) and those of
+// --redact-fields by redactedValue. Names are field paths, so "contact.email"
+// masks a value nested in a map. Null values are left alone. data isn't
+// modified; the maps holding it are copied instead.
+func maskFields(data map[string]any, cfg exportConfig) map[string]any {
+	if len(cfg.hashFields) == 0 && len(cfg.redactFields) == 0 {
+		return data
+	}
+	return maskMap("", data, cfg)
+}
+
+func maskMap(prefix string, data map[string]any, cfg exportConfig) map[string]any {
+	out := make(map[string]any, len(data))
+	for k, v := range data {
+		name := prefix + k
+		switch {
+		case v == nil:
+		case cfg.redactFields[name]:
+			v = redactedValue
+		case cfg.hashFields[name]:
+			v = hashValue(v)
+		default:
+			if m, ok := v.(map[string]any); ok {
+				v = maskMap(name+".", m, cfg)
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// hashValue returns the SHA-256 hex digest of v's string form.
+func hashValue(v any) string {
+	sum := sha256.Sum256([]byte(formatValue(v)))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseMaskFields parses the --hash-fields and --redact-fields lists. A field
+// can't be in both.
+func parseMaskFields(hashRaw, redactRaw string) (hash, redact map[string]bool, err error) {
+	hashList, err := parseFieldList(hashRaw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --hash-fields: %w", err)
+	}
+	redactList, err := parseFieldList(redactRaw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --redact-fields: %w", err)
+	}
+	hash, redact = fieldNameSet(hashList), fieldNameSet(redactList)
+	for _, f := range redactList {
+		if hash[f] {
+			return nil, nil, fmt.Errorf("field %q is in both --hash-fields and --redact-fields", f)
+		}
+	}
+	return hash, redact, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestMaskFields(t *testing.T) {
+	data := map[string]any{
+		"email":   "alice@example.com",
+		"phone":   "+1 555 0100",
+		"age":     int64(30),
+		"note":    nil,
+		"contact": map[string]any{"email": "a@work.example", "city": "Berlin"},
+		"name":    "Alice",
+	}
+	cfg := exportConfig{
+		hashFields:   map[string]bool{"email": true, "age": true, "contact.email": true, "note": true},
+		redactFields: map[string]bool{"phone": true},
+	}
+
+	got := maskFields(data, cfg)
+	want := map[string]any{
+		"email":   sha256Hex("alice@example.com"),
+		"phone":   redactedValue,
+		"age":     sha256Hex("30"),
+		"note":    nil,
+		"contact": map[string]any{"email": sha256Hex("a@work.example"), "city": "Berlin"},
+		"name":    "Alice",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("maskFields() = %v, want %v", got, want)
+	}
+	if data["email"] != "alice@example.com" || data["contact"].(map[string]any)["email"] != "a@work.example" {
+		t.Errorf("maskFields() modified its input: %v", data)
+	}
+}
+
+func TestShapeRecord_MaskedMap(t *testing.T) {
+	data := map[string]any{"address": map[string]any{"city": "Berlin"}}
+	got, err := shapeRecord(data, exportConfig{flatten: true, redactFields: map[string]bool{"address": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"address": redactedValue}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shapeRecord = %v, want %v", got, want)
+	}
+}
+
+func TestParseMaskFields(t *testing.T) {
+	hash, redact, err := parseMaskFields("email, contact.email", "phone")
+	if err != nil {
+		t.Fatalf("parseMaskFields() error = %v", err)
+	}
+	if !reflect.DeepEqual(hash, map[string]bool{"email": true, "contact.email": true}) {
+		t.Errorf("hash = %v", hash)
+	}
+	if !reflect.DeepEqual(redact, map[string]bool{"phone": true}) {
+		t.Errorf("redact = %v", redact)
+	}
+
+	if _, _, err := parseMaskFields("email", "phone,email"); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Errorf("parseMaskFields() error = %v, want field in both lists", err)
+	}
+	if _, _, err := parseMaskFields("email,email", ""); err == nil || !strings.Contains(err.Error(), "--hash-fields") {
+		t.Errorf("parseMaskFields() error = %v, want duplicate in --hash-fields", err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// lacksField reports whether data, a document as read from Firestore, has no
+// top-level field named field. A field set to null is present.
+func lacksField(data map[string]any, field string) bool {
+	_, ok := data[field]
+	return !ok
+}
+
+// validateMissingField checks the --missing-field setting. Firestore can't
+// query for a missing field, so the filter runs on every document read.
+func validateMissingField(cfg exportConfig) error {
+	switch {
+	case cfg.resume:
+		return fmt.Errorf("--missing-field can't be combined with --resume")
+	case len(cfg.fields) > 0 && !slices.Contains(cfg.fields, cfg.missing):
+		// Without it in the selection, no document would appear to have it.
+		return fmt.Errorf("--missing-field %q must be one of the --fields", cfg.missing)
+	}
+	return nil
+}
+
+// reportMissingField notes how many of the total documents read from
+// displayPath were kept by --missing-field.
+func reportMissingField(displayPath string, kept, total int, field string) {
+	printInfoFor(displayPath, "Kept %s of %s docs in %q without field %q", fmtInt(kept), fmtInt(total), displayPath, field)
+}
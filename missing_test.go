@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLacksField(t *testing.T) {
+	data := map[string]any{"name": "Ada", "migratedAt": nil}
+	if lacksField(data, "name") {
+		t.Error(`lacksField("name") = true, want false`)
+	}
+	if lacksField(data, "migratedAt") {
+		t.Error(`lacksField("migratedAt") = true, want false for a null field`)
+	}
+	if !lacksField(data, "email") {
+		t.Error(`lacksField("email") = false, want true`)
+	}
+}
+
+func TestValidateMissingField(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"valid", exportConfig{missing: "migratedAt"}, ""},
+		{"in fields", exportConfig{missing: "migratedAt", fields: []string{"name", "migratedAt"}}, ""},
+		{"not in fields", exportConfig{missing: "migratedAt", fields: []string{"name"}}, "--fields"},
+		{"resume", exportConfig{missing: "migratedAt", resume: true}, "--resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMissingField(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateMissingField() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateMissingField() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
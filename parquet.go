@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetKind is the physical type of a Parquet column.
+type parquetKind int
+
+// Values with no Parquet counterpart (timestamps, GeoPoints, references,
+// arrays, maps) and fields holding more than one type go in string columns,
+// formatted as they would be in CSV.
+const (
+	parquetString parquetKind = iota
+	parquetInt64
+	parquetDouble
+	parquetBoolean
+	parquetBytes
+)
+
+func (k parquetKind) String() string {
+	switch k {
+	case parquetInt64:
+		return "int64"
+	case parquetDouble:
+		return "double"
+	case parquetBoolean:
+		return "boolean"
+	case parquetBytes:
+		return "bytes"
+	default:
+		return "string"
+	}
+}
+
+func (k parquetKind) node() parquet.Node {
+	switch k {
+	case parquetInt64:
+		return parquet.Leaf(parquet.Int64Type)
+	case parquetDouble:
+		return parquet.Leaf(parquet.DoubleType)
+	case parquetBoolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case parquetBytes:
+		return parquet.Leaf(parquet.ByteArrayType)
+	default:
+		return parquet.String()
+	}
+}
+
+// parquetColumnKind picks the column type for a field from the types seen in
+// the collection. Integers mixed with floats widen to double; a field never
+// seen (or only ever null) is a string column.
+func parquetColumnKind(fs *fieldSchema, cfg exportConfig) parquetKind {
+	if fs == nil {
+		return parquetString
+	}
+	switch len(fs.Types) {
+	case 1:
+		switch fs.Types[0] {
+		case "int":
+			return parquetInt64
+		case "float":
+			return parquetDouble
+		case "bool":
+			return parquetBoolean
+		case "bytes":
+			return parquetBytes
+		case "timestamp":
+			if isEpochFormat(cfg.timeFormat) {
+				return parquetInt64
+			}
+		}
+	case 2:
+		if fs.Types[0] == "float" && fs.Types[1] == "int" {
+			return parquetDouble
+		}
+	}
+	return parquetString
+}
+
+// parquetWriter writes documents as rows of a Parquet file. The reserved
+// columns are required; data columns are optional, so missing and null fields
+// are written as nulls rather than --null-value.
+type parquetWriter struct {
+	f       io.WriteCloser
+	w       *parquet.Writer
+	columns []parquetColumn // in schema order
+	parents int             // parent levels with --path-columns
+	vf      valueFormatter
+}
+
+type parquetColumn struct {
+	name     string
+	kind     parquetKind
+	reserved bool
+	parent   int // 1 + the index in parentValues of a --path-columns column
+}
+
+// newParquetWriter returns a writer whose columns are __path__, optionally the
+// parent columns, __create_time__ and __update_time__, and the header fields,
+// typed from schema. A nil schema makes every data column a string.
+func newParquetWriter(f io.WriteCloser, fieldSet map[string]struct{}, schema *collectionSchema, cfg exportConfig) *parquetWriter {
+	timeKind := parquetString
+	if isEpochFormat(cfg.timeFormat) {
+		timeKind = parquetInt64
+	}
+	reserved := map[string]parquetKind{"__path__": parquetString}
+	parents := make(map[string]int)
+	for i, col := range parentColumns(cfg.parentLevels) {
+		reserved[col] = parquetString
+		parents[col] = i + 1
+	}
+	if cfg.includeTimestamps {
+		reserved["__create_time__"] = timeKind
+		reserved["__update_time__"] = timeKind
+	}
+
+	kinds := make(map[string]parquetKind)
+	group := make(parquet.Group)
+	for name, kind := range reserved {
+		kinds[name] = kind
+		group[name] = kind.node()
+	}
+	for _, name := range headerFields(fieldSet, cfg) {
+		var fs *fieldSchema
+		if schema != nil {
+			fs = schema.Fields[name]
+		}
+		kind := parquetColumnKind(fs, cfg)
+		kinds[name] = kind
+		group[name] = parquet.Optional(kind.node())
+	}
+
+	ps := parquet.NewSchema("document", group)
+	pw := &parquetWriter{
+		f:       f,
+		w:       parquet.NewWriter(f, ps, parquet.Compression(&parquet.Snappy)),
+		parents: cfg.parentLevels,
+		vf:      newValueFormatter(cfg),
+	}
+	// Group lays out its columns by name, so the row is built in that order.
+	for _, field := range ps.Fields() {
+		_, isReserved := reserved[field.Name()]
+		pw.columns = append(pw.columns, parquetColumn{name: field.Name(), kind: kinds[field.Name()], reserved: isReserved, parent: parents[field.Name()]})
+	}
+	return pw
+}
+
+func (pw *parquetWriter) write(doc docRecord) error {
+	row := make(parquet.Row, len(pw.columns))
+	var parents []string
+	for i, col := range pw.columns {
+		var v any
+		switch {
+		case !col.reserved:
+			v = doc.data[col.name]
+		case col.parent > 0:
+			if parents == nil {
+				parents = parentValues(doc.path, pw.parents)
+			}
+			v = parents[col.parent-1]
+		case col.name == "__path__":
+			v = doc.path
+		case col.name == "__create_time__":
+			v = doc.createTime
+		default:
+			v = doc.updateTime
+		}
+		pv, err := pw.value(col, v)
+		if err != nil {
+			return fmt.Errorf("writing document %s: field %q: %w", doc.path, col.name, err)
+		}
+		// Optional columns are defined at level 1 when they hold a value.
+		def := 0
+		if !col.reserved && v != nil {
+			def = 1
+		}
+		row[i] = pv.Level(0, def, i)
+	}
+	if _, err := pw.w.WriteRows([]parquet.Row{row}); err != nil {
+		return fmt.Errorf("writing document %s: %w", doc.path, err)
+	}
+	return nil
+}
+
+// value converts v to the physical type of col.
+func (pw *parquetWriter) value(col parquetColumn, v any) (parquet.Value, error) {
+	if v == nil {
+		return parquet.NullValue(), nil
+	}
+	var pv parquet.Value
+	ok := true
+	switch col.kind {
+	case parquetInt64:
+		switch x := v.(type) {
+		case int64:
+			pv = parquet.Int64Value(x)
+		case time.Time:
+			pv = parquet.Int64Value(x.Unix())
+		default:
+			ok = false
+		}
+	case parquetDouble:
+		switch x := v.(type) {
+		case float64:
+			pv = parquet.DoubleValue(x)
+		case int64:
+			pv = parquet.DoubleValue(float64(x))
+		default:
+			ok = false
+		}
+	case parquetBoolean:
+		var b bool
+		b, ok = v.(bool)
+		pv = parquet.BooleanValue(b)
+	case parquetBytes:
+		var b []byte
+		b, ok = v.([]byte)
+		pv = parquet.ByteArrayValue(b)
+	default:
+		pv = parquet.ByteArrayValue([]byte(pw.vf.formatValue(v)))
+	}
+	if !ok {
+		// Only possible in --stream mode, when a document changes type
+		// between the discovery pass and the write.
+		return parquet.Value{}, fmt.Errorf("%s value in a %s column", typeLabel(v), col.kind)
+	}
+	return pv, nil
+}
+
+// flush writes the buffered rows out as a row group.
+func (pw *parquetWriter) flush() error {
+	return pw.w.Flush()
+}
+
+// close writes the remaining rows and the file footer before closing the
+// underlying file.
+func (pw *parquetWriter) close() error {
+	if err := pw.w.Close(); err != nil {
+		pw.f.Close()
+		return err
+	}
+	return pw.f.Close()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// readParquet returns the column types of a Parquet file and its rows, keyed
+// by column name.
+func readParquet(t *testing.T, path string) (map[string]string, []map[string]parquet.Value) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		t.Fatalf("parquet.OpenFile() error = %v", err)
+	}
+
+	fields := pf.Schema().Fields()
+	types := make(map[string]string, len(fields))
+	for _, field := range fields {
+		types[field.Name()] = field.Type().String()
+	}
+
+	r := parquet.NewReader(pf)
+	defer r.Close()
+	var rows []map[string]parquet.Value
+	buf := make([]parquet.Row, 16)
+	for {
+		n, err := r.ReadRows(buf)
+		for _, row := range buf[:n] {
+			m := make(map[string]parquet.Value, len(row))
+			for _, v := range row {
+				m[fields[v.Column()].Name()] = v
+			}
+			rows = append(rows, m)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadRows() error = %v", err)
+		}
+	}
+	return types, rows
+}
+
+func TestWriteCollection_Parquet(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "users/a", data: map[string]any{
+			"age":    int64(30),
+			"score":  int64(7),
+			"active": true,
+			"avatar": []byte{0x01, 0x02},
+			"name":   "Alice",
+			"tags":   []any{"a", "b"},
+		}},
+		{path: "users/b", data: map[string]any{
+			"age":   nil,
+			"score": 7.5,
+			"name":  "Bob",
+			"mixed": "x",
+		}},
+		{path: "users/c", data: map[string]any{"mixed": int64(1)}},
+	}
+	fieldSet := map[string]struct{}{}
+	for _, doc := range docs {
+		for k := range doc.data {
+			fieldSet[k] = struct{}{}
+		}
+	}
+
+	filePath, err := writeCollection(docs, fieldSet, "users", exportConfig{output: tmpDir, format: "parquet"})
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if filePath != filepath.Join(tmpDir, "users.parquet") {
+		t.Errorf("filePath = %q, want users.parquet under output dir", filePath)
+	}
+
+	types, rows := readParquet(t, filePath)
+	wantTypes := map[string]string{
+		"__path__": "STRING",
+		"active":   "BOOLEAN",
+		"age":      "INT(64,true)",
+		"avatar":   "BYTE_ARRAY",
+		"mixed":    "STRING",
+		"name":     "STRING",
+		"score":    "DOUBLE",
+		"tags":     "STRING",
+	}
+	for col, want := range wantTypes {
+		if types[col] != want {
+			t.Errorf("column %q type = %q, want %q", col, types[col], want)
+		}
+	}
+	if len(types) != len(wantTypes) {
+		t.Errorf("got columns %v, want %d", types, len(wantTypes))
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	a, b, c := rows[0], rows[1], rows[2]
+	if got := string(a["__path__"].ByteArray()); got != "users/a" {
+		t.Errorf("__path__ = %q, want users/a", got)
+	}
+	if got := a["age"].Int64(); got != 30 {
+		t.Errorf("age = %d, want 30", got)
+	}
+	if got := a["score"].Double(); got != 7 {
+		t.Errorf("score = %v, want 7 (int widened to double)", got)
+	}
+	if got := b["score"].Double(); got != 7.5 {
+		t.Errorf("score = %v, want 7.5", got)
+	}
+	if !a["active"].Boolean() {
+		t.Error("active = false, want true")
+	}
+	if got := a["avatar"].ByteArray(); string(got) != "\x01\x02" {
+		t.Errorf("avatar = %v, want raw bytes", got)
+	}
+	if got := string(a["tags"].ByteArray()); got != `["a","b"]` {
+		t.Errorf("tags = %q, want JSON array", got)
+	}
+	if got := string(c["mixed"].ByteArray()); got != "1" {
+		t.Errorf("mixed = %q, want 1 (conflicting types written as strings)", got)
+	}
+	for _, v := range []parquet.Value{b["age"], b["active"], c["name"]} {
+		if !v.IsNull() {
+			t.Errorf("value in column %d = %v, want null for null and missing fields", v.Column(), v)
+		}
+	}
+}
+
+func TestWriteCollection_ParquetTimestamps(t *testing.T) {
+	tmpDir := t.TempDir()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	docs := []docRecord{{
+		path:       "events/e1",
+		data:       map[string]any{"at": created},
+		createTime: created,
+		updateTime: created.Add(time.Hour),
+	}}
+	cfg := exportConfig{output: tmpDir, format: "parquet", timeFormat: timeFormatUnix, includeTimestamps: true}
+
+	filePath, err := writeCollection(docs, map[string]struct{}{"at": {}}, "events", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	types, rows := readParquet(t, filePath)
+	for _, col := range []string{"at", "__create_time__", "__update_time__"} {
+		if types[col] != "INT(64,true)" {
+			t.Errorf("column %q type = %q, want int64 with --time-format unix", col, types[col])
+		}
+	}
+	if got := rows[0]["__update_time__"].Int64(); got != created.Add(time.Hour).Unix() {
+		t.Errorf("__update_time__ = %d, want %d", got, created.Add(time.Hour).Unix())
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// partitionCursors returns the document paths, relative to the database and
+// in order, at which Firestore's PartitionQuery API splits the collection
+// group id into up to n parts of similar size. It may return fewer cursors
+// than n-1, or none for a small group.
+func partitionCursors(ctx context.Context, client *firestore.Client, id string, n int) ([]string, error) {
+	parts, err := client.CollectionGroup(id).GetPartitionedQueries(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	// The partitions don't expose their cursors, but their queries carry
+	// them: each but the last ends before the next one's first document.
+	var cursors []string
+	for _, part := range parts {
+		b, err := part.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		var req firestorepb.RunQueryRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			return nil, err
+		}
+		values := req.GetStructuredQuery().GetEndAt().GetValues()
+		if len(values) == 0 {
+			continue
+		}
+		if _, rel, ok := strings.Cut(values[0].GetReferenceValue(), "/documents/"); ok {
+			cursors = append(cursors, rel)
+		}
+	}
+	return cursors, nil
+}
+
+// partitionQueries splits query, which reads in document ID order, at the
+// given cursor values: the first part ends before the first cursor and each
+// other part starts at its cursor, so the parts read what query does between
+// them. query keeps any --id-start and --id-end bounds as its outer ones.
+func partitionQueries(query firestore.Query, cursors []any) []firestore.Query {
+	queries := make([]firestore.Query, 0, len(cursors)+1)
+	var prev any
+	for _, c := range cursors {
+		q := query
+		if prev != nil {
+			q = q.StartAt(prev)
+		}
+		queries = append(queries, q.EndBefore(c))
+		prev = c
+	}
+	if prev != nil {
+		query = query.StartAt(prev)
+	}
+	return append(queries, query)
+}
+
+// groupCursors turns the cursors of a collection group into the cursor values
+// of a query on it. A plain string cursor would be resolved against the
+// query's parent, which for a collection group is the database rather than
+// its documents, so the paths are passed as document references instead.
+func groupCursors(client *firestore.Client, cursors []string) []any {
+	refs := make([]any, len(cursors))
+	for i, c := range cursors {
+		refs[i] = client.Doc(c)
+	}
+	return refs
+}
+
+// collectionCursors keeps the cursors of a collection group that are
+// documents of the top-level collection name, strictly inside the --id-start
+// and --id-end range, and returns their IDs: the cursor values of a query on
+// the collection. Documents of same-named collections elsewhere in the
+// database are dropped, which only merges parts.
+func collectionCursors(cursors []string, name string, cfg exportConfig) []any {
+	var ids []any
+	for _, c := range cursors {
+		parent, id, ok := strings.Cut(c, "/")
+		if !ok || parent != name || strings.Contains(id, "/") {
+			continue
+		}
+		if id <= cfg.idStart || (cfg.idEnd != "" && id > cfg.idEnd) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// partitionedScan returns the scanFunc of a top-level collection or
+// collection group read with --partitions: query split into up to
+// cfg.partitions parts by partitionQueries. toCursor turns the group's cursors
+// into query's cursor values. If Firestore can't partition the query, or the
+// collection is too small to, it is read by queryScan as usual.
+func partitionedScan(ctx context.Context, client *firestore.Client, id string, query firestore.Query, toCursor func([]string) []any, displayPath string, cfg exportConfig) scanFunc {
+	cursors, err := partitionCursors(ctx, client, id, cfg.partitions)
+	if err != nil {
+		printWarn("Couldn't partition %q, reading it with one query: %v", displayPath, err)
+		return queryScan(ctx, []firestore.Query{query}, 0, cfg)
+	}
+	queries := partitionQueries(query, toCursor(cursors))
+	if verbosity >= verboseQueries {
+		printDebugFor(displayPath, "Reading %q in %d partition(s)", displayPath, len(queries))
+	}
+	if len(queries) == 1 {
+		return queryScan(ctx, queries, 0, cfg)
+	}
+	return partitionScan(ctx, queries, cfg)
+}
+
+// partitionScan returns a scanFunc that runs the queries concurrently, one
+// goroutine each. fn is called for one document at a time, so the callbacks
+// of readAndExport and streamAndExport, and the writers behind them, need no
+// locking of their own; documents of different partitions arrive in no
+// particular order. The first error stops the other partitions.
+func partitionScan(ctx context.Context, queries []firestore.Query, cfg exportConfig) scanFunc {
+	return func(sp *spinner, label string, fn func(snap *firestore.DocumentSnapshot) error) (int, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var mu sync.Mutex
+		var firstErr error
+		count := 0
+		var wg sync.WaitGroup
+		for _, query := range queries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := scanQuery(ctx, query, 0, cfg.pageSize, cfg.maxRetries, cfg.limiter, func(snap *firestore.DocumentSnapshot) error {
+					mu.Lock()
+					defer mu.Unlock()
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+					if err := fn(snap); err != nil {
+						return err
+					}
+					count++
+					sp.SetSuffix(fmt.Sprintf("%s %s documents", label, fmtInt(count)))
+					return nil
+				})
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					cancel()
+				}
+			}()
+		}
+		wg.Wait()
+		return count, firstErr
+	}
+}
+
+// comparePaths orders document paths segment by segment, as Firestore orders
+// documents by name, so documents read by several partitions can be put back
+// in the order one query returns them.
+func comparePaths(a, b string) int {
+	return slices.Compare(strings.Split(a, "/"), strings.Split(b, "/"))
+}
+
+// validatePartitions rejects options that --partitions can't honor. The
+// parts are ranges of document IDs, so the query has to read in ID order.
+func validatePartitions(cfg exportConfig) error {
+	switch {
+	case cfg.preview > 0:
+		return fmt.Errorf("--preview reads only a few documents; it can't be combined with --partitions")
+	case cfg.limit > 0 || len(cfg.limitPer) > 0:
+		return fmt.Errorf("--partitions reads whole collections; it can't be combined with --limit or --limit-per")
+	case len(cfg.orderBy) > 0:
+		return fmt.Errorf("--partitions reads in document ID order; it can't be combined with --order-by")
+	case len(cfg.ids) > 0:
+		return fmt.Errorf("--partitions can't be combined with --ids")
+	case cfg.resume:
+		return fmt.Errorf("--partitions can't be combined with --resume, which reads in one pass to checkpoint")
+	}
+	for _, wf := range cfg.where {
+		if isInequalityOp(wf.op) {
+			return fmt.Errorf("--partitions reads in document ID order, which a %s filter would change; only ==, in, array-contains and array-contains-any filters can be combined with it", wf.op)
+		}
+	}
+	return nil
+}
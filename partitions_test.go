@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCollectionCursors(t *testing.T) {
+	cursors := []string{"users/a", "teams/t/users/b", "users/c", "users/c/users/d", "usersx/e", "users/f"}
+	if got, want := collectionCursors(cursors, "users", exportConfig{}), []any{"a", "c", "f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collectionCursors() = %v, want %v", got, want)
+	}
+
+	// Cursors on or outside the --id-start/--id-end bounds would make empty
+	// parts or read past them.
+	cfg := exportConfig{idStart: "a", idEnd: "e"}
+	if got, want := collectionCursors(cursors, "users", cfg), []any{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collectionCursors(id range) = %v, want %v", got, want)
+	}
+	if got := collectionCursors(nil, "users", exportConfig{}); len(got) != 0 {
+		t.Errorf("collectionCursors(nil) = %v, want none", got)
+	}
+}
+
+func TestPartitionQueries_CollectionGroup(t *testing.T) {
+	// The emulator needs no credentials; nothing is sent to it.
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8080")
+	client, err := firestore.NewClient(context.Background(), "p")
+	if err != nil {
+		t.Skipf("cannot create Firestore client: %v", err)
+	}
+	defer client.Close()
+
+	query := applyOrderBy(client.CollectionGroup("users").Query, nil, nil)
+	queries := partitionQueries(query, groupCursors(client, []string{"users/a", "teams/t/users/b"}))
+	if len(queries) != 3 {
+		t.Fatalf("got %d queries, want 3", len(queries))
+	}
+	const docs = "projects/p/databases/(default)/documents/"
+	want := []struct{ start, end string }{
+		{"", docs + "users/a"},
+		{docs + "users/a", docs + "teams/t/users/b"},
+		{docs + "teams/t/users/b", ""},
+	}
+	for i, q := range queries {
+		b, err := q.Serialize()
+		if err != nil {
+			t.Fatalf("query %d: Serialize() error = %v", i, err)
+		}
+		var req firestorepb.RunQueryRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			t.Fatalf("query %d: Unmarshal() error = %v", i, err)
+		}
+		sq := req.GetStructuredQuery()
+		if got := cursorRef(sq.GetStartAt()); got != want[i].start {
+			t.Errorf("query %d: start_at = %q, want %q", i, got, want[i].start)
+		}
+		if got := cursorRef(sq.GetEndAt()); got != want[i].end {
+			t.Errorf("query %d: end_at = %q, want %q", i, got, want[i].end)
+		}
+	}
+}
+
+// cursorRef returns the document reference a query cursor starts or ends at.
+func cursorRef(c *firestorepb.Cursor) string {
+	if len(c.GetValues()) == 0 {
+		return ""
+	}
+	return c.GetValues()[0].GetReferenceValue()
+}
+
+func TestComparePaths(t *testing.T) {
+	// Plain string order would put "users/a/orders/1" after "users/a-b".
+	paths := []string{"users/b", "users/a-b", "users/a/orders/1", "users/a", "teams/x"}
+	slices.SortFunc(paths, comparePaths)
+	want := []string{"teams/x", "users/a", "users/a/orders/1", "users/a-b", "users/b"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("sorted = %v, want %v", paths, want)
+	}
+	if comparePaths("users/a", "users/a") != 0 {
+		t.Error("comparePaths(same) != 0")
+	}
+}
+
+func TestValidatePartitions(t *testing.T) {
+	base := exportConfig{partitions: 4, where: []whereFilter{{field: "status", op: "==", value: "active"}}}
+	if err := validatePartitions(base); err != nil {
+		t.Errorf("validatePartitions() error = %v", err)
+	}
+	invalid := map[string]func(*exportConfig){
+		"--preview":   func(c *exportConfig) { c.preview = 5 },
+		"--limit":     func(c *exportConfig) { c.limit = 10 },
+		"--limit-per": func(c *exportConfig) { c.limitPer = map[string]int{"users": 10} },
+		"--order-by":  func(c *exportConfig) { c.orderBy = []orderClause{{field: "name"}} },
+		"--ids":       func(c *exportConfig) { c.ids = []string{"a"} },
+		"--resume":    func(c *exportConfig) { c.resume = true },
+		"--where >":   func(c *exportConfig) { c.where = []whereFilter{{field: "age", op: ">", value: int64(1)}} },
+	}
+	for name, mutate := range invalid {
+		cfg := base
+		mutate(&cfg)
+		if err := validatePartitions(cfg); err == nil {
+			t.Errorf("validatePartitions(%s) error = nil, want error", name)
+		}
+	}
+}
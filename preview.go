@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// previewCellWidth is the widest a --preview cell is printed, in runes, so a
+// few columns fit next to each other in a terminal.
+const previewCellWidth = 40
+
+// previewLimit caps a --limit, --limit-per or --child-limit value at the
+// --preview count n; 0 means no limit.
+func previewLimit(limit, n int) int {
+	if limit == 0 || limit > n {
+		return n
+	}
+	return limit
+}
+
+// printPreview prints the documents of a collection read with --preview as a
+// table on stderr: __path__, then the CSV header's columns, with cells
+// formatted as in a CSV. The table is written at once, so those of
+// collections exported concurrently don't interleave.
+func printPreview(displayPath string, docs []docRecord, fieldSet map[string]struct{}, cfg exportConfig) {
+	if len(docs) == 0 || logJSON {
+		return
+	}
+	vf := newValueFormatter(cfg)
+	fields := headerFields(fieldSet, cfg)
+	header := append([]string{"__path__"}, fields...)
+	rows := make([][]string, len(docs))
+	for i, doc := range docs {
+		row := make([]string, len(header))
+		row[0] = previewCell(doc.path)
+		for j, field := range fields {
+			if v, ok := doc.data[field]; ok {
+				row[j+1] = previewCell(vf.formatValue(v))
+			}
+		}
+		rows[i] = row
+	}
+	widths := columnWidths(header, rows)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n %s\n", bold(displayPath))
+	cells := make([]string, len(header))
+	for i, h := range header {
+		// Padded before bold() adds its escape codes, as in printSummaryTable.
+		cells[i] = bold(fmt.Sprintf("%-*s", widths[i], h))
+	}
+	fmt.Fprintf(&b, " %s\n", strings.Join(cells, "  "))
+	for i, w := range widths {
+		cells[i] = faint(strings.Repeat("─", w))
+	}
+	fmt.Fprintf(&b, " %s\n", strings.Join(cells, "  "))
+	for _, row := range rows {
+		for i, cell := range row {
+			cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+		}
+		fmt.Fprintf(&b, " %s\n", strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+// previewEscaper keeps a multi-line value on its row.
+var previewEscaper = strings.NewReplacer("\r", `\r`, "\n", `\n`, "\t", `\t`)
+
+// previewCell returns s as a --preview cell: on one line and cut to
+// previewCellWidth runes, ending in "…" if it was cut.
+func previewCell(s string) string {
+	s = previewEscaper.Replace(s)
+	if utf8.RuneCountInString(s) <= previewCellWidth {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:previewCellWidth-1]) + "…"
+}
+
+// validatePreview rejects options that --preview can't honor.
+func validatePreview(cfg exportConfig) error {
+	if cfg.resume {
+		return fmt.Errorf("--preview writes no files, so there is nothing to --resume")
+	}
+	return nil
+}
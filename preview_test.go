@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPreviewLimit(t *testing.T) {
+	tests := []struct{ limit, n, want int }{
+		{0, 5, 5},
+		{10, 5, 5},
+		{3, 5, 3},
+	}
+	for _, tt := range tests {
+		if got := previewLimit(tt.limit, tt.n); got != tt.want {
+			t.Errorf("previewLimit(%d, %d) = %d, want %d", tt.limit, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPreviewCell(t *testing.T) {
+	if got, want := previewCell("line 1\nline 2\tend"), `line 1\nline 2\tend`; got != want {
+		t.Errorf("previewCell() = %q, want %q", got, want)
+	}
+	long := strings.Repeat("é", previewCellWidth+5)
+	got := previewCell(long)
+	if n := utf8.RuneCountInString(got); n != previewCellWidth || !strings.HasSuffix(got, "…") {
+		t.Errorf("previewCell(long) = %q (%d runes), want %d runes ending in …", got, n, previewCellWidth)
+	}
+	exact := strings.Repeat("x", previewCellWidth)
+	if got := previewCell(exact); got != exact {
+		t.Errorf("previewCell(%d runes) = %q, want it unchanged", previewCellWidth, got)
+	}
+}
+
+func TestPrintPreview(t *testing.T) {
+	docs := []docRecord{
+		{path: "users/alice", data: map[string]any{"name": "Alice", "age": int64(30)}},
+		{path: "users/bob", data: map[string]any{"name": "Bob", "tags": []any{"a", "b"}}},
+	}
+	fieldSet := map[string]struct{}{"name": {}, "age": {}, "tags": {}}
+	out := captureStderr(t, func() { printPreview("users", docs, fieldSet, exportConfig{}) })
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")[1:]
+	want := []string{
+		" users",
+		" __path__     age  name   tags     ",
+		" ───────────  ───  ─────  ─────────",
+		" users/alice  30   Alice",
+		` users/bob         Bob    ["a","b"]`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("preview =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	if out := captureStderr(t, func() { printPreview("users", nil, nil, exportConfig{}) }); out != "" {
+		t.Errorf("preview of no documents = %q, want nothing", out)
+	}
+}
+
+func TestValidatePreview(t *testing.T) {
+	if err := validatePreview(exportConfig{preview: 5}); err != nil {
+		t.Errorf("validatePreview() error = %v", err)
+	}
+	if err := validatePreview(exportConfig{preview: 5, resume: true}); err == nil {
+		t.Error("validatePreview(--resume) error = nil, want error")
+	}
+}
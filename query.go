@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// validWhereOps enumerates the operators accepted by --where.
+var validWhereOps = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"in": true, "not-in": true, "array-contains": true, "array-contains-any": true,
+}
+
+// isListOp reports whether a --where operator compares against a list of
+// values rather than a single one.
+func isListOp(op string) bool {
+	return op == "in" || op == "not-in" || op == "array-contains-any"
+}
+
+// whereFilter is a single parsed --where clause.
+type whereFilter struct {
+	field string
+	op    string
+	value any
+}
+
+// parseWhereFilters parses repeated --where values of the form "field op value".
+func parseWhereFilters(raws []string) ([]whereFilter, error) {
+	filters := make([]whereFilter, 0, len(raws))
+	for _, raw := range raws {
+		wf, err := parseWhereFilter(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --where %q: %w", raw, err)
+		}
+		filters = append(filters, wf)
+	}
+	return filters, nil
+}
+
+// parseWhereFilter parses a single "field op value" clause. The value is
+// everything after the operator, so it may contain spaces.
+func parseWhereFilter(raw string) (whereFilter, error) {
+	var wf whereFilter
+
+	field, rest, ok := strings.Cut(strings.TrimSpace(raw), " ")
+	if !ok || field == "" {
+		return wf, fmt.Errorf("expected \"field op value\"")
+	}
+	op, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok {
+		return wf, fmt.Errorf("expected \"field op value\"")
+	}
+	if !validWhereOps[op] {
+		return wf, fmt.Errorf("unknown operator %q; supported: ==, !=, <, <=, >, >=, in, not-in, array-contains, array-contains-any", op)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return wf, fmt.Errorf("missing value")
+	}
+
+	wf.field = field
+	wf.op = op
+	bracketed := strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]")
+	switch {
+	case isListOp(op):
+		// List operators take [a, b, c], or the same list without brackets.
+		if bracketed {
+			value = strings.TrimSpace(value[1 : len(value)-1])
+		}
+		if value == "" {
+			return wf, fmt.Errorf("operator %q needs at least one value", op)
+		}
+		var values []any
+		for _, v := range splitWhereList(value) {
+			values = append(values, parseWhereValue(strings.TrimSpace(v)))
+		}
+		wf.value = values
+	case bracketed:
+		return wf, fmt.Errorf("operator %q takes a single value, not a list; quote it to match the string %s", op, value)
+	default:
+		wf.value = parseWhereValue(value)
+	}
+	return wf, nil
+}
+
+// splitWhereList splits a list value on commas outside double quotes, so a
+// quoted element like "a, b" stays whole.
+func splitWhereList(raw string) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			if quoted {
+				i++ // skip the escaped character
+			}
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, raw[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, raw[start:])
+}
+
+// parseWhereValue converts a raw value into the Go type Firestore should compare
+// against: bool, int64, float64, or string. Double-quoted values are always
+// treated as strings, so `"42"` matches the string "42" rather than the number.
+func parseWhereValue(raw string) any {
+	if len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+		if s, err := strconv.Unquote(raw); err == nil {
+			return s
+		}
+		return raw[1 : len(raw)-1]
+	}
+	switch raw {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f
+	}
+	return raw
+}
+
+// isInequalityOp reports whether a --where operator is a range or inequality
+// comparison, which constrains how Firestore allows the query to be ordered.
+func isInequalityOp(op string) bool {
+	switch op {
+	case "==", "in", "array-contains", "array-contains-any":
+		return false
+	default:
+		return true
+	}
+}
+
+// modifiedSinceFilter returns the filter for --modified-since and
+// --modified-field: documents whose field is later than the RFC3339 timestamp
+// since. Firestore doesn't expose update times to queries, so the field has to
+// be one the application maintains itself.
+func modifiedSinceFilter(since, field string) (whereFilter, error) {
+	if field == "" {
+		return whereFilter{}, fmt.Errorf("--modified-since requires --modified-field, the document field holding its last update time")
+	}
+	t, err := time.Parse(time.RFC3339Nano, since)
+	if err != nil {
+		return whereFilter{}, fmt.Errorf("invalid --modified-since %q: must be an RFC3339 timestamp such as 2024-06-01T00:00:00Z", since)
+	}
+	return whereFilter{field: field, op: ">", value: t}, nil
+}
+
+// applyWhereFilters ANDs all filters onto the query.
+func applyWhereFilters(query firestore.Query, filters []whereFilter) firestore.Query {
+	for _, wf := range filters {
+		query = query.Where(wf.field, wf.op, wf.value)
+	}
+	return query
+}
+
+// parseFieldList parses a comma-separated list of field names, keeping the
+// given order. Blank entries are skipped; duplicates are an error.
+func parseFieldList(raw string) ([]string, error) {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if seen[f] {
+			return nil, fmt.Errorf("duplicate field %q", f)
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// fieldNameSet returns the names as a set, or nil if there are none.
+func fieldNameSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// applyFieldSelection projects the query onto the given fields so Firestore
+// only returns the data that will be exported. No fields means no projection.
+func applyFieldSelection(query firestore.Query, fields []string) firestore.Query {
+	if len(fields) == 0 {
+		return query
+	}
+	return query.Select(fields...)
+}
+
+// orderClause is a single parsed --order-by entry.
+type orderClause struct {
+	field string
+	dir   firestore.Direction
+}
+
+// parseOrderBy parses a comma-separated --order-by value such as
+// "createdAt:desc,name". The direction defaults to asc; __name__ orders by
+// document ID.
+func parseOrderBy(raw string) ([]orderClause, error) {
+	var orders []orderClause
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, dir, hasDir := strings.Cut(entry, ":")
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return nil, fmt.Errorf("missing field name in %q", entry)
+		}
+		oc := orderClause{field: field, dir: firestore.Asc}
+		if hasDir {
+			switch strings.ToLower(strings.TrimSpace(dir)) {
+			case "asc":
+			case "desc":
+				oc.dir = firestore.Desc
+			default:
+				return nil, fmt.Errorf("invalid direction %q for %q: must be asc or desc", dir, field)
+			}
+		}
+		orders = append(orders, oc)
+	}
+	return orders, nil
+}
+
+// parseLimitPer parses a comma-separated --limit-per value such as
+// "logs=100,events=500" into per-collection document limits. A limit of 0
+// exports the whole collection, as with --limit.
+func parseLimitPer(raw string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected collection=limit", entry)
+		}
+		if _, dup := limits[name]; dup {
+			return nil, fmt.Errorf("duplicate collection %q", name)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit %q for %q: must be a non-negative integer", value, name)
+		}
+		limits[name] = n
+	}
+	return limits, nil
+}
+
+// applyOrderBy orders the query by the given clauses. Without clauses it
+// orders by document ID so output is stable across runs, unless the filters
+// include an inequality, in which case Firestore's implicit ordering (the
+// filtered fields, then document ID) is kept since it is stable too.
+func applyOrderBy(query firestore.Query, orders []orderClause, filters []whereFilter) firestore.Query {
+	if len(orders) == 0 {
+		for _, wf := range filters {
+			if isInequalityOp(wf.op) {
+				return query
+			}
+		}
+		return query.OrderBy(firestore.DocumentID, firestore.Asc)
+	}
+	for _, oc := range orders {
+		query = query.OrderBy(oc.field, oc.dir)
+	}
+	return query
+}
+
+// describeQuery renders a query built from these settings for --verbose, in
+// the form `where status == "active" order by __name__ asc limit 100`. The
+// order is the one applyOrderBy picks.
+func describeQuery(filters []whereFilter, orders []orderClause, fields []string, limit int) string {
+	var parts []string
+	for i, wf := range filters {
+		keyword := "and"
+		if i == 0 {
+			keyword = "where"
+		}
+		value, err := json.Marshal(wf.value)
+		if err != nil {
+			value = []byte(fmt.Sprint(wf.value))
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s %s", keyword, wf.field, wf.op, value))
+	}
+	if len(fields) > 0 {
+		parts = append(parts, "select "+strings.Join(fields, ", "))
+	}
+	if len(orders) == 0 {
+		// applyOrderBy leaves the order to Firestore after an inequality.
+		orders = []orderClause{{field: firestore.DocumentID, dir: firestore.Asc}}
+		for _, wf := range filters {
+			if isInequalityOp(wf.op) {
+				orders = nil
+				break
+			}
+		}
+	}
+	if len(orders) > 0 {
+		clauses := make([]string, len(orders))
+		for i, oc := range orders {
+			dir := "asc"
+			if oc.dir == firestore.Desc {
+				dir = "desc"
+			}
+			clauses[i] = oc.field + " " + dir
+		}
+		parts = append(parts, "order by "+strings.Join(clauses, ", "))
+	}
+	if limit > 0 {
+		parts = append(parts, fmt.Sprintf("limit %d", limit))
+	}
+	return strings.Join(parts, " ")
+}
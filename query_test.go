@@ -0,0 +1,246 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+func TestParseWhereFilter(t *testing.T) {
+	tests := []struct {
+		input string
+		want  whereFilter
+	}{
+		{`status == active`, whereFilter{"status", "==", "active"}},
+		{`age >= 21`, whereFilter{"age", ">=", int64(21)}},
+		{`score < 9.5`, whereFilter{"score", "<", float64(9.5)}},
+		{`active != false`, whereFilter{"active", "!=", false}},
+		{`code == "42"`, whereFilter{"code", "==", "42"}},
+		{`name == John Smith`, whereFilter{"name", "==", "John Smith"}},
+		{`  tags   array-contains   go  `, whereFilter{"tags", "array-contains", "go"}},
+		{`address.city == Berlin`, whereFilter{"address.city", "==", "Berlin"}},
+		{`status in active, pending,3`, whereFilter{"status", "in", []any{"active", "pending", int64(3)}}},
+		{`status in [active,pending,trial]`, whereFilter{"status", "in", []any{"active", "pending", "trial"}}},
+		{`status in [active]`, whereFilter{"status", "in", []any{"active"}}},
+		{`code not-in [ 1, "2", true ]`, whereFilter{"code", "not-in", []any{int64(1), "2", true}}},
+		{`tags array-contains-any [go, "a, b", "say \"hi\", bye"]`, whereFilter{"tags", "array-contains-any", []any{"go", "a, b", `say "hi", bye`}}},
+		{`label == "[draft]"`, whereFilter{"label", "==", "[draft]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseWhereFilter(tt.input)
+			if err != nil {
+				t.Fatalf("parseWhereFilter(%q) unexpected error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWhereFilter(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWhereFilter_Invalid(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{"", "expected"},
+		{"status", "expected"},
+		{"status ==", "expected"},
+		{"status ~= active", "unknown operator"},
+		{"status like active", "unknown operator"},
+		{"status == [active,pending]", "single value"},
+		{"tags array-contains [go]", "single value"},
+		{"status in []", "at least one value"},
+		{"status not-in [ ]", "at least one value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parseWhereFilter(tt.input)
+			if err == nil {
+				t.Fatalf("parseWhereFilter(%q) expected error", tt.input)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseWhereFilters(t *testing.T) {
+	filters, err := parseWhereFilters([]string{"status == active", "age > 18"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filters) != 2 {
+		t.Fatalf("got %d filters, want 2", len(filters))
+	}
+
+	_, err = parseWhereFilters([]string{"status == active", "bogus"})
+	if err == nil {
+		t.Fatal("expected error for malformed clause")
+	}
+	if !strings.Contains(err.Error(), `invalid --where "bogus"`) {
+		t.Errorf("error = %q, want it to name the bad clause", err.Error())
+	}
+}
+
+func TestParseWhereValue(t *testing.T) {
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{"true", true},
+		{"false", false},
+		{"0", int64(0)},
+		{"-7", int64(-7)},
+		{"1.25", float64(1.25)},
+		{"1e3", float64(1000)},
+		{"hello", "hello"},
+		{`"true"`, "true"},
+		{`"12"`, "12"},
+		{`""`, ""},
+	}
+	for _, tt := range tests {
+		if got := parseWhereValue(tt.input); got != tt.want {
+			t.Errorf("parseWhereValue(%q) = %#v (%T), want %#v (%T)", tt.input, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestParseFieldList(t *testing.T) {
+	got, err := parseFieldList(" name, email ,,age ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"name", "email", "age"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFieldList() = %v, want %v", got, want)
+	}
+
+	if got, err := parseFieldList(""); err != nil || got != nil {
+		t.Errorf("parseFieldList(\"\") = %v, %v; want nil, nil", got, err)
+	}
+
+	if _, err := parseFieldList("name,email,name"); err == nil || !strings.Contains(err.Error(), `duplicate field "name"`) {
+		t.Errorf("expected duplicate field error, got %v", err)
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	got, err := parseOrderBy("createdAt:desc, name ,__name__:ASC")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []orderClause{
+		{field: "createdAt", dir: firestore.Desc},
+		{field: "name", dir: firestore.Asc},
+		{field: firestore.DocumentID, dir: firestore.Asc},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOrderBy() = %v, want %v", got, want)
+	}
+
+	if got, err := parseOrderBy(""); err != nil || got != nil {
+		t.Errorf("parseOrderBy(\"\") = %v, %v; want nil, nil", got, err)
+	}
+
+	invalid := map[string]string{
+		"name:up": `invalid direction "up"`,
+		":desc":   "missing field name",
+	}
+	for input, wantErr := range invalid {
+		if _, err := parseOrderBy(input); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("parseOrderBy(%q) error = %v, want containing %q", input, err, wantErr)
+		}
+	}
+}
+
+func TestParseLimitPer(t *testing.T) {
+	got, err := parseLimitPer("logs=100, events = 500,,all=0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]int{"logs": 100, "events": 500, "all": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLimitPer() = %v, want %v", got, want)
+	}
+
+	if got, err := parseLimitPer(""); err != nil || len(got) != 0 {
+		t.Errorf("parseLimitPer(\"\") = %v, %v; want empty, nil", got, err)
+	}
+
+	invalid := map[string]string{
+		"logs":          "expected collection=limit",
+		"=10":           "expected collection=limit",
+		"logs=ten":      `invalid limit "ten"`,
+		"logs=-1":       `invalid limit "-1"`,
+		"logs=1,logs=2": `duplicate collection "logs"`,
+	}
+	for input, wantErr := range invalid {
+		if _, err := parseLimitPer(input); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("parseLimitPer(%q) error = %v, want containing %q", input, err, wantErr)
+		}
+	}
+}
+
+func TestIsInequalityOp(t *testing.T) {
+	for _, op := range []string{"==", "in", "array-contains", "array-contains-any"} {
+		if isInequalityOp(op) {
+			t.Errorf("isInequalityOp(%q) = true, want false", op)
+		}
+	}
+	for _, op := range []string{"!=", "<", "<=", ">", ">=", "not-in"} {
+		if !isInequalityOp(op) {
+			t.Errorf("isInequalityOp(%q) = false, want true", op)
+		}
+	}
+}
+
+func TestModifiedSinceFilter(t *testing.T) {
+	wf, err := modifiedSinceFilter("2024-06-01T12:00:00+02:00", "updatedAt")
+	if err != nil {
+		t.Fatalf("modifiedSinceFilter() error = %v", err)
+	}
+	want := whereFilter{field: "updatedAt", op: ">", value: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)}
+	if wf.field != want.field || wf.op != want.op || !wf.value.(time.Time).Equal(want.value.(time.Time)) {
+		t.Errorf("modifiedSinceFilter() = %+v, want %+v", wf, want)
+	}
+
+	if _, err := modifiedSinceFilter("2024-06-01T12:00:00Z", ""); err == nil || !strings.Contains(err.Error(), "--modified-field") {
+		t.Errorf("expected --modified-field error, got %v", err)
+	}
+	if _, err := modifiedSinceFilter("yesterday", "updatedAt"); err == nil {
+		t.Error("expected error for invalid timestamp")
+	}
+}
+
+func TestDescribeQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []whereFilter
+		orders  []orderClause
+		fields  []string
+		limit   int
+		want    string
+	}{
+		{"default", nil, nil, nil, 0, "order by __name__ asc"},
+		{
+			"everything",
+			[]whereFilter{{field: "status", op: "==", value: "active"}, {field: "tier", op: "in", value: []any{int64(1), int64(2)}}},
+			[]orderClause{{field: "createdAt", dir: firestore.Desc}, {field: "name", dir: firestore.Asc}},
+			[]string{"name", "email"},
+			100,
+			`where status == "active" and tier in [1,2] select name, email order by createdAt desc, name asc limit 100`,
+		},
+		{"inequality", []whereFilter{{field: "age", op: ">", value: int64(30)}}, nil, nil, 0, "where age > 30"},
+	}
+	for _, tt := range tests {
+		if got := describeQuery(tt.filters, tt.orders, tt.fields, tt.limit); got != tt.want {
+			t.Errorf("describeQuery(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"golang.org/x/time/rate"
+)
+
+// refResolver replaces the references in top-level fields, alone or in
+// arrays, with data of the documents they point to, for --resolve-refs. Each
+// target is read once and cached, since many documents tend to point at the
+// same few (the customer of every order). Collections exported concurrently
+// share the cache, so it is locked, and each document gets a copy of the
+// cached value, which later steps such as --sanitize may change in place.
+type refResolver struct {
+	client  *firestore.Client
+	field   string // --resolve-ref-field; "" = the whole document
+	limiter *rate.Limiter
+
+	mu    sync.Mutex
+	cache map[string]any // document path → resolved value
+}
+
+func newRefResolver(client *firestore.Client, field string, limiter *rate.Limiter) *refResolver {
+	return &refResolver{client: client, field: field, limiter: limiter, cache: make(map[string]any)}
+}
+
+// resolve replaces the references in data, reading the targets that aren't
+// cached in one GetAll call. A reference to a document that doesn't exist, or
+// lacks the field, resolves to nil. A nil resolver leaves data as it is.
+func (r *refResolver) resolve(ctx context.Context, data map[string]any) error {
+	if r == nil {
+		return nil
+	}
+	var uncached []*firestore.DocumentRef
+	queued := make(map[string]bool)
+	r.mu.Lock()
+	for _, v := range data {
+		for _, ref := range topLevelRefs(v) {
+			if _, ok := r.cache[ref.Path]; !ok && !queued[ref.Path] {
+				queued[ref.Path] = true
+				uncached = append(uncached, ref)
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	if len(uncached) > 0 {
+		for range uncached {
+			if err := waitForRead(ctx, r.limiter); err != nil {
+				return err
+			}
+		}
+		snaps, err := r.client.GetAll(ctx, uncached)
+		if err != nil {
+			return fmt.Errorf("resolving references: %w", err)
+		}
+		r.mu.Lock()
+		for i, snap := range snaps {
+			r.cache[uncached[i].Path] = r.value(snap)
+		}
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, v := range data {
+		switch v := v.(type) {
+		case *firestore.DocumentRef:
+			if v != nil {
+				data[k] = copyValue(r.cache[v.Path])
+			}
+		case []any:
+			if len(topLevelRefs(v)) == 0 {
+				continue
+			}
+			resolved := make([]any, len(v))
+			for i, elem := range v {
+				if ref, ok := elem.(*firestore.DocumentRef); ok && ref != nil {
+					resolved[i] = copyValue(r.cache[ref.Path])
+				} else {
+					resolved[i] = elem
+				}
+			}
+			data[k] = resolved
+		}
+	}
+	return nil
+}
+
+// value returns what a reference to snap's document is replaced with.
+func (r *refResolver) value(snap *firestore.DocumentSnapshot) any {
+	if !snap.Exists() {
+		return nil
+	}
+	if r.field == "" {
+		return snap.Data()
+	}
+	v, err := snap.DataAt(r.field)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// copyValue returns a deep copy of the maps and arrays in v. Other values are
+// immutable or, like references, never modified, so they are shared.
+func copyValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, elem := range v {
+			out[k] = copyValue(elem)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, elem := range v {
+			out[i] = copyValue(elem)
+		}
+		return out
+	}
+	return v
+}
+
+// topLevelRefs returns the references v holds: v itself, or the elements of
+// an array. References nested deeper are left alone.
+func topLevelRefs(v any) []*firestore.DocumentRef {
+	switch v := v.(type) {
+	case *firestore.DocumentRef:
+		if v != nil {
+			return []*firestore.DocumentRef{v}
+		}
+	case []any:
+		var refs []*firestore.DocumentRef
+		for _, elem := range v {
+			if ref, ok := elem.(*firestore.DocumentRef); ok && ref != nil {
+				refs = append(refs, ref)
+			}
+		}
+		return refs
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+)
+
+func TestTopLevelRefs(t *testing.T) {
+	a := &firestore.DocumentRef{ID: "a", Path: "projects/p/databases/(default)/documents/users/a"}
+	b := &firestore.DocumentRef{ID: "b", Path: "projects/p/databases/(default)/documents/users/b"}
+	tests := []struct {
+		name string
+		v    any
+		want []*firestore.DocumentRef
+	}{
+		{"reference", a, []*firestore.DocumentRef{a}},
+		{"array", []any{a, "x", b}, []*firestore.DocumentRef{a, b}},
+		{"nil reference", (*firestore.DocumentRef)(nil), nil},
+		{"nested in a map", map[string]any{"ref": a}, nil},
+		{"scalar", "users/a", nil},
+	}
+	for _, tt := range tests {
+		if got := topLevelRefs(tt.v); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("topLevelRefs(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRefResolver_Cached(t *testing.T) {
+	a := &firestore.DocumentRef{ID: "a", Path: "projects/p/databases/(default)/documents/users/a"}
+	gone := &firestore.DocumentRef{ID: "gone", Path: "projects/p/databases/(default)/documents/users/gone"}
+	// With every target cached, resolve doesn't need the client.
+	r := newRefResolver(nil, "name", nil)
+	r.cache[a.Path] = "Alice"
+	r.cache[gone.Path] = nil
+
+	data := map[string]any{
+		"owner":   a,
+		"team":    []any{a, gone, "guest"},
+		"deleted": gone,
+		"nested":  map[string]any{"ref": a},
+	}
+	if err := r.resolve(context.Background(), data); err != nil {
+		t.Fatalf("resolve() error = %v", err)
+	}
+	want := map[string]any{
+		"owner":   "Alice",
+		"team":    []any{"Alice", nil, "guest"},
+		"deleted": nil,
+		"nested":  map[string]any{"ref": a},
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("resolve() = %v, want %v", data, want)
+	}
+}
+
+func TestRefResolver_CopiesCachedValue(t *testing.T) {
+	a := &firestore.DocumentRef{ID: "a", Path: "projects/p/databases/(default)/documents/users/a"}
+	r := newRefResolver(nil, "", nil)
+	r.cache[a.Path] = map[string]any{"name": "Alice", "tags": []any{map[string]any{"k": "v"}}}
+
+	first := map[string]any{"owner": a}
+	second := map[string]any{"owner": a}
+	for _, data := range []map[string]any{first, second} {
+		if err := r.resolve(context.Background(), data); err != nil {
+			t.Fatalf("resolve() error = %v", err)
+		}
+	}
+	// --sanitize rewrites nested maps in place; the other document and the
+	// cache must not see it.
+	owner := first["owner"].(map[string]any)
+	owner["name"] = "Bob"
+	owner["tags"].([]any)[0].(map[string]any)["k"] = "w"
+	want := map[string]any{"name": "Alice", "tags": []any{map[string]any{"k": "v"}}}
+	if !reflect.DeepEqual(second["owner"], want) {
+		t.Errorf("second document = %v, want %v", second["owner"], want)
+	}
+	if !reflect.DeepEqual(r.cache[a.Path], want) {
+		t.Errorf("cache = %v, want %v", r.cache[a.Path], want)
+	}
+}
+
+func TestRefResolver_Nil(t *testing.T) {
+	a := &firestore.DocumentRef{ID: "a", Path: "projects/p/databases/(default)/documents/users/a"}
+	data := map[string]any{"owner": a}
+	var r *refResolver
+	if err := r.resolve(context.Background(), data); err != nil || data["owner"] != a {
+		t.Errorf("nil resolver changed data: %v, %v", data, err)
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+)
+
+// checkpoint is the content of a <collection>.cursor file written by --resume.
+type checkpoint struct {
+	// Last is the ID of the last document written before Offset.
+	Last string `json:"last"`
+	// Count is the number of documents written up to Last.
+	Count int `json:"count"`
+	// Offset is the output file size after the last checkpointed row; anything
+	// written after it is discarded on resume.
+	Offset int64 `json:"offset"`
+	// Complete is set once the whole collection has been written.
+	Complete bool `json:"complete,omitempty"`
+}
+
+// checkpointPath returns the cursor file path for a collection.
+func checkpointPath(displayPath string, cfg exportConfig) string {
+	return filepath.Join(cfg.output, filepath.FromSlash(outputName(displayPath, cfg))+".cursor")
+}
+
+// loadCheckpoint reads a cursor file. A missing file returns nil and no error.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint writes a cursor file atomically, so an interrupted run never
+// leaves a half-written checkpoint behind.
+func saveCheckpoint(path string, cp checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// validateResume rejects options that --resume can't honor.
+func validateResume(cfg exportConfig) error {
+	switch {
+	case cfg.group != "":
+		return fmt.Errorf("--resume can't be combined with --collection-group")
+	case cfg.maxDepth != 0:
+		return fmt.Errorf("--resume only supports top-level collections; use it with --depth 0")
+	case cfg.compression != "":
+		return fmt.Errorf("--resume can't append to compressed files; drop --compression")
+	case cfg.format == "parquet":
+		return fmt.Errorf("--resume can't append to Parquet files; use csv or jsonl")
+	case isGCSURL(cfg.output):
+		return fmt.Errorf("--resume needs a local --output directory")
+	case cfg.dryRun:
+		return fmt.Errorf("--dry-run can't be combined with --resume")
+	case cfg.noHeader:
+		return fmt.Errorf("--resume reads the CSV header to continue a file; drop --no-header")
+	case cfg.emitSchema:
+		return fmt.Errorf("--emit-schema can't be combined with --resume")
+	case len(cfg.orderBy) > 0:
+		return fmt.Errorf("--order-by can't be combined with --resume, which orders by document ID")
+	case cfg.checkpointEvery < 1:
+		return fmt.Errorf("invalid --checkpoint-every %d: must be at least 1", cfg.checkpointEvery)
+	}
+	for _, wf := range cfg.where {
+		if isInequalityOp(wf.op) {
+			return fmt.Errorf("--resume orders documents by ID, which Firestore doesn't allow with the %q filter on %q", wf.op, wf.field)
+		}
+	}
+	return nil
+}
+
+// resumeAndExport is the --resume variant of readAndExportCollection. Documents
+// are read in document ID order and streamed to the output file; every
+// cfg.checkpointEvery documents the file is flushed and a cursor file records
+// the last document ID and file size. If a cursor file exists, the export picks
+// up after that document and appends to the file instead of starting over.
+func resumeAndExport(ctx context.Context, colRef *firestore.CollectionRef, displayPath string, depth int, cfg exportConfig) (exportResult, []*firestore.DocumentRef) {
+	fail := func(err error) (exportResult, []*firestore.DocumentRef) {
+		printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
+		return exportResult{collection: displayPath, depth: depth, err: err}, nil
+	}
+
+	filePath := filepath.Join(cfg.output, filepath.FromSlash(outputName(displayPath, cfg))+outputExt(cfg.format))
+	cpPath := checkpointPath(displayPath, cfg)
+	cp, err := loadCheckpoint(cpPath)
+	if err != nil {
+		return fail(err)
+	}
+	if cp != nil && cp.Complete {
+		printInfoFor(displayPath, "Collection %q was already exported (%s docs), skipping.", displayPath, fmtInt(cp.Count))
+		if cfg.checksums != nil {
+			if err := cfg.checksums.addFile(filePath); err != nil {
+				return fail(err)
+			}
+		}
+		return exportResult{collection: displayPath, depth: depth, docCount: cp.Count, filePath: filePath}, nil
+	}
+
+	query := applyFieldSelection(applyWhereFilters(colRef.Query, cfg.where), cfg.fields).
+		OrderBy(firestore.DocumentID, firestore.Asc)
+	written := 0
+	if cp != nil {
+		// The checkpoint is past --id-start, so it replaces that cursor.
+		query = query.StartAfter(colRef.Doc(cp.Last))
+		written = cp.Count
+	} else if cfg.idStart != "" {
+		query = query.StartAt(cfg.idStart)
+	}
+	if cfg.idEnd != "" {
+		query = query.EndAt(cfg.idEnd)
+	}
+	limit := 0
+	if cfg.limit > 0 {
+		limit = cfg.limit - written
+		if limit <= 0 {
+			return finishResume(cpPath, checkpoint{Last: cp.Last, Count: written, Offset: cp.Offset}, displayPath, depth, filePath, nil, cfg)
+		}
+		query = query.Limit(limit)
+	}
+	if verbosity >= verboseQueries {
+		order := []orderClause{{field: firestore.DocumentID, dir: firestore.Asc}}
+		desc := describeQuery(cfg.where, order, cfg.fields, limit)
+		if cfg.idStart != "" || cfg.idEnd != "" {
+			desc += ", " + describeIDRange(cfg)
+		}
+		printDebugFor(displayPath, "Query for %q: %s", displayPath, desc)
+	}
+
+	var rw recordWriter
+	var f *os.File
+	var fields []string
+	if cp != nil {
+		rw, f, fields, err = openResumedWriter(filePath, cp.Offset, cfg)
+		if err != nil {
+			return fail(err)
+		}
+		printInfoFor(displayPath, "Resuming %q after %s docs", displayPath, fmtInt(written))
+	} else {
+		fieldSet := make(map[string]struct{})
+		if cfg.format != "jsonl" && !fixedHeader(cfg) {
+			sp := newSpinner(fmt.Sprintf("Scanning fields in %q... 0 documents", displayPath), !cfg.noSpinner)
+			sp.Start()
+			count, err := scanDocuments(ctx, []firestore.Query{query}, limit, cfg.pageSize, cfg.maxRetries, cfg.limiter, sp, fmt.Sprintf("Scanning fields in %q...", displayPath), func(snap *firestore.DocumentSnapshot) error {
+				if !keepDocumentID(displayPath, snap.Ref.ID, cfg, false) {
+					return nil
+				}
+				raw := snap.Data()
+				if err := cfg.resolver.resolve(ctx, raw); err != nil {
+					return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+				}
+				data, err := shapeRecord(castFields(raw, documentPath(snap.Ref), cfg), cfg)
+				if err != nil {
+					return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+				}
+				for k := range data {
+					fieldSet[k] = struct{}{}
+				}
+				return nil
+			})
+			sp.Stop()
+			if err != nil {
+				return fail(err)
+			}
+			if count == 0 {
+				return emptyCollectionResult(ctx, []*firestore.CollectionRef{colRef}, displayPath, depth, false, cfg)
+			}
+		}
+		if rw, f, err = createResumableWriter(filePath, fieldSet, cfg); err != nil {
+			return fail(err)
+		}
+		fields = headerFields(fieldSet, cfg)
+	}
+
+	var last string
+	sinceCheckpoint := 0
+	save := func() error {
+		if err := rw.flush(); err != nil {
+			return fmt.Errorf("flushing %s: %w", filePath, err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("checkpointing %s: %w", filePath, err)
+		}
+		cp = &checkpoint{Last: last, Count: written, Offset: info.Size()}
+		sinceCheckpoint = 0
+		return saveCheckpoint(cpPath, *cp)
+	}
+
+	// --validate sees the documents read by this run only.
+	var types *schemaBuilder
+	if cfg.validation != nil {
+		types = newSchemaBuilder()
+	}
+	sp := newSpinner(fmt.Sprintf("Writing %q... 0 documents", displayPath), !cfg.noSpinner)
+	sp.Start()
+	_, err = scanDocuments(ctx, []firestore.Query{query}, limit, cfg.pageSize, cfg.maxRetries, cfg.limiter, sp, fmt.Sprintf("Writing %q...", displayPath), func(snap *firestore.DocumentSnapshot) error {
+		if verbosity >= verboseDocuments {
+			debugDocument(displayPath, snap)
+		}
+		if !keepDocumentID(displayPath, snap.Ref.ID, cfg, true) {
+			return nil
+		}
+		raw := snap.Data()
+		if err := cfg.resolver.resolve(ctx, raw); err != nil {
+			return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+		}
+		data, err := prepareRecord(raw, documentPath(snap.Ref), cfg)
+		if err != nil {
+			return fmt.Errorf("document %s: %w", documentPath(snap.Ref), err)
+		}
+		if err := rw.write(newDocRecord(snap, data)); err != nil {
+			return err
+		}
+		if types != nil {
+			types.add(documentPath(snap.Ref), data)
+		}
+		last = snap.Ref.ID
+		written++
+		sinceCheckpoint++
+		if sinceCheckpoint >= cfg.checkpointEvery {
+			return save()
+		}
+		return nil
+	})
+	sp.Stop()
+	if last != "" && (err == nil || ctx.Err() != nil) {
+		// An interrupted export keeps the rows it wrote, so the next run
+		// continues after them.
+		if saveErr := save(); err == nil {
+			err = saveErr
+		}
+	}
+	if closeErr := rw.close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("closing %s: %w", filePath, closeErr)
+	}
+	if err != nil && ctx.Err() != nil && cp != nil {
+		return stoppedResult(displayPath, depth, cp.Count, len(fields), filePath, err), nil
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if written == 0 {
+		os.Remove(filePath)
+		return emptyCollectionResult(ctx, []*firestore.CollectionRef{colRef}, displayPath, depth, false, cfg)
+	}
+	if types != nil && types.docs > 0 {
+		cfg.validation.add(types.build(displayPath))
+	}
+	return finishResume(cpPath, *cp, displayPath, depth, filePath, fields, cfg)
+}
+
+// finishResume marks a collection's checkpoint complete and reports it. The
+// resumable writers don't hash what they write, since the file may have been
+// started by an earlier run, so with --manifest the finished file is hashed
+// here.
+func finishResume(cpPath string, cp checkpoint, displayPath string, depth int, filePath string, fields []string, cfg exportConfig) (exportResult, []*firestore.DocumentRef) {
+	cp.Complete = true
+	err := saveCheckpoint(cpPath, cp)
+	if err == nil && cfg.checksums != nil {
+		err = cfg.checksums.addFile(filePath)
+	}
+	if err != nil {
+		printErrFor(displayPath, "Failed to export %q: %v", displayPath, err)
+		return exportResult{collection: displayPath, depth: depth, err: err}, nil
+	}
+	printOKFor(displayPath, "Exported %q — %s docs, %d fields → %s", displayPath, fmtInt(cp.Count), len(fields), filePath)
+	return exportResult{
+		collection: displayPath,
+		depth:      depth,
+		docCount:   cp.Count,
+		fieldCount: len(fields),
+		filePath:   filePath,
+	}, nil
+}
+
+// createResumableWriter creates a fresh output file for a resumable export.
+func createResumableWriter(filePath string, fieldSet map[string]struct{}, cfg exportConfig) (recordWriter, *os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, nil, fmt.Errorf("creating directory for %s: %w", filePath, err)
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating file %s: %w", filePath, err)
+	}
+	if cfg.format == "jsonl" {
+		return newJSONLWriter(f, cfg), f, nil
+	}
+	w, err := newCSVWriter(f, fieldSet, cfg)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return w, f, nil
+}
+
+// openResumedWriter truncates an existing output file to the checkpointed
+// offset, dropping any rows written after the last checkpoint, and returns a
+// writer that appends to it. CSV columns are taken from the file's header.
+func openResumedWriter(filePath string, offset int64, cfg exportConfig) (recordWriter, *os.File, []string, error) {
+	if err := os.Truncate(filePath, offset); err != nil {
+		return nil, nil, nil, fmt.Errorf("truncating %s to last checkpoint: %w", filePath, err)
+	}
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("opening %s: %w", filePath, err)
+	}
+	if cfg.format == "jsonl" {
+		return newJSONLWriter(f, cfg), f, nil, nil
+	}
+
+	fields, err := readCSVHeaderFields(f, cfg)
+	if err != nil {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("reading header of %s: %w", filePath, err)
+	}
+	return newCSVRowWriter(f, fields, cfg), f, fields, nil
+}
+
+// readCSVHeaderFields reads the data columns from the header of an exported
+// CSV or TSV file, checking that it matches the current --single-file,
+// --path-columns, --with-types and --include-timestamps settings.
+func readCSVHeaderFields(r io.Reader, cfg exportConfig) ([]string, error) {
+	var header []string
+	var err error
+	if cfg.format == "tsv" {
+		header, err = readTSVRow(r)
+	} else {
+		cr := csv.NewReader(r)
+		if cfg.delimiter != 0 {
+			cr.Comma = cfg.delimiter
+		}
+		header, err = cr.Read()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], utf8BOM)
+	}
+	if len(header) == 0 || header[0] != "__path__" {
+		return nil, fmt.Errorf("first column is not __path__")
+	}
+	fields := header[1:]
+	hasCollection := len(fields) > 0 && fields[0] == "__collection__"
+	if hasCollection != cfg.singleFile {
+		return nil, fmt.Errorf("file was written with a different --single-file setting")
+	}
+	if hasCollection {
+		fields = fields[1:]
+	}
+	parents := parentColumns(cfg.parentLevels)
+	if len(fields) < len(parents) || !slices.Equal(fields[:len(parents)], parents) || (len(fields) > len(parents) && isParentColumn(fields[len(parents)])) {
+		return nil, fmt.Errorf("file was written with a different --path-columns setting")
+	}
+	fields = fields[len(parents):]
+	hasTimestamps := len(fields) >= 2 && fields[0] == "__create_time__" && fields[1] == "__update_time__"
+	if hasTimestamps != cfg.includeTimestamps {
+		return nil, fmt.Errorf("file was written with a different --include-timestamps setting")
+	}
+	if hasTimestamps {
+		fields = fields[2:]
+	}
+	hasTypes := len(fields) > 0 && fields[len(fields)-1] == "__fs_types__"
+	if hasTypes != cfg.withTypes {
+		return nil, fmt.Errorf("file was written with a different --with-types setting")
+	}
+	if hasTypes {
+		fields = fields[:len(fields)-1]
+	}
+	return fields, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckpoint_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.cursor")
+
+	cp, err := loadCheckpoint(path)
+	if err != nil || cp != nil {
+		t.Fatalf("loadCheckpoint(missing) = %v, %v; want nil, nil", cp, err)
+	}
+
+	want := checkpoint{Last: "u42", Count: 42, Offset: 1234}
+	if err := saveCheckpoint(path, want); err != nil {
+		t.Fatalf("saveCheckpoint() error = %v", err)
+	}
+	got, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint() error = %v", err)
+	}
+	if *got != want {
+		t.Errorf("loadCheckpoint() = %+v, want %+v", *got, want)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary checkpoint file left behind")
+	}
+}
+
+func TestOpenResumedWriter_CSV(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "users.csv")
+	header := "__path__,age,name\n"
+	partial := header + "users/a,30,Alice\n"
+	// A row that was written after the last checkpoint, cut off mid-line.
+	if err := os.WriteFile(filePath, []byte(partial+"users/b,2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rw, f, fields, err := openResumedWriter(filePath, int64(len(partial)), exportConfig{})
+	if err != nil {
+		t.Fatalf("openResumedWriter() error = %v", err)
+	}
+	if f == nil {
+		t.Fatal("openResumedWriter() returned nil file")
+	}
+	if !reflect.DeepEqual(fields, []string{"age", "name"}) {
+		t.Errorf("fields = %v, want [age name]", fields)
+	}
+	if err := rw.write(docRecord{path: "users/b", data: map[string]any{"age": int64(25), "name": "Bob"}}); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if err := rw.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := partial + "users/b,25,Bob\n"; string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestReadCSVHeaderFields(t *testing.T) {
+	fields, err := readCSVHeaderFields(strings.NewReader("__path__;a;__fs_types__\n"), exportConfig{delimiter: ';', withTypes: true})
+	if err != nil {
+		t.Fatalf("readCSVHeaderFields() error = %v", err)
+	}
+	if !reflect.DeepEqual(fields, []string{"a"}) {
+		t.Errorf("fields = %v, want [a]", fields)
+	}
+
+	if _, err := readCSVHeaderFields(strings.NewReader("__path__,a,__fs_types__\n"), exportConfig{}); err == nil {
+		t.Error("expected error for --with-types mismatch")
+	}
+	fields, err = readCSVHeaderFields(strings.NewReader("__path__,__create_time__,__update_time__,a\n"), exportConfig{includeTimestamps: true})
+	if err != nil || !reflect.DeepEqual(fields, []string{"a"}) {
+		t.Errorf("with timestamps: fields = %v, err = %v; want [a]", fields, err)
+	}
+	if _, err := readCSVHeaderFields(strings.NewReader("__path__,a\n"), exportConfig{includeTimestamps: true}); err == nil {
+		t.Error("expected error for --include-timestamps mismatch")
+	}
+	if fields, err := readCSVHeaderFields(strings.NewReader(utf8BOM+"__path__,a\n"), exportConfig{bom: true}); err != nil || !reflect.DeepEqual(fields, []string{"a"}) {
+		t.Errorf("with BOM: fields = %v, err = %v; want [a]", fields, err)
+	}
+	if _, err := readCSVHeaderFields(strings.NewReader("id,a\n"), exportConfig{}); err == nil {
+		t.Error("expected error for missing __path__ column")
+	}
+	if fields, err := readCSVHeaderFields(strings.NewReader("__path__,__collection__,a\n"), exportConfig{singleFile: true}); err != nil || !reflect.DeepEqual(fields, []string{"a"}) {
+		t.Errorf("with __collection__: fields = %v, err = %v; want [a]", fields, err)
+	}
+	if _, err := readCSVHeaderFields(strings.NewReader("__path__,__collection__,a\n"), exportConfig{}); err == nil {
+		t.Error("expected error for --single-file mismatch")
+	}
+	if fields, err := readCSVHeaderFields(strings.NewReader("__path__,__parent_collection__,__parent_id__,a\n"), exportConfig{parentLevels: 1}); err != nil || !reflect.DeepEqual(fields, []string{"a"}) {
+		t.Errorf("with parent columns: fields = %v, err = %v; want [a]", fields, err)
+	}
+	if _, err := readCSVHeaderFields(strings.NewReader("__path__,__parent_collection__,__parent_id__,a\n"), exportConfig{}); err == nil {
+		t.Error("expected error for --path-columns mismatch")
+	}
+}
+
+func TestValidateResume(t *testing.T) {
+	base := exportConfig{output: ".", checkpointEvery: 100}
+	tests := []struct {
+		name    string
+		modify  func(*exportConfig)
+		wantErr string
+	}{
+		{"valid", func(c *exportConfig) {}, ""},
+		{"equality filter", func(c *exportConfig) { c.where = []whereFilter{{field: "a", op: "==", value: int64(1)}} }, ""},
+		{"recursive", func(c *exportConfig) { c.maxDepth = -1 }, "--depth 0"},
+		{"compressed", func(c *exportConfig) { c.compression = "gzip" }, "--compression"},
+		{"no header", func(c *exportConfig) { c.noHeader = true }, "--no-header"},
+		{"collection group", func(c *exportConfig) { c.group = "orders" }, "--collection-group"},
+		{"gcs", func(c *exportConfig) { c.output = "gs://bucket/prefix" }, "local --output"},
+		{"emit schema", func(c *exportConfig) { c.emitSchema = true }, "--emit-schema"},
+		{"dry run", func(c *exportConfig) { c.dryRun = true }, "--dry-run"},
+		{"checkpoint size", func(c *exportConfig) { c.checkpointEvery = 0 }, "--checkpoint-every"},
+		{"inequality filter", func(c *exportConfig) { c.where = []whereFilter{{field: "age", op: ">", value: int64(1)}} }, `"age"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.modify(&cfg)
+			err := validateResume(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateResume() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateResume() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFinishResume_Manifest(t *testing.T) {
+	// A resumed file is partly written by an earlier run, so it is hashed from
+	// disk once it's complete.
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "users.csv")
+	content := "__path__,name\nusers/a,Alice\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := exportConfig{output: dir, manifest: true, checksums: newChecksumSet(false)}
+	result, _ := finishResume(filepath.Join(dir, "users.cursor"), checkpoint{Last: "a", Count: 1}, "users", 0, filePath, []string{"name"}, cfg)
+	if result.err != nil {
+		t.Fatalf("finishResume() error = %v", result.err)
+	}
+	want := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	if got := cfg.checksums.get(filePath); got != want {
+		t.Errorf("sha256 = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// retryBaseDelay is the wait before the first retry; it doubles per attempt.
+	retryBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay caps the wait between retries.
+	retryMaxDelay = 30 * time.Second
+)
+
+// isRetryable reports whether err is a transient Firestore error worth retrying.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// The caller's context is done; retrying can't succeed.
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryDelay returns the exponential backoff delay before the given retry
+// attempt, starting at 1.
+func retryDelay(attempt int) time.Duration {
+	d := retryBaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= retryMaxDelay {
+			return retryMaxDelay
+		}
+	}
+	return d
+}
+
+// newReadLimiter returns the limiter shared by every query of an export for
+// --rate-limit, or nil for no limit. A tenth of a second's worth of reads may
+// go at once, so fast rates don't pause before every document.
+func newReadLimiter(perSecond int) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), max(1, perSecond/10))
+}
+
+// waitForRead blocks until limiter allows another document read, or ctx is
+// done. A nil limiter never waits. Unlike limiter.Wait it keeps waiting up to
+// a --timeout deadline, so the export stops the same way as without a limit.
+func waitForRead(ctx context.Context, limiter *rate.Limiter) error {
+	if limiter == nil {
+		return nil
+	}
+	r := limiter.Reserve()
+	if err := sleepContext(ctx, r.Delay()); err != nil {
+		r.Cancel()
+		return err
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done, returning ctx.Err() in the
+// latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unavailable", status.Error(codes.Unavailable, "down"), true},
+		{"deadline exceeded", status.Error(codes.DeadlineExceeded, "slow"), true},
+		{"resource exhausted", status.Error(codes.ResourceExhausted, "quota"), true},
+		{"aborted", status.Error(codes.Aborted, "contention"), true},
+		{"wrapped unavailable", fmt.Errorf("query: %w", status.Error(codes.Unavailable, "down")), true},
+		{"permission denied", status.Error(codes.PermissionDenied, "nope"), false},
+		{"invalid argument", status.Error(codes.InvalidArgument, "bad"), false},
+		{"context canceled", context.Canceled, false},
+		{"context deadline", context.DeadlineExceeded, false},
+		{"plain error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 500 * time.Millisecond},
+		{2, time.Second},
+		{3, 2 * time.Second},
+		{7, 30 * time.Second},
+		{20, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestSleepContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepContext() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestNewReadLimiter(t *testing.T) {
+	if l := newReadLimiter(0); l != nil {
+		t.Errorf("newReadLimiter(0) = %v, want nil", l)
+	}
+	tests := []struct {
+		perSecond, burst int
+	}{
+		{1, 1},
+		{5, 1},
+		{500, 50},
+	}
+	for _, tt := range tests {
+		l := newReadLimiter(tt.perSecond)
+		if float64(l.Limit()) != float64(tt.perSecond) || l.Burst() != tt.burst {
+			t.Errorf("newReadLimiter(%d): limit %v, burst %d; want %d, %d", tt.perSecond, l.Limit(), l.Burst(), tt.perSecond, tt.burst)
+		}
+	}
+}
+
+func TestWaitForRead(t *testing.T) {
+	if err := waitForRead(context.Background(), nil); err != nil {
+		t.Errorf("waitForRead(nil) error = %v", err)
+	}
+
+	// One read per hour: the first goes at once, the second has to wait.
+	limiter := newReadLimiter(1)
+	limiter.SetLimit(1.0 / 3600)
+	if err := waitForRead(context.Background(), limiter); err != nil {
+		t.Fatalf("first waitForRead() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := waitForRead(ctx, limiter); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second waitForRead() error = %v, want context.DeadlineExceeded", err)
+	}
+}
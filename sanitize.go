@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/spf13/cobra"
@@ -81,6 +82,7 @@ func parseSanitizeConfig(raw string) (sanitizeConfig, error) {
 type sanitizer struct {
 	fields map[string]string // field name → faker type
 	faker  *gofakeit.Faker
+	mu     sync.Mutex // guards faker when collections are exported concurrently
 }
 
 // newSanitizer creates a sanitizer. seed=0 uses crypto/rand (non-deterministic);
@@ -151,6 +153,15 @@ func (s *sanitizer) sanitizeRecord(data map[string]any) {
 	}
 }
 
+// sanitizeLocked is sanitizeRecord under the sanitizer's lock, so one sanitizer
+// can be shared by concurrent exports. Seeded output is then only deterministic
+// when collections are exported sequentially.
+func (s *sanitizer) sanitizeLocked(data map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sanitizeRecord(data)
+}
+
 // runSanitizeCmd is the cobra RunE handler for the sanitize subcommand.
 func runSanitizeCmd(cmd *cobra.Command, args []string) error {
 	f := cmd.Flags()
@@ -169,7 +180,7 @@ func runSanitizeCmd(cmd *cobra.Command, args []string) error {
 
 // runSanitize sanitizes CSV files from inputPath, writing results to outputDir.
 func runSanitize(cfg sanitizeConfig, inputPath, outputDir string, seed int64) error {
-	fmt.Fprintln(os.Stderr)
+	printText("\n")
 
 	san := newSanitizer(cfg, seed)
 
@@ -209,8 +220,7 @@ func runSanitize(cfg sanitizeConfig, inputPath, outputDir string, seed int64) er
 		printOK("Sanitized %q — %d rows", csvFile, rows)
 	}
 
-	fmt.Fprintf(os.Stderr, "\n%s Sanitized %d file(s), %d row(s) total.\n",
-		green("✓"), len(csvFiles), totalRows)
+	printDone(true, "Sanitized %d file(s), %d row(s) total.", len(csvFiles), totalRows)
 	return nil
 }
 
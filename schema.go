@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// collectionSchema is the content of a <collection>.schema.json file written
+// with --emit-schema.
+type collectionSchema struct {
+	Collection string                  `json:"collection"`
+	Documents  int                     `json:"documents"`
+	Fields     map[string]*fieldSchema `json:"fields"`
+}
+
+// fieldSchema describes the values seen for one field. Types holds the
+// typeLabel names observed, sorted; more than one type is a conflict.
+type fieldSchema struct {
+	Types    []string `json:"types"`
+	Count    int      `json:"count"`
+	Nullable bool     `json:"nullable,omitempty"`
+	Conflict bool     `json:"conflict,omitempty"`
+
+	// samples maps each type to the path of the first document holding it,
+	// for the --validate report.
+	samples map[string]string
+}
+
+// schemaBuilder infers a collectionSchema from the records of a collection.
+type schemaBuilder struct {
+	docs   int
+	fields map[string]*fieldStats
+}
+
+type fieldStats struct {
+	types map[string]string // type → first document path
+	count int
+	nulls int
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{fields: make(map[string]*fieldStats)}
+}
+
+// add records the field types of one exported document at docPath.
+func (b *schemaBuilder) add(docPath string, data map[string]any) {
+	b.docs++
+	for k, v := range data {
+		fs, ok := b.fields[k]
+		if !ok {
+			fs = &fieldStats{types: make(map[string]string)}
+			b.fields[k] = fs
+		}
+		fs.count++
+		if v == nil {
+			fs.nulls++
+			continue
+		}
+		if t := typeLabel(v); fs.types[t] == "" {
+			fs.types[t] = docPath
+		}
+	}
+}
+
+func (b *schemaBuilder) build(displayPath string) collectionSchema {
+	schema := collectionSchema{
+		Collection: displayPath,
+		Documents:  b.docs,
+		Fields:     make(map[string]*fieldSchema, len(b.fields)),
+	}
+	for k, fs := range b.fields {
+		types := make([]string, 0, len(fs.types))
+		for t := range fs.types {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		schema.Fields[k] = &fieldSchema{
+			Types:    types,
+			Count:    fs.count,
+			Nullable: fs.nulls > 0,
+			Conflict: len(types) > 1,
+			samples:  fs.types,
+		}
+	}
+	return schema
+}
+
+// inferSchema builds the schema of a collection from its records.
+func inferSchema(docs []docRecord, displayPath string) collectionSchema {
+	sb := newSchemaBuilder()
+	for _, doc := range docs {
+		sb.add(doc.path, doc.data)
+	}
+	return sb.build(displayPath)
+}
+
+// writeSchemaFile writes the schema next to the collection's output file and
+// returns its path. It is never compressed, even with --compression.
+func writeSchemaFile(schema collectionSchema, displayPath string, cfg exportConfig) (string, error) {
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding schema: %w", err)
+	}
+	f, filePath, err := openOutputFile(displayPath, ".schema.json", cfg)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return "", fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("closing %s: %w", filePath, err)
+	}
+	return filePath, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaBuilder(t *testing.T) {
+	sb := newSchemaBuilder()
+	sb.add("users/a", map[string]any{"name": "Alice", "age": int64(30), "score": int64(7)})
+	sb.add("users/b", map[string]any{"name": "Bob", "age": nil, "score": 7.5})
+
+	got := sb.build("users")
+	want := collectionSchema{
+		Collection: "users",
+		Documents:  2,
+		Fields: map[string]*fieldSchema{
+			"name":  {Types: []string{"string"}, Count: 2, samples: map[string]string{"string": "users/a"}},
+			"age":   {Types: []string{"int"}, Count: 2, Nullable: true, samples: map[string]string{"int": "users/a"}},
+			"score": {Types: []string{"float", "int"}, Count: 2, Conflict: true, samples: map[string]string{"int": "users/a", "float": "users/b"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("build() = %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestWriteSchemaFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	sb := newSchemaBuilder()
+	sb.add("", map[string]any{"total": 9.5})
+
+	filePath, err := writeSchemaFile(sb.build("users/orders"), "users/orders", exportConfig{output: tmpDir, compression: "gzip"})
+	if err != nil {
+		t.Fatalf("writeSchemaFile() error = %v", err)
+	}
+	if filePath != filepath.Join(tmpDir, "users", "orders.schema.json") {
+		t.Errorf("filePath = %q, want users/orders.schema.json under output dir", filePath)
+	}
+
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	var got collectionSchema
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if got.Collection != "users/orders" || got.Documents != 1 || !reflect.DeepEqual(got.Fields["total"].Types, []string{"float"}) {
+		t.Errorf("schema = %s", b)
+	}
+}
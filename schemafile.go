@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// readSchemaFile reads a --schema-file: a JSON array of column names, in the
+// order the header lists them, e.g. ["name", "email", "address.city"]. The
+// names are output columns, so they are taken after --flatten and --rename.
+func readSchemaFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading --schema-file: %w", err)
+	}
+	var columns []string
+	if err := json.Unmarshal(b, &columns); err != nil {
+		return nil, fmt.Errorf("--schema-file %s must be a JSON array of column names: %w", path, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("--schema-file %s lists no columns", path)
+	}
+	seen := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		switch {
+		case col == "":
+			return nil, fmt.Errorf("--schema-file %s lists an empty column name", path)
+		case seen[col]:
+			return nil, fmt.Errorf("--schema-file %s lists column %q twice", path, col)
+		}
+		seen[col] = true
+	}
+	return columns, nil
+}
+
+// schemaHeader is headerFields with --schema-file: the schema's columns in
+// its order, whether or not the data has them. With --schema-strict other
+// fields are dropped; without it they follow, sorted.
+func schemaHeader(fieldSet map[string]struct{}, cfg exportConfig) []string {
+	if cfg.schemaStrict {
+		return cfg.columnOrder
+	}
+	fields := slices.Clone(cfg.columnOrder)
+	var extra []string
+	for k := range fieldSet {
+		if !slices.Contains(cfg.columnOrder, k) {
+			extra = append(extra, k)
+		}
+	}
+	slices.Sort(extra)
+	return append(fields, extra...)
+}
+
+// fixedHeader reports whether the header is known before any document is
+// read, so a streaming export can skip its field-discovery pass.
+func fixedHeader(cfg exportConfig) bool {
+	if len(cfg.columnOrder) > 0 {
+		return cfg.schemaStrict
+	}
+	return len(cfg.fields) > 0
+}
+
+// validateSchemaFile rejects options that --schema-file can't honor.
+func validateSchemaFile(cfg exportConfig) error {
+	if cfg.format != "csv" && cfg.format != "tsv" {
+		return fmt.Errorf("--schema-file orders CSV and TSV columns; it can't be combined with --format %s", cfg.format)
+	}
+	reserved := reservedColumns(cfg)
+	for _, col := range cfg.columnOrder {
+		if slices.Contains(reserved, col) || (cfg.pathColumns && isParentColumn(col)) {
+			return fmt.Errorf("--schema-file can't list the %s column, which is always written first", col)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSchemaFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	got, err := readSchemaFile(write("ok.json", `["name", "email", "address.city"]`))
+	if err != nil {
+		t.Fatalf("readSchemaFile() error = %v", err)
+	}
+	if want := []string{"name", "email", "address.city"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("readSchemaFile() = %v, want %v", got, want)
+	}
+
+	invalid := map[string]string{
+		"object.json":    `{"fields": {}}`,
+		"empty.json":     `[]`,
+		"blank.json":     `["name", ""]`,
+		"duplicate.json": `["name", "name"]`,
+	}
+	for name, content := range invalid {
+		if _, err := readSchemaFile(write(name, content)); err == nil {
+			t.Errorf("readSchemaFile(%s) error = nil, want error", name)
+		}
+	}
+	if _, err := readSchemaFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("readSchemaFile(missing file) error = nil, want error")
+	}
+}
+
+func TestHeaderFields_SchemaFile(t *testing.T) {
+	fieldSet := map[string]struct{}{"name": {}, "zip": {}, "age": {}}
+	cfg := exportConfig{columnOrder: []string{"name", "email"}, schemaStrict: true}
+	if got, want := headerFields(fieldSet, cfg), []string{"name", "email"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("headerFields(strict) = %v, want %v", got, want)
+	}
+	cfg.schemaStrict = false
+	if got, want := headerFields(fieldSet, cfg), []string{"name", "email", "age", "zip"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("headerFields(not strict) = %v, want %v", got, want)
+	}
+}
+
+func TestWriteCollectionCSV_SchemaFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "users/a", data: map[string]any{"name": "Alice", "age": 30}},
+		{path: "users/b", data: map[string]any{"email": "bob@example.com", "name": "Bob"}},
+	}
+	fieldSet := map[string]struct{}{"name": {}, "age": {}, "email": {}}
+	cfg := exportConfig{output: tmpDir, format: "csv", columnOrder: []string{"name", "phone", "email"}, schemaStrict: true}
+
+	filePath, err := writeCollection(docs, fieldSet, "users", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "__path__,name,phone,email\nusers/a,Alice,,\nusers/b,Bob,,bob@example.com\n"
+	if string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestFixedHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  exportConfig
+		want bool
+	}{
+		{"field union", exportConfig{}, false},
+		{"--fields", exportConfig{fields: []string{"name"}}, true},
+		{"strict schema", exportConfig{columnOrder: []string{"name"}, schemaStrict: true}, true},
+		{"schema with extra fields", exportConfig{columnOrder: []string{"name"}, fields: []string{"name"}}, false},
+	}
+	for _, tt := range tests {
+		if got := fixedHeader(tt.cfg); got != tt.want {
+			t.Errorf("fixedHeader(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSchemaFile(t *testing.T) {
+	base := exportConfig{format: "csv", columnOrder: []string{"name"}}
+	if err := validateSchemaFile(base); err != nil {
+		t.Errorf("validateSchemaFile() error = %v", err)
+	}
+	invalid := map[string]func(*exportConfig){
+		"--format jsonl":   func(c *exportConfig) { c.format = "jsonl" },
+		"--format parquet": func(c *exportConfig) { c.format = "parquet" },
+		"__path__":         func(c *exportConfig) { c.columnOrder = []string{"__path__", "name"} },
+		"__create_time__": func(c *exportConfig) {
+			c.includeTimestamps = true
+			c.columnOrder = []string{"__create_time__"}
+		},
+		"__parent_id__": func(c *exportConfig) {
+			c.pathColumns = true
+			c.columnOrder = []string{"__parent_id__"}
+		},
+	}
+	for wantErr, modify := range invalid {
+		cfg := base
+		modify(&cfg)
+		if err := validateSchemaFile(cfg); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("validateSchemaFile() error = %v, want containing %q", err, wantErr)
+		}
+	}
+}
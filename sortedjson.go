@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// marshalSorted encodes v, a value returned by convertForJSON, as compact JSON
+// with the keys of every map written in byte order. encoding/json happens to
+// sort map keys as well; writing them here makes the order part of the output
+// format, so identical data gives byte-identical cells that can be hashed and
+// diffed across runs and Go versions. Scalars are encoded by encoding/json.
+func marshalSorted(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeSorted(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeSorted(buf *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case map[string]any:
+		return writeSortedObject(buf, val)
+	case map[string]float64:
+		return writeSortedObject(buf, val)
+	case map[string]string:
+		return writeSortedObject(buf, val)
+	case []any:
+		buf.WriteByte('[')
+		for i, elem := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeSorted(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+func writeSortedObject[V any](buf *bytes.Buffer, m map[string]V) error {
+	buf.WriteByte('{')
+	for i, k := range slices.Sorted(maps.Keys(m)) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k) // strings always encode
+		buf.Write(key)
+		buf.WriteByte(':')
+		if err := writeSorted(buf, m[k]); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestMarshalSorted(t *testing.T) {
+	v := map[string]any{
+		"zeta":  []any{map[string]any{"b": int64(2), "a": int64(1)}, "x"},
+		"alpha": map[string]any{"y": nil, "x": map[string]any{"d": true, "c": 1.5}},
+		"<tag>": "a & b",
+		"geo":   map[string]float64{"lng": 2, "lat": 1},
+		"Upper": "sorts before lower case",
+	}
+	got, err := marshalSorted(v)
+	if err != nil {
+		t.Fatalf("marshalSorted() error = %v", err)
+	}
+	want := `{"\u003ctag\u003e":"a \u0026 b","Upper":"sorts before lower case","alpha":{"x":{"c":1.5,"d":true},"y":null},"geo":{"lat":1,"lng":2},"zeta":[{"a":1,"b":2},"x"]}`
+	if string(got) != want {
+		t.Errorf("marshalSorted() = %s, want %s", got, want)
+	}
+	// The output is the same JSON encoding/json writes today.
+	if std, _ := json.Marshal(v); string(std) != want {
+		t.Errorf("json.Marshal() = %s, want %s", std, want)
+	}
+}
+
+func TestMarshalSorted_Repeatable(t *testing.T) {
+	v := map[string]any{}
+	for _, k := range []string{"m", "c", "x", "a", "q", "f", "b", "z", "k", "e"} {
+		v[k] = map[string]any{k + "2": k, k + "1": []any{k}}
+	}
+	first, err := marshalSorted(v)
+	if err != nil {
+		t.Fatalf("marshalSorted() error = %v", err)
+	}
+	for range 20 {
+		if got, _ := marshalSorted(v); string(got) != string(first) {
+			t.Fatalf("marshalSorted() = %s, then %s", first, got)
+		}
+	}
+}
+
+func TestMarshalSorted_Unsupported(t *testing.T) {
+	if _, err := marshalSorted(map[string]any{"a": []any{math.NaN()}}); err == nil {
+		t.Error("marshalSorted(NaN) error = nil, want error")
+	}
+}
+
+func TestFormatValue_NestedKeyOrder(t *testing.T) {
+	v := map[string]any{"b": map[string]any{"z": int64(1), "y": []any{map[string]any{"q": "1", "p": "2"}}}, "a": "x"}
+	want := `{"a":"x","b":{"y":[{"p":"2","q":"1"}],"z":1}}`
+	if got := formatValue(v); got != want {
+		t.Errorf("formatValue() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// splitWriter writes a collection to numbered parts (users.part001.csv,
+// users.part002.csv, ...) for --max-file-size. Each row is formatted before
+// it is committed to a part, so a row that would take the part over the limit
+// starts the next one instead; only a row larger than the limit on its own
+// gets a part that exceeds it. Every CSV part starts with the header. Sizes
+// are counted before compression.
+type splitWriter struct {
+	fieldSet    map[string]struct{}
+	displayPath string
+	cfg         exportConfig
+	maxSize     int64
+
+	rw    recordWriter // the current part
+	sink  *partSink
+	rows  int // rows committed to the current part
+	files []string
+}
+
+func newSplitWriter(fieldSet map[string]struct{}, displayPath string, cfg exportConfig) (*splitWriter, error) {
+	sw := &splitWriter{fieldSet: fieldSet, displayPath: displayPath, cfg: cfg, maxSize: cfg.maxFileSize}
+	if err := sw.openPart(); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// partExt returns the extension of part n, e.g. ".part001.csv".
+func partExt(n int, format string) string {
+	return fmt.Sprintf(".part%03d%s", n, outputExt(format))
+}
+
+// openPart creates the next part and writes its header to the sink, where it
+// waits for the first row.
+func (sw *splitWriter) openPart() error {
+	f, filePath, err := createOutputFile(sw.displayPath, partExt(len(sw.files)+1, sw.cfg.format), sw.cfg)
+	if err != nil {
+		return err
+	}
+	sink := newPartSink(f)
+	var rw recordWriter
+	if sw.cfg.format == "jsonl" {
+		rw = newJSONLWriter(sink, sw.cfg)
+	} else if rw, err = newCSVWriter(sink, sw.fieldSet, sw.cfg); err != nil {
+		f.Close()
+		return err
+	}
+	if err := rw.flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	sw.rw, sw.sink, sw.rows = rw, sink, 0
+	sw.files = append(sw.files, filePath)
+	return nil
+}
+
+func (sw *splitWriter) write(doc docRecord) error {
+	if err := sw.rw.write(doc); err != nil {
+		return err
+	}
+	if err := sw.rw.flush(); err != nil {
+		return err
+	}
+	if sw.rows > 0 && sw.sink.size+int64(sw.sink.pending.Len()) > sw.maxSize {
+		// The row goes first in a new part. Its bytes don't depend on the
+		// file, so they are moved over as they are.
+		row := bytes.Clone(sw.sink.pending.Bytes())
+		sw.sink.pending.Reset()
+		if err := sw.rw.close(); err != nil {
+			return fmt.Errorf("closing %s: %w", sw.files[len(sw.files)-1], err)
+		}
+		if err := sw.openPart(); err != nil {
+			return err
+		}
+		sw.sink.pending.Write(row)
+	}
+	sw.rows++
+	return sw.sink.commit()
+}
+
+func (sw *splitWriter) flush() error {
+	return sw.rw.flush()
+}
+
+func (sw *splitWriter) close() error {
+	return sw.rw.close()
+}
+
+// writtenFiles returns every file rw wrote: the parts of a splitWriter, or
+// nil for writers of a single file.
+func writtenFiles(rw recordWriter) []string {
+	if sw, ok := rw.(*splitWriter); ok {
+		return sw.files
+	}
+	return nil
+}
+
+// partSink sits between a part's record writer and its file. Bytes written to
+// it are held in pending until commit, so splitWriter can measure a row
+// before deciding which part it belongs to.
+type partSink struct {
+	f       io.WriteCloser
+	bw      *bufio.Writer
+	pending bytes.Buffer
+	size    int64 // bytes committed to the part
+}
+
+func newPartSink(f io.WriteCloser) *partSink {
+	return &partSink{f: f, bw: bufio.NewWriter(f)}
+}
+
+func (s *partSink) Write(p []byte) (int, error) {
+	return s.pending.Write(p)
+}
+
+// commit passes the pending bytes on to the file.
+func (s *partSink) commit() error {
+	n, err := s.pending.WriteTo(s.bw)
+	s.size += n
+	return err
+}
+
+// Close commits what is pending and closes the file.
+func (s *partSink) Close() error {
+	err := s.commit()
+	if err == nil {
+		err = s.bw.Flush()
+	}
+	if err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+// byteUnits are the suffixes accepted by parseByteSize.
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	// Longest first, so "KiB" isn't taken for "B".
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseByteSize parses a size such as "500MB", "1GiB" or "4096". KB, MB and
+// GB are powers of 1000; KiB, MiB and GiB powers of 1024. Units ignore case.
+func parseByteSize(raw string) (int64, error) {
+	s := strings.TrimSpace(raw)
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if len(s) > len(u.suffix) && strings.EqualFold(s[len(s)-len(u.suffix):], u.suffix) {
+			s, unit = strings.TrimSpace(s[:len(s)-len(u.suffix)]), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || !(n*float64(unit) >= 1) || n*float64(unit) > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: must be a positive number of bytes, optionally with a unit such as MB or GiB", raw)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// validateMaxFileSize rejects options that --max-file-size can't honor.
+func validateMaxFileSize(cfg exportConfig) error {
+	switch {
+	case cfg.format == "parquet":
+		return fmt.Errorf("--max-file-size doesn't support --format parquet")
+	case cfg.output == stdoutOutput:
+		return fmt.Errorf("--max-file-size writes numbered files; it can't be combined with --output -")
+	case cfg.append:
+		return fmt.Errorf("--max-file-size can't be combined with --append")
+	case cfg.resume:
+		return fmt.Errorf("--max-file-size can't be combined with --resume")
+	}
+	return nil
+}
+
+// describeOutput names the output of a collection in progress lines and the
+// summary table: its file, followed by the number of parts with
+// --max-file-size, or its directory and the number of files with
+// --split-documents.
+func describeOutput(filePath string, parts []string, files int) string {
+	switch {
+	case len(parts) > 0:
+		return fmt.Sprintf("%s (%d part(s))", filePath, len(parts))
+	case files > 0:
+		return fmt.Sprintf("%s (%s file(s))", filePath, fmtInt(files))
+	}
+	return filePath
+}
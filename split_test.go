@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteCollectionFiles_MaxFileSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "users/a", data: map[string]any{"name": "Ann"}},
+		{path: "users/b", data: map[string]any{"name": "Bob"}},
+		{path: "users/c", data: map[string]any{"name": "Cid"}},
+		{path: "users/d", data: map[string]any{"name": "Dee"}},
+		{path: "users/e", data: map[string]any{"name": "Eve"}},
+	}
+	// The header takes 14 bytes and each row 12, so two rows fit in a part.
+	cfg := exportConfig{output: tmpDir, maxFileSize: 40}
+
+	filePath, parts, err := writeCollectionFiles(docs, map[string]struct{}{"name": {}}, "users", cfg)
+	if err != nil {
+		t.Fatalf("writeCollectionFiles() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(tmpDir, "users.part001.csv"),
+		filepath.Join(tmpDir, "users.part002.csv"),
+		filepath.Join(tmpDir, "users.part003.csv"),
+	}
+	if strings.Join(parts, ",") != strings.Join(want, ",") {
+		t.Fatalf("parts = %v, want %v", parts, want)
+	}
+	if filePath != want[0] {
+		t.Errorf("filePath = %q, want the first part", filePath)
+	}
+
+	wantRows := []string{"users/a,Ann\nusers/b,Bob\n", "users/c,Cid\nusers/d,Dee\n", "users/e,Eve\n"}
+	for i, part := range parts {
+		b, err := os.ReadFile(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 40 {
+			t.Errorf("part %d is %d bytes, over the limit", i+1, len(b))
+		}
+		if want := "__path__,name\n" + wantRows[i]; string(b) != want {
+			t.Errorf("part %d = %q, want %q", i+1, b, want)
+		}
+	}
+}
+
+func TestWriteCollectionFiles_RowOverLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "logs/a", data: map[string]any{"msg": strings.Repeat("x", 100)}},
+		{path: "logs/b", data: map[string]any{"msg": "short"}},
+	}
+	cfg := exportConfig{output: tmpDir, format: "jsonl", maxFileSize: 50}
+
+	_, parts, err := writeCollectionFiles(docs, nil, "logs", cfg)
+	if err != nil {
+		t.Fatalf("writeCollectionFiles() error = %v", err)
+	}
+	// An oversized row still gets written, in a part of its own.
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], "logs.part002.jsonl") {
+		t.Fatalf("parts = %v, want two JSONL parts", parts)
+	}
+	b, err := os.ReadFile(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"logs/b"`) || strings.Count(string(b), "\n") != 1 {
+		t.Errorf("second part = %q, want only logs/b", b)
+	}
+}
+
+func TestWriteCollectionFiles_NoLimit(t *testing.T) {
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Ann"}}}
+	filePath, parts, err := writeCollectionFiles(docs, map[string]struct{}{"name": {}}, "users", exportConfig{output: t.TempDir()})
+	if err != nil {
+		t.Fatalf("writeCollectionFiles() error = %v", err)
+	}
+	if parts != nil || filepath.Base(filePath) != "users.csv" {
+		t.Errorf("writeCollectionFiles() = %q, %v; want users.csv and no parts", filePath, parts)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	valid := map[string]int64{
+		"4096":   4096,
+		"500MB":  500_000_000,
+		"1gb":    1_000_000_000,
+		"1GiB":   1 << 30,
+		"1.5 KB": 1500,
+		"64KiB":  64 << 10,
+		"10B":    10,
+	}
+	for raw, want := range valid {
+		if got, err := parseByteSize(raw); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"", "0", "-1MB", "MB", "ten", "NaN", "0.1B"} {
+		if _, err := parseByteSize(raw); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestValidateMaxFileSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"csv", exportConfig{format: "csv"}, ""},
+		{"jsonl", exportConfig{format: "jsonl", compression: "gzip"}, ""},
+		{"parquet", exportConfig{format: "parquet"}, "parquet"},
+		{"stdout", exportConfig{format: "csv", output: stdoutOutput}, "--output -"},
+		{"append", exportConfig{format: "csv", append: true}, "--append"},
+		{"resume", exportConfig{format: "csv", resume: true}, "--resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMaxFileSize(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateMaxFileSize() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateMaxFileSize() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// stdoutOutput is the --output value that writes the export to stdout instead
+// of files, for piping into other tools. Logs already go to stderr.
+const stdoutOutput = "-"
+
+// stdoutPath stands in for the file path of output written to stdout.
+const stdoutPath = "(stdout)"
+
+// stdoutFile is the destination of an export to stdout. Closing it leaves
+// stdout open.
+type stdoutFile struct {
+	io.Writer
+}
+
+func (stdoutFile) Close() error { return nil }
+
+// validateStdout rejects options that --output - can't honor. Everything has
+// to fit in one stream, so only one collection is exported, unless
+// --single-file combines several; runExport checks how many were resolved.
+func validateStdout(cfg exportConfig) error {
+	switch {
+	case len(cfg.databases) > 1:
+		return fmt.Errorf("--output - writes a single database; name one with --database")
+	case cfg.maxDepth != 0 && !cfg.singleFile && cfg.group == "":
+		return fmt.Errorf("--output - writes a single collection; use --depth 0, or --single-file to include sub-collections")
+	case cfg.summary == "csv" || cfg.summary == "tsv":
+		return fmt.Errorf("--summary-format %s writes to stdout too; use table or none with --output -", cfg.summary)
+	case cfg.emitSchema:
+		return fmt.Errorf("--emit-schema writes schema files; it can't be combined with --output -")
+	case cfg.manifest:
+		return fmt.Errorf("--manifest writes a manifest file; it can't be combined with --output -")
+	case cfg.append:
+		return fmt.Errorf("--append can't be combined with --output -")
+	case cfg.resume:
+		return fmt.Errorf("--resume needs a local --output directory")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteCollection_Stdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice"}}}
+	filePath, err := writeCollection(docs, map[string]struct{}{"name": {}}, "users", exportConfig{output: stdoutOutput})
+	os.Stdout = orig
+	w.Close()
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if filePath != stdoutPath {
+		t.Errorf("filePath = %q, want %q", filePath, stdoutPath)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "__path__,name\nusers/a,Alice\n"; string(got) != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+}
+
+func TestValidateStdout(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     exportConfig
+		wantErr string
+	}{
+		{"top level only", exportConfig{summary: "table"}, ""},
+		{"sub-collections", exportConfig{maxDepth: -1}, "--depth 0"},
+		{"single file", exportConfig{maxDepth: -1, singleFile: true}, ""},
+		{"collection group", exportConfig{maxDepth: -1, group: "orders"}, ""},
+		{"csv summary", exportConfig{summary: "csv"}, "--summary-format csv"},
+		{"emit schema", exportConfig{emitSchema: true}, "--emit-schema"},
+		{"manifest", exportConfig{manifest: true}, "--manifest"},
+		{"append", exportConfig{append: true}, "--append"},
+		{"resume", exportConfig{resume: true}, "--resume"},
+		{"databases", exportConfig{databases: []string{"(default)", "tenant-a"}}, "--database"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStdout(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateStdout() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateStdout() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// rowWriter writes rows of delimited text: *csv.Writer for CSV output,
+// *quotingWriter for CSV with --quote-all, and *tsvWriter for --format tsv.
+type rowWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// tsvEscaper escapes TSV cells. A tab or line break inside a value would end
+// the cell or row, so they are written as \t, \n and \r, and backslashes are
+// doubled so these escapes can't be confused with the data. Cells are never
+// quoted, as they are in CSV, so a quote character is just a character.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tsvUnescaper reverses tsvEscaper.
+var tsvUnescaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+
+// tsvWriter writes rows of tab-separated values, one line per row. Rows end
+// in \r\n with crlf.
+type tsvWriter struct {
+	w   *bufio.Writer
+	eol string
+	err error
+}
+
+func newTSVWriter(w io.Writer, crlf bool) *tsvWriter {
+	return &tsvWriter{w: bufio.NewWriter(w), eol: lineEnding(crlf)}
+}
+
+// lineEnding returns the row terminator for --line-ending.
+func lineEnding(crlf bool) string {
+	if crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+func (tw *tsvWriter) Write(record []string) error {
+	if tw.err != nil {
+		return tw.err
+	}
+	for i, cell := range record {
+		if i > 0 {
+			tw.w.WriteByte('\t')
+		}
+		tsvEscaper.WriteString(tw.w, cell)
+	}
+	if _, err := tw.w.WriteString(tw.eol); err != nil {
+		tw.err = err
+	}
+	return tw.err
+}
+
+func (tw *tsvWriter) Flush() {
+	if err := tw.w.Flush(); err != nil && tw.err == nil {
+		tw.err = err
+	}
+}
+
+func (tw *tsvWriter) Error() error {
+	return tw.err
+}
+
+// readTSVRow reads the first row from r and unescapes its cells.
+func readTSVRow(r io.Reader) ([]string, error) {
+	return nextTSVRow(bufio.NewReader(r))
+}
+
+// nextTSVRow reads the next row from br and unescapes its cells. It returns
+// io.EOF once there are no rows left.
+func nextTSVRow(br *bufio.Reader) ([]string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, err
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	cells := strings.Split(line, "\t")
+	for i, cell := range cells {
+		cells[i] = tsvUnescaper.Replace(cell)
+	}
+	return cells, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteCollection_TSV(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "col/doc1", data: map[string]any{"note": "a\tb\nc\r\nd", "quote": `say "hi"`, "path": `C:\tmp`}},
+	}
+	fieldSet := map[string]struct{}{"note": {}, "quote": {}, "path": {}}
+
+	filePath, err := writeCollection(docs, fieldSet, "col", exportConfig{output: tmpDir, format: "tsv", delimiter: ','})
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if want := filepath.Join(tmpDir, "col.tsv"); filePath != want {
+		t.Errorf("filePath = %q, want %q", filePath, want)
+	}
+
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	want := "__path__\tnote\tpath\tquote\n" +
+		`col/doc1` + "\t" + `a\tb\nc\r\nd` + "\t" + `C:\\tmp` + "\t" + `say "hi"` + "\n"
+	if string(raw) != want {
+		t.Errorf("file content = %q, want %q", string(raw), want)
+	}
+}
+
+func TestReadTSVRow(t *testing.T) {
+	got, err := readTSVRow(strings.NewReader("__path__\ta\\tb\tc\\\\d\r\nnext\trow\n"))
+	if err != nil {
+		t.Fatalf("readTSVRow() error = %v", err)
+	}
+	if want := []string{"__path__", "a\tb", `c\d`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("readTSVRow() = %q, want %q", got, want)
+	}
+
+	if _, err := readTSVRow(strings.NewReader("")); err == nil {
+		t.Error("readTSVRow() on an empty file should fail")
+	}
+}
+
+func TestWriteCollection_AppendTSV(t *testing.T) {
+	tmpDir := t.TempDir()
+	fieldSet := map[string]struct{}{"name": {}}
+	cfg := exportConfig{output: tmpDir, format: "tsv", append: true}
+
+	first := []docRecord{{path: "col/a", data: map[string]any{"name": "Alice"}}}
+	if _, err := writeCollection(first, fieldSet, "col", cfg); err != nil {
+		t.Fatalf("first writeCollection() error = %v", err)
+	}
+	second := []docRecord{{path: "col/b", data: map[string]any{"name": "Bob\tB."}}}
+	filePath, err := writeCollection(second, fieldSet, "col", cfg)
+	if err != nil {
+		t.Fatalf("second writeCollection() error = %v", err)
+	}
+
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	if want := "__path__\tname\ncol/a\tAlice\ncol/b\tBob\\tB.\n"; string(raw) != want {
+		t.Errorf("file content = %q, want %q", string(raw), want)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// typeReport gathers the inferred schema of every exported collection for
+// --validate, which reports the fields holding values of more than one type.
+// Collections are read in parallel with --concurrency, so access is locked.
+type typeReport struct {
+	mu      sync.Mutex
+	schemas []collectionSchema
+}
+
+func newTypeReport() *typeReport {
+	return &typeReport{}
+}
+
+// add records the schema of one collection.
+func (r *typeReport) add(schema collectionSchema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas = append(r.schemas, schema)
+}
+
+// typeConflict is a field with mixed types in one collection.
+type typeConflict struct {
+	collection string
+	field      string
+	types      []string
+	samples    map[string]string // type → path of a document holding it
+}
+
+// conflicts returns the fields with mixed types, sorted by collection and
+// field. Nulls don't count as a type.
+func (r *typeReport) conflicts() []typeConflict {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []typeConflict
+	for _, schema := range r.schemas {
+		for name, fs := range schema.Fields {
+			if fs.Conflict {
+				out = append(out, typeConflict{collection: schema.Collection, field: name, types: fs.Types, samples: fs.samples})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].collection != out[j].collection {
+			return out[i].collection < out[j].collection
+		}
+		return out[i].field < out[j].field
+	})
+	return out
+}
+
+// print reports the fields with mixed types: a warning with a table on
+// stderr listing one sample document per type, or with --log-format json one
+// warning per field.
+func (r *typeReport) print() {
+	conflicts := r.conflicts()
+	if len(conflicts) == 0 {
+		printOK("Validation: no fields with mixed types in %d collection(s)", len(r.schemas))
+		return
+	}
+	printWarn("Validation: %d field(s) with mixed types", len(conflicts))
+	if logJSON {
+		for _, c := range conflicts {
+			samples := make([]string, len(c.types))
+			for i, t := range c.types {
+				samples[i] = fmt.Sprintf("%s (%s)", t, c.samples[t])
+			}
+			printWarn("Field %q of %q has mixed types: %s", c.field, c.collection, strings.Join(samples, ", "))
+		}
+		return
+	}
+
+	var rows [][]string
+	for _, c := range conflicts {
+		for i, t := range c.types {
+			if i == 0 {
+				rows = append(rows, []string{c.collection, c.field, t, c.samples[t]})
+			} else {
+				rows = append(rows, []string{"", "", t, c.samples[t]})
+			}
+		}
+	}
+	headers := []string{"Collection", "Field", "Type", "Sample Document"}
+	widths := make([]int, len(headers))
+	for i, h := range headers {
+		widths[i] = len(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, " %-*s  %-*s  %-*s  %-*s\n",
+		widths[0], bold(headers[0]), widths[1], bold(headers[1]), widths[2], bold(headers[2]), widths[3], bold(headers[3]))
+	fmt.Fprintf(os.Stderr, " %s  %s  %s  %s\n",
+		faint(strings.Repeat("─", widths[0])), faint(strings.Repeat("─", widths[1])), faint(strings.Repeat("─", widths[2])), faint(strings.Repeat("─", widths[3])))
+	for _, row := range rows {
+		fmt.Fprintf(os.Stderr, " %-*s  %-*s  %-*s  %-*s\n",
+			widths[0], row[0], widths[1], row[1], widths[2], row[2], widths[3], row[3])
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypeReport_Conflicts(t *testing.T) {
+	r := newTypeReport()
+	r.add(inferSchema([]docRecord{
+		{path: "users/a", data: map[string]any{"age": int64(30), "name": "Alice", "zip": "10115"}},
+		{path: "users/b", data: map[string]any{"age": "thirty", "name": nil, "zip": int64(10115)}},
+		{path: "users/c", data: map[string]any{"age": int64(31), "name": "Carol"}},
+	}, "users"))
+	r.add(inferSchema([]docRecord{
+		{path: "orders/1", data: map[string]any{"total": 9.5}},
+		{path: "orders/2", data: map[string]any{"total": int64(10)}},
+	}, "orders"))
+	r.add(inferSchema([]docRecord{{path: "tags/x", data: map[string]any{"label": "x"}}}, "tags"))
+
+	got := r.conflicts()
+	want := []typeConflict{
+		{collection: "orders", field: "total", types: []string{"float", "int"}, samples: map[string]string{"float": "orders/1", "int": "orders/2"}},
+		{collection: "users", field: "age", types: []string{"int", "string"}, samples: map[string]string{"int": "users/a", "string": "users/b"}},
+		{collection: "users", field: "zip", types: []string{"int", "string"}, samples: map[string]string{"string": "users/a", "int": "users/b"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("conflicts() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata, set when building releases:
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.date=2024-05-01T12:00:00Z"
+//
+// `make build` fills them from git. Left empty, they come from the module and
+// VCS information Go embeds in the binary, when there is any.
+var version, commit, date string
+
+// buildInfo describes the running binary for `version` and --version.
+type buildInfo struct {
+	version string // semantic version, or "dev"
+	commit  string // short git commit, with "-dirty" for uncommitted changes
+	date    string // build date (or commit date, from VCS info)
+}
+
+// currentBuild returns the build metadata of the running binary.
+func currentBuild() buildInfo {
+	info, _ := debug.ReadBuildInfo()
+	return resolveBuild(version, commit, date, info)
+}
+
+// resolveBuild combines the -ldflags values with info, which may be nil. Each
+// value set with -ldflags wins over the embedded one.
+func resolveBuild(ldVersion, ldCommit, ldDate string, info *debug.BuildInfo) buildInfo {
+	b := buildInfo{version: ldVersion, commit: ldCommit, date: ldDate}
+	if info != nil {
+		// go install module@version sets Main.Version (e.g. "v1.2.3")
+		if v := info.Main.Version; b.version == "" && v != "" && v != "(devel)" {
+			b.version = v
+		}
+		var revision, dirty, vcsTime string
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				if s.Value == "true" {
+					dirty = "-dirty"
+				}
+			case "vcs.time":
+				vcsTime = s.Value
+			}
+		}
+		if b.commit == "" && revision != "" {
+			if len(revision) > 7 {
+				revision = revision[:7]
+			}
+			b.commit = revision + dirty
+		}
+		if b.date == "" {
+			b.date = vcsTime
+		}
+	}
+	if b.version == "" {
+		b.version = "dev"
+	}
+	return b
+}
+
+// versionString renders b as "v1.2.3 (commit abc1234, built 2024-05-01T12:00:00Z)",
+// leaving out what isn't known.
+func versionString(b buildInfo) string {
+	var details []string
+	if b.commit != "" {
+		details = append(details, "commit "+b.commit)
+	}
+	if b.date != "" {
+		details = append(details, "built "+b.date)
+	}
+	if len(details) == 0 {
+		return b.version
+	}
+	return fmt.Sprintf("%s (%s)", b.version, strings.Join(details, ", "))
+}
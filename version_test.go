@@ -0,0 +1,38 @@
+package main
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestResolveBuild(t *testing.T) {
+	vcs := &debug.BuildInfo{
+		Main: debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123456789abcdef"},
+			{Key: "vcs.time", Value: "2024-05-01T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	tests := []struct {
+		name                string
+		ldVersion, ldCommit string
+		ldDate              string
+		info                *debug.BuildInfo
+		want                string
+	}{
+		{"no info", "", "", "", nil, "dev"},
+		{"ldflags", "v1.2.3", "abc1234", "2024-06-01", nil, "v1.2.3 (commit abc1234, built 2024-06-01)"},
+		{"vcs", "", "", "", vcs, "dev (commit 0123456-dirty, built 2024-05-01T12:00:00Z)"},
+		{"ldflags over vcs", "v1.2.3", "abc1234", "", vcs, "v1.2.3 (commit abc1234, built 2024-05-01T12:00:00Z)"},
+		{"go install", "", "", "", &debug.BuildInfo{Main: debug.Module{Version: "v1.4.0"}}, "v1.4.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := versionString(resolveBuild(tt.ldVersion, tt.ldCommit, tt.ldDate, tt.info))
+			if got != tt.want {
+				t.Errorf("versionString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
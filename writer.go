@@ -0,0 +1,623 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// recordWriter writes exported documents to an output file one at a time, so
+// the same writer serves both buffered and streaming exports.
+type recordWriter interface {
+	// write appends a single document to the output.
+	write(doc docRecord) error
+	// flush writes buffered output through to the underlying file.
+	flush() error
+	// close flushes buffered output and closes the underlying file.
+	close() error
+}
+
+// newRecordWriter creates the output file for displayPath in the configured
+// format and returns a writer for it along with the file path. fieldSet is the
+// union of fields across the collection; formats with a fixed header use it
+// to lay out columns unless --fields is set. schema holds the inferred field
+// types, which Parquet uses for its column types; other formats ignore it.
+func newRecordWriter(fieldSet map[string]struct{}, schema *collectionSchema, displayPath string, cfg exportConfig) (recordWriter, string, error) {
+	if cfg.append {
+		if rw, filePath, err := openAppendWriter(fieldSet, displayPath, cfg); rw != nil || err != nil {
+			return rw, filePath, err
+		}
+	}
+	if cfg.splitDocuments {
+		return &documentWriter{cfg: cfg}, documentDir(displayPath, cfg), nil
+	}
+	if cfg.maxFileSize > 0 {
+		sw, err := newSplitWriter(fieldSet, displayPath, cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		return sw, sw.files[0], nil
+	}
+	switch cfg.format {
+	case "jsonl":
+		f, filePath, err := createOutputFile(displayPath, ".jsonl", cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		return withBaseline(newJSONLWriter(f, cfg), filePath, displayPath, cfg)
+	case "parquet":
+		f, filePath, err := openOutputFile(displayPath, ".parquet", cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		return newParquetWriter(f, fieldSet, schema, cfg), filePath, nil
+	default:
+		f, filePath, err := createOutputFile(displayPath, outputExt(cfg.format), cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		w, err := newCSVWriter(f, fieldSet, cfg)
+		if err != nil {
+			f.Close()
+			return nil, "", err
+		}
+		return withBaseline(w, filePath, displayPath, cfg)
+	}
+}
+
+// writeCollection writes document records in the configured output format and
+// returns the path of the written file, or of the first part with
+// --max-file-size.
+func writeCollection(docs []docRecord, fieldSet map[string]struct{}, displayPath string, cfg exportConfig) (string, error) {
+	filePath, _, err := writeCollectionFiles(docs, fieldSet, displayPath, cfg)
+	return filePath, err
+}
+
+// writeCollectionFiles is writeCollection that also returns the parts written
+// with --max-file-size; see writtenFiles.
+func writeCollectionFiles(docs []docRecord, fieldSet map[string]struct{}, displayPath string, cfg exportConfig) (string, []string, error) {
+	var schema *collectionSchema
+	if cfg.format == "parquet" {
+		s := inferSchema(docs, displayPath)
+		schema = &s
+	}
+	cfg, abort := abortableUploads(cfg)
+	defer abort()
+	rw, filePath, err := newRecordWriter(fieldSet, schema, displayPath, cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, doc := range docs {
+		if err := rw.write(doc); err != nil {
+			abort()
+			rw.close()
+			return "", nil, err
+		}
+	}
+	if err := rw.close(); err != nil {
+		return "", nil, fmt.Errorf("closing %s: %w", filePath, err)
+	}
+	return filePath, writtenFiles(rw), nil
+}
+
+// abortableUploads returns cfg with its Cloud Storage uploads tied to a
+// context of their own, and the function that cancels it. Calling abort before
+// closing a writer that failed discards the objects it was writing: closing
+// alone would commit them, truncated. Callers defer abort as well, to release
+// the context once the writer is closed. For local output abort does nothing.
+func abortableUploads(cfg exportConfig) (exportConfig, context.CancelFunc) {
+	if cfg.gcs == nil {
+		return cfg, func() {}
+	}
+	var abort context.CancelFunc
+	cfg.gcs, abort = cfg.gcs.withCancel()
+	return cfg, abort
+}
+
+// writeEmptyCollection writes the output file of a collection without
+// documents for --empty-files, and returns its path and the number of data
+// columns. A CSV file gets just its header: __path__ and the other columns
+// the writer adds, then the --fields or --schema-file columns if given.
+func writeEmptyCollection(displayPath string, cfg exportConfig) (string, int, error) {
+	fieldSet := make(map[string]struct{})
+	filePath, _, err := writeCollectionFiles(nil, fieldSet, displayPath, cfg)
+	return filePath, len(headerFields(fieldSet, cfg)), err
+}
+
+// validateEmptyFiles rejects options that --empty-files can't honor.
+func validateEmptyFiles(cfg exportConfig) error {
+	switch {
+	case cfg.splitDocuments:
+		return fmt.Errorf("--split-documents writes a file per document, so --empty-files has nothing to write for an empty collection")
+	case cfg.singleFile:
+		return fmt.Errorf("--single-file writes every collection to one file; it can't be combined with --empty-files")
+	}
+	return nil
+}
+
+// createOutputFile creates the output file for a collection, mirroring the
+// collection hierarchy under the output directory (or GCS prefix), and returns
+// it with its path. With --compression the file gets the codec's suffix
+// (.gz, .zst) and is compressed.
+func createOutputFile(displayPath, ext string, cfg exportConfig) (io.WriteCloser, string, error) {
+	return createNamedFile(outputFileName(displayPath, cfg), ext, cfg)
+}
+
+// createNamedFile is createOutputFile for a file named name below the output
+// directory (see outputName).
+func createNamedFile(name, ext string, cfg exportConfig) (io.WriteCloser, string, error) {
+	c, compress := codecs[cfg.compression]
+	if compress {
+		ext += c.ext
+	}
+	f, filePath, err := openNamedFile(name, ext, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	if !compress {
+		return f, filePath, nil
+	}
+	cf, err := newCompressedFile(f, c)
+	if err != nil {
+		f.Close()
+		return nil, "", fmt.Errorf("compressing %s: %w", filePath, err)
+	}
+	return cf, filePath, nil
+}
+
+// openOutputFile opens the raw destination for a collection: stdout with
+// --output -, a GCS object when writing to Cloud Storage, otherwise a local
+// file.
+func openOutputFile(displayPath, ext string, cfg exportConfig) (io.WriteCloser, string, error) {
+	return openNamedFile(outputFileName(displayPath, cfg), ext, cfg)
+}
+
+// outputFileName returns the outputName of displayPath, or "" for stdout,
+// where output has no name.
+func outputFileName(displayPath string, cfg exportConfig) string {
+	if cfg.output == stdoutOutput {
+		return ""
+	}
+	return outputName(displayPath, cfg)
+}
+
+// openNamedFile is openOutputFile for a file named name below the output
+// directory.
+func openNamedFile(name, ext string, cfg exportConfig) (io.WriteCloser, string, error) {
+	if cfg.output == stdoutOutput {
+		return stdoutFile{os.Stdout}, stdoutPath, nil
+	}
+	if cfg.gcs != nil {
+		w, url := cfg.gcs.create(name + ext)
+		return hashFile(w, name, ext, url, cfg), url, nil
+	}
+
+	filePath := filepath.Join(cfg.output, filepath.FromSlash(name)+ext)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, "", fmt.Errorf("creating directory for %s: %w", filePath, err)
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating file %s: %w", filePath, err)
+	}
+	return hashFile(f, name, ext, filePath, cfg), filePath, nil
+}
+
+// hashFile wraps f in a hashedFile when the export collects checksums.
+func hashFile(f io.WriteCloser, name, ext, filePath string, cfg exportConfig) io.WriteCloser {
+	if cfg.checksums == nil {
+		return f
+	}
+	return newHashedFile(f, name, ext, filePath, cfg)
+}
+
+// openAppendWriter opens the existing output file of a collection for
+// --append and returns a writer that adds rows after its content. A CSV
+// file's header must list the same columns the export would write, so rows
+// line up with it. If there is no file yet (or it is empty), it returns a nil
+// writer and the caller creates one.
+func openAppendWriter(fieldSet map[string]struct{}, displayPath string, cfg exportConfig) (recordWriter, string, error) {
+	filePath := filepath.Join(cfg.output, filepath.FromSlash(outputName(displayPath, cfg))+outputExt(cfg.format))
+	info, err := os.Stat(filePath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.Size() == 0) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("checking %s: %w", filePath, err)
+	}
+
+	fields := headerFields(fieldSet, cfg)
+	if cfg.format != "jsonl" {
+		r, err := os.Open(filePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("opening %s: %w", filePath, err)
+		}
+		existing, err := readCSVHeaderFields(r, cfg)
+		r.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("reading header of %s: %w", filePath, err)
+		}
+		if !slices.Equal(existing, fields) {
+			return nil, "", fmt.Errorf("can't append to %s: its columns %v don't match the exported fields %v", filePath, existing, fields)
+		}
+	}
+
+	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening %s: %w", filePath, err)
+	}
+	printInfoFor(displayPath, "Appending to existing %s", filePath)
+	if cfg.format == "jsonl" {
+		return newJSONLWriter(f, cfg), filePath, nil
+	}
+	return newCSVRowWriter(f, fields, cfg), filePath, nil
+}
+
+// validateAppend rejects options that --append can't honor.
+func validateAppend(cfg exportConfig) error {
+	switch {
+	case cfg.format == "parquet":
+		return fmt.Errorf("--append can't add rows to Parquet files; use csv or jsonl")
+	case cfg.compression != "":
+		return fmt.Errorf("--append can't add rows to compressed files; drop --compression")
+	case isGCSURL(cfg.output):
+		return fmt.Errorf("--append needs a local --output directory")
+	case cfg.noHeader:
+		return fmt.Errorf("--append checks the CSV header before adding rows; drop --no-header")
+	case cfg.emitSchema:
+		return fmt.Errorf("--emit-schema can't be combined with --append")
+	case cfg.resume:
+		return fmt.Errorf("--append can't be combined with --resume, which continues files on its own")
+	}
+	return nil
+}
+
+// outputExt returns the extension of output files in format, before any .gz
+// suffix. Delimited output is CSV unless --format tsv is set.
+func outputExt(format string) string {
+	switch format {
+	case "jsonl", "tsv", "parquet":
+		return "." + format
+	}
+	return ".csv"
+}
+
+// outputName returns the slash-separated path of a collection's output files
+// below the output directory, without extension. With --sanitize-names each
+// collection name is made safe for the file system; --file-prefix and
+// --file-suffix then wrap the last segment, so directories keep their names.
+// When several databases are exported, the database comes first.
+func outputName(displayPath string, cfg exportConfig) string {
+	segments := strings.Split(displayPath, "/")
+	if cfg.sanitizeNames {
+		for i, seg := range segments {
+			segments[i] = sanitizeFileName(seg)
+		}
+		if name := strings.Join(segments, "/"); name != displayPath {
+			if _, warned := renamedOutputs.LoadOrStore(displayPath, true); !warned {
+				printWarn("Collection %q is written as %q, since its name isn't safe in a file name", displayPath, name)
+			}
+		}
+	}
+	last := len(segments) - 1
+	segments[last] = cfg.dbPrefix + cfg.filePrefix + segments[last] + cfg.fileSuffix
+	return strings.Join(segments, "/")
+}
+
+// renamedOutputs records the collections whose sanitized file name has been
+// reported, since outputName runs for every file written for a collection.
+var renamedOutputs sync.Map
+
+// sanitizeFileName replaces path separators and control characters in a
+// collection name with '_'.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// writeCollectionCSV writes document records to a CSV file.
+func writeCollectionCSV(docs []docRecord, fieldSet map[string]struct{}, displayPath string, cfg exportConfig) (string, error) {
+	cfg.format = "csv"
+	return writeCollection(docs, fieldSet, displayPath, cfg)
+}
+
+// writeCollectionJSONL writes document records as newline-delimited JSON, one
+// object per document. The document path is stored under the __path__ key.
+func writeCollectionJSONL(docs []docRecord, displayPath string, cfg exportConfig) (string, error) {
+	cfg.format = "jsonl"
+	return writeCollection(docs, nil, displayPath, cfg)
+}
+
+// csvWriter writes documents as CSV rows: __path__, optionally __collection__,
+// the parent columns, __create_time__ and __update_time__, the header fields,
+// and optionally __fs_types__.
+type csvWriter struct {
+	f          io.WriteCloser
+	w          rowWriter
+	fields     []string
+	withTypes  bool
+	collection bool
+	parents    int // parent levels with --path-columns
+	timestamps bool
+	vf         valueFormatter
+}
+
+// headerFields returns the data columns in output order: the --fields list when
+// given (without --exclude-fields and with --rename applied), otherwise the
+// sorted union of fields across the collection, followed by any --compute
+// columns.
+func headerFields(fieldSet map[string]struct{}, cfg exportConfig) []string {
+	if len(cfg.columnOrder) > 0 {
+		return schemaHeader(fieldSet, cfg)
+	}
+	if len(cfg.fields) > 0 {
+		if len(cfg.rename) == 0 && len(cfg.excludeFields) == 0 {
+			return computedHeader(cfg.fields, cfg)
+		}
+		fields := make([]string, 0, len(cfg.fields))
+		for _, f := range cfg.fields {
+			if cfg.excludeFields[f] {
+				continue
+			}
+			if to, ok := cfg.rename[f]; ok {
+				f = to
+			}
+			fields = append(fields, f)
+		}
+		return computedHeader(fields, cfg)
+	}
+	fields := make([]string, 0, len(fieldSet))
+	for k := range fieldSet {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return computedHeader(fields, cfg)
+}
+
+// utf8BOM is the byte order mark written at the start of CSV files with --bom.
+const utf8BOM = "\ufeff"
+
+// newCSVWriter writes the header row to f, unless --no-header is set, and
+// returns a writer for data rows. With --bom the file starts with utf8BOM.
+// With --baseline the data columns end with changedColumn.
+func newCSVWriter(f io.WriteCloser, fieldSet map[string]struct{}, cfg exportConfig) (*csvWriter, error) {
+	if cfg.bom {
+		if _, err := io.WriteString(f, utf8BOM); err != nil {
+			return nil, fmt.Errorf("writing byte order mark: %w", err)
+		}
+	}
+	fields := headerFields(fieldSet, cfg)
+	if cfg.baseline != "" {
+		fields = append(fields, changedColumn)
+	}
+	cw := newCSVRowWriter(f, fields, cfg)
+	if cfg.noHeader {
+		return cw, nil
+	}
+	headers := []string{"__path__"}
+	if cfg.singleFile {
+		headers = append(headers, "__collection__")
+	}
+	headers = append(headers, parentColumns(cfg.parentLevels)...)
+	if cfg.includeTimestamps {
+		headers = append(headers, "__create_time__", "__update_time__")
+	}
+	headers = append(headers, cw.fields...)
+	if cfg.withTypes {
+		headers = append(headers, "__fs_types__")
+	}
+	if err := cw.w.Write(headers); err != nil {
+		return nil, fmt.Errorf("writing header: %w", err)
+	}
+	return cw, nil
+}
+
+// newCSVRowWriter returns a writer for data rows with the given columns,
+// without writing a header. It is used to append to an existing file.
+func newCSVRowWriter(f io.WriteCloser, fields []string, cfg exportConfig) *csvWriter {
+	var w rowWriter
+	if cfg.format == "tsv" {
+		w = newTSVWriter(f, cfg.crlf)
+	} else if cfg.quoteAll {
+		w = newQuotingWriter(f, cfg.delimiter, cfg.crlf)
+	} else {
+		cw := csv.NewWriter(f)
+		if cfg.delimiter != 0 {
+			cw.Comma = cfg.delimiter
+		}
+		cw.UseCRLF = cfg.crlf
+		w = cw
+	}
+	return &csvWriter{
+		f:          f,
+		w:          w,
+		fields:     fields,
+		withTypes:  cfg.withTypes,
+		collection: cfg.singleFile,
+		parents:    cfg.parentLevels,
+		timestamps: cfg.includeTimestamps,
+		vf:         newValueFormatter(cfg),
+	}
+}
+
+func (cw *csvWriter) write(doc docRecord) error {
+	row := make([]string, 0, 5+2*cw.parents+len(cw.fields))
+	row = append(row, doc.path)
+	if cw.collection {
+		row = append(row, collectionPath(doc.path))
+	}
+	row = append(row, parentValues(doc.path, cw.parents)...)
+	if cw.timestamps {
+		row = append(row, cw.vf.formatValue(doc.createTime), cw.vf.formatValue(doc.updateTime))
+	}
+	typeMap := make(map[string]string, len(cw.fields))
+	for _, h := range cw.fields {
+		val, ok := doc.data[h]
+		if !ok || val == nil {
+			row = append(row, cw.vf.nullValue)
+			continue
+		}
+		cell, cut := cw.vf.truncate(cw.vf.formatValue(val))
+		if cut {
+			printWarn("Truncated field %q of document %s to %d bytes (--max-cell-size)", h, doc.path, cw.vf.maxCellSize)
+		}
+		row = append(row, cell)
+		if cw.withTypes {
+			typeMap[h] = typeLabel(val)
+		}
+	}
+	if cw.withTypes {
+		b, _ := marshalSorted(typeMap)
+		row = append(row, string(b))
+	}
+	if err := cw.w.Write(row); err != nil {
+		return fmt.Errorf("writing row: %w", err)
+	}
+	return nil
+}
+
+func (cw *csvWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) close() error {
+	if err := cw.flush(); err != nil {
+		cw.f.Close()
+		return err
+	}
+	return cw.f.Close()
+}
+
+// quotingWriter writes CSV rows with every field in double quotes, for
+// --quote-all; encoding/csv only quotes fields that need it. Quotes inside a
+// field are doubled, and delimiters and line breaks are kept as they are,
+// which quoting makes valid. With crlf, rows end in \r\n and line breaks
+// inside fields become \r\n too, as csv.Writer.UseCRLF does.
+type quotingWriter struct {
+	w     *bufio.Writer
+	comma rune
+	crlf  bool
+	err   error
+}
+
+func newQuotingWriter(w io.Writer, comma rune, crlf bool) *quotingWriter {
+	if comma == 0 {
+		comma = ','
+	}
+	return &quotingWriter{w: bufio.NewWriter(w), comma: comma, crlf: crlf}
+}
+
+func (qw *quotingWriter) Write(record []string) error {
+	if qw.err != nil {
+		return qw.err
+	}
+	for i, field := range record {
+		if i > 0 {
+			qw.w.WriteRune(qw.comma)
+		}
+		field = strings.ReplaceAll(field, `"`, `""`)
+		if qw.crlf {
+			field = strings.ReplaceAll(strings.ReplaceAll(field, "\r", ""), "\n", "\r\n")
+		}
+		qw.w.WriteByte('"')
+		qw.w.WriteString(field)
+		qw.w.WriteByte('"')
+	}
+	if _, err := qw.w.WriteString(lineEnding(qw.crlf)); err != nil {
+		qw.err = err
+	}
+	return qw.err
+}
+
+func (qw *quotingWriter) Flush() {
+	if err := qw.w.Flush(); err != nil && qw.err == nil {
+		qw.err = err
+	}
+}
+
+func (qw *quotingWriter) Error() error {
+	return qw.err
+}
+
+// jsonlWriter writes documents as newline-delimited JSON objects.
+type jsonlWriter struct {
+	f          io.WriteCloser
+	bw         *bufio.Writer
+	pretty     bool // --pretty-json
+	parents    int
+	timestamps bool
+	vf         valueFormatter
+}
+
+// newJSONLWriter returns a writer for JSON Lines output. With --pretty-json the
+// objects are indented, so each one spans several lines.
+func newJSONLWriter(f io.WriteCloser, cfg exportConfig) *jsonlWriter {
+	return &jsonlWriter{
+		f:          f,
+		bw:         bufio.NewWriter(f),
+		pretty:     cfg.prettyJSON,
+		parents:    cfg.parentLevels,
+		timestamps: cfg.includeTimestamps,
+		vf:         newValueFormatter(cfg),
+	}
+}
+
+func (jw *jsonlWriter) write(doc docRecord) error {
+	obj, _ := jw.vf.convertForJSON(doc.data).(map[string]any)
+	obj["__path__"] = doc.path
+	if jw.parents > 0 {
+		vals := parentValues(doc.path, jw.parents)
+		for i, col := range parentColumns(jw.parents) {
+			obj[col] = vals[i]
+		}
+	}
+	if jw.timestamps {
+		obj["__create_time__"] = jw.vf.formatTime(doc.createTime)
+		obj["__update_time__"] = jw.vf.formatTime(doc.updateTime)
+	}
+	b, err := marshalSorted(obj)
+	if err != nil {
+		return fmt.Errorf("writing document %s: %w", doc.path, err)
+	}
+	if jw.pretty {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, b, "", "  "); err != nil {
+			return fmt.Errorf("writing document %s: %w", doc.path, err)
+		}
+		b = indented.Bytes()
+	}
+	if _, err := jw.bw.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("writing document %s: %w", doc.path, err)
+	}
+	return nil
+}
+
+func (jw *jsonlWriter) flush() error {
+	return jw.bw.Flush()
+}
+
+func (jw *jsonlWriter) close() error {
+	if err := jw.flush(); err != nil {
+		jw.f.Close()
+		return err
+	}
+	return jw.f.Close()
+}
@@ -0,0 +1,625 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNewRecordWriter_CSVStreaming(t *testing.T) {
+	tmpDir := t.TempDir()
+	fieldSet := map[string]struct{}{"name": {}, "age": {}}
+
+	rw, filePath, err := newRecordWriter(fieldSet, nil, "users", exportConfig{output: tmpDir})
+	if err != nil {
+		t.Fatalf("newRecordWriter() error = %v", err)
+	}
+	if filePath != filepath.Join(tmpDir, "users.csv") {
+		t.Errorf("filePath = %q, want users.csv under output dir", filePath)
+	}
+
+	// Rows are written one at a time, as the streaming export does.
+	for _, doc := range []docRecord{
+		{path: "users/a", data: map[string]any{"name": "Alice", "age": int64(30)}},
+		{path: "users/b", data: map[string]any{"name": "Bob", "extra": "dropped"}},
+	} {
+		if err := rw.write(doc); err != nil {
+			t.Fatalf("write(%s) error = %v", doc.path, err)
+		}
+	}
+	if err := rw.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	records := readCSV(t, filePath)
+	want := [][]string{
+		{"__path__", "age", "name"},
+		{"users/a", "30", "Alice"},
+		{"users/b", "", "Bob"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(records), len(want))
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("row %d col %d = %q, want %q", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestNewRecordWriter_JSONL(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	rw, filePath, err := newRecordWriter(nil, nil, "users/orders", exportConfig{output: tmpDir, format: "jsonl"})
+	if err != nil {
+		t.Fatalf("newRecordWriter() error = %v", err)
+	}
+	if err := rw.write(docRecord{path: "users/a/orders/1", data: map[string]any{"total": float64(9.5)}}); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if err := rw.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	if filePath != filepath.Join(tmpDir, "users", "orders.jsonl") {
+		t.Errorf("filePath = %q, want users/orders.jsonl under output dir", filePath)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	want := `{"__path__":"users/a/orders/1","total":9.5}` + "\n"
+	if string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestWriteCollectionJSONL_PrettyJSON(t *testing.T) {
+	docs := []docRecord{{path: "users/a", data: map[string]any{"tags": []any{"x"}}}}
+
+	filePath, err := writeCollectionJSONL(docs, "users", exportConfig{output: t.TempDir(), prettyJSON: true})
+	if err != nil {
+		t.Fatalf("writeCollectionJSONL() error = %v", err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"__path__\": \"users/a\",\n  \"tags\": [\n    \"x\"\n  ]\n}\n"
+	if string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestWriteCollection_Gzip(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice"}}}
+
+	tests := []struct {
+		format  string
+		wantExt string
+		want    string
+	}{
+		{"csv", ".csv.gz", "__path__,name\nusers/a,Alice\n"},
+		{"jsonl", ".jsonl.gz", `{"__path__":"users/a","name":"Alice"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			fieldSet := map[string]struct{}{"name": {}}
+			filePath, err := writeCollection(docs, fieldSet, "users", exportConfig{output: tmpDir, format: tt.format, compression: "gzip"})
+			if err != nil {
+				t.Fatalf("writeCollection() error = %v", err)
+			}
+			if filePath != filepath.Join(tmpDir, "users"+tt.wantExt) {
+				t.Errorf("filePath = %q, want users%s under output dir", filePath, tt.wantExt)
+			}
+
+			f, err := os.Open(filePath)
+			if err != nil {
+				t.Fatalf("opening %s: %v", filePath, err)
+			}
+			defer f.Close()
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("gzip.NewReader() error = %v", err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("reading gzip stream: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCollection_IncludeTimestamps(t *testing.T) {
+	tmpDir := t.TempDir()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := time.Date(2024, 6, 7, 8, 9, 10, 0, time.UTC)
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice"}, createTime: created, updateTime: updated}}
+
+	tests := []struct {
+		format string
+		cfg    exportConfig
+		want   string
+	}{
+		{"csv", exportConfig{}, "__path__,__create_time__,__update_time__,name\nusers/a,2024-01-02T03:04:05Z,2024-06-07T08:09:10Z,Alice\n"},
+		{"csv", exportConfig{timeFormat: "2006-01-02"}, "__path__,__create_time__,__update_time__,name\nusers/a,2024-01-02,2024-06-07,Alice\n"},
+		{"jsonl", exportConfig{timeFormat: timeFormatUnix}, `{"__create_time__":1704164645,"__path__":"users/a","__update_time__":1717747750,"name":"Alice"}` + "\n"},
+	}
+	for _, tt := range tests {
+		cfg := tt.cfg
+		cfg.output, cfg.format, cfg.includeTimestamps = tmpDir, tt.format, true
+		filePath, err := writeCollection(docs, map[string]struct{}{"name": {}}, "users", cfg)
+		if err != nil {
+			t.Fatalf("writeCollection(%s) error = %v", tt.format, err)
+		}
+		got, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s output = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCollection_PathColumns(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "users/a/orders/1/items/x", data: map[string]any{"sku": "A-1"}}}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "__path__,__parent_2_collection__,__parent_2_id__,__parent_collection__,__parent_id__,sku\nusers/a/orders/1/items/x,users,a,orders,1,A-1\n"},
+		{"jsonl", `{"__parent_2_collection__":"users","__parent_2_id__":"a","__parent_collection__":"orders","__parent_id__":"1","__path__":"users/a/orders/1/items/x","sku":"A-1"}` + "\n"},
+	}
+	for _, tt := range tests {
+		cfg := exportConfig{output: tmpDir, format: tt.format, pathColumns: true, parentLevels: 2}
+		filePath, err := writeCollection(docs, map[string]struct{}{"sku": {}}, "users/orders/items", cfg)
+		if err != nil {
+			t.Fatalf("writeCollection(%s) error = %v", tt.format, err)
+		}
+		got, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s output = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestShapeRecord_NoSideEffects(t *testing.T) {
+	san := newSanitizer(sanitizeConfig{Fields: map[string]string{"email": "email"}}, 1)
+	data := map[string]any{"email": "real@example.com"}
+
+	shapeRecord(data, exportConfig{sanitizer: san})
+	if data["email"] != "real@example.com" {
+		t.Errorf("shapeRecord should not sanitize, email = %v", data["email"])
+	}
+
+	prepareRecord(data, "users/alice", exportConfig{sanitizer: san})
+	if data["email"] == "real@example.com" {
+		t.Error("prepareRecord should sanitize configured fields")
+	}
+}
+
+func TestWriteCollectionCSV_FixedFields(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "users/a", data: map[string]any{"name": "Alice", "email": "a@example.com", "age": int64(30)}},
+		{path: "users/b", data: map[string]any{"name": "Bob"}},
+	}
+	fieldSet := map[string]struct{}{"name": {}, "email": {}, "age": {}}
+
+	filePath, err := writeCollectionCSV(docs, fieldSet, "users", exportConfig{output: tmpDir, fields: []string{"name", "missing", "email"}})
+	if err != nil {
+		t.Fatalf("writeCollectionCSV() error = %v", err)
+	}
+
+	records := readCSV(t, filePath)
+	want := [][]string{
+		{"__path__", "name", "missing", "email"},
+		{"users/a", "Alice", "", "a@example.com"},
+		{"users/b", "Bob", "", ""},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+}
+
+func TestWriteCollection_Append(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := exportConfig{output: tmpDir, append: true}
+	fieldSet := map[string]struct{}{"name": {}}
+	first := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice"}}}
+	second := []docRecord{{path: "users/b", data: map[string]any{"name": "Bob"}}}
+
+	// With no file yet, the export writes one as usual.
+	filePath, err := writeCollectionCSV(first, fieldSet, "users", cfg)
+	if err != nil {
+		t.Fatalf("first writeCollectionCSV() error = %v", err)
+	}
+	if _, err := writeCollectionCSV(second, fieldSet, "users", cfg); err != nil {
+		t.Fatalf("second writeCollectionCSV() error = %v", err)
+	}
+	want := [][]string{
+		{"__path__", "name"},
+		{"users/a", "Alice"},
+		{"users/b", "Bob"},
+	}
+	if got := readCSV(t, filePath); !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+
+	// A different field union would misalign the rows.
+	fieldSet["email"] = struct{}{}
+	_, err = writeCollectionCSV(second, fieldSet, "users", cfg)
+	if err == nil || !strings.Contains(err.Error(), "don't match") {
+		t.Errorf("writeCollectionCSV() with new field error = %v, want header mismatch", err)
+	}
+	if got := readCSV(t, filePath); !reflect.DeepEqual(got, want) {
+		t.Errorf("records after mismatch = %v, want file unchanged", got)
+	}
+
+	// JSON Lines has no header to check.
+	jsonlPath, err := writeCollectionJSONL(first, "users", cfg)
+	if err != nil {
+		t.Fatalf("first writeCollectionJSONL() error = %v", err)
+	}
+	if _, err := writeCollectionJSONL(second, "users", cfg); err != nil {
+		t.Fatalf("second writeCollectionJSONL() error = %v", err)
+	}
+	b, err := os.ReadFile(jsonlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 2 {
+		t.Errorf("got %d JSON lines, want 2", lines)
+	}
+}
+
+func TestValidateAppend(t *testing.T) {
+	base := exportConfig{output: ".", format: "csv"}
+	if err := validateAppend(base); err != nil {
+		t.Errorf("validateAppend() error = %v", err)
+	}
+	invalid := map[string]func(*exportConfig){
+		"Parquet":        func(c *exportConfig) { c.format = "parquet" },
+		"compressed":     func(c *exportConfig) { c.compression = "gzip" },
+		"local --output": func(c *exportConfig) { c.output = "gs://bucket/prefix" },
+		"--no-header":    func(c *exportConfig) { c.noHeader = true },
+		"--emit-schema":  func(c *exportConfig) { c.emitSchema = true },
+		"--resume":       func(c *exportConfig) { c.resume = true },
+	}
+	for wantErr, modify := range invalid {
+		cfg := base
+		modify(&cfg)
+		if err := validateAppend(cfg); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("validateAppend() error = %v, want containing %q", err, wantErr)
+		}
+	}
+}
+
+func TestWriteEmptyCollection(t *testing.T) {
+	cfg := exportConfig{output: t.TempDir(), fields: []string{"name", "age"}, includeTimestamps: true}
+	filePath, fields, err := writeEmptyCollection("users", cfg)
+	if err != nil {
+		t.Fatalf("writeEmptyCollection() error = %v", err)
+	}
+	if fields != 2 {
+		t.Errorf("fields = %d, want 2", fields)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "__path__,__create_time__,__update_time__,name,age\n"; string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+
+	cfg = exportConfig{output: t.TempDir(), format: "jsonl"}
+	filePath, _, err = writeEmptyCollection("users", cfg)
+	if err != nil {
+		t.Fatalf("writeEmptyCollection(jsonl) error = %v", err)
+	}
+	if info, err := os.Stat(filePath); err != nil || info.Size() != 0 {
+		t.Errorf("JSON Lines file = %v, %v; want an empty file", info, err)
+	}
+}
+
+func TestValidateEmptyFiles(t *testing.T) {
+	if err := validateEmptyFiles(exportConfig{emptyFiles: true}); err != nil {
+		t.Errorf("validateEmptyFiles() error = %v", err)
+	}
+	for name, cfg := range map[string]exportConfig{
+		"--split-documents": {emptyFiles: true, splitDocuments: true},
+		"--single-file":     {emptyFiles: true, singleFile: true},
+	} {
+		if err := validateEmptyFiles(cfg); err == nil {
+			t.Errorf("validateEmptyFiles(%s) error = nil, want error", name)
+		}
+	}
+}
+
+func TestWriteCollectionCSV_NoHeader(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice", "age": int64(30)}}}
+	fieldSet := map[string]struct{}{"name": {}, "age": {}}
+
+	filePath, err := writeCollectionCSV(docs, fieldSet, "users", exportConfig{output: tmpDir, noHeader: true})
+	if err != nil {
+		t.Fatalf("writeCollectionCSV() error = %v", err)
+	}
+
+	records := readCSV(t, filePath)
+	want := [][]string{{"users/a", "30", "Alice"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+}
+
+func TestWriteCollectionCSV_BOM(t *testing.T) {
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Zoë"}}}
+	fieldSet := map[string]struct{}{"name": {}}
+	want := "\xEF\xBB\xBF__path__,name\nusers/a,Zoë\n"
+
+	for _, gz := range []bool{false, true} {
+		cfg := exportConfig{output: t.TempDir(), bom: true}
+		if gz {
+			cfg.compression = "gzip"
+		}
+		filePath, err := writeCollectionCSV(docs, fieldSet, "users", cfg)
+		if err != nil {
+			t.Fatalf("writeCollectionCSV(gzip=%v) error = %v", gz, err)
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if gz {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("gzip.NewReader() error = %v", err)
+			}
+			r = zr
+		}
+		got, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("gzip=%v: output = %q, want %q", gz, got, want)
+		}
+	}
+}
+
+func TestWriteCollectionCSV_MaxCellSize(t *testing.T) {
+	docs := []docRecord{{path: "users/a", data: map[string]any{
+		"bio":  "a very long biography",
+		"name": "Ann",
+	}}}
+	fieldSet := map[string]struct{}{"bio": {}, "name": {}}
+
+	var filePath string
+	out := captureStderr(t, func() {
+		var err error
+		filePath, err = writeCollectionCSV(docs, fieldSet, "users", exportConfig{output: t.TempDir(), maxCellSize: 6})
+		if err != nil {
+			t.Fatalf("writeCollectionCSV() error = %v", err)
+		}
+	})
+
+	records := readCSV(t, filePath)
+	if got, want := records[1], []string{"users/a", "a very" + truncatedMarker, "Ann"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("row = %q, want %q", got, want)
+	}
+	if !strings.Contains(out, `"bio"`) || !strings.Contains(out, "users/a") {
+		t.Errorf("warning = %q, want the field and document", out)
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	cfg := exportConfig{filePrefix: "prod_", fileSuffix: "_v2"}
+	tests := []struct {
+		displayPath string
+		want        string
+	}{
+		{"users", "prod_users_v2"},
+		{"users/orders", "users/prod_orders_v2"},
+		{"users/orders/items", "users/orders/prod_items_v2"},
+	}
+	for _, tt := range tests {
+		if got := outputName(tt.displayPath, cfg); got != tt.want {
+			t.Errorf("outputName(%q) = %q, want %q", tt.displayPath, got, tt.want)
+		}
+	}
+	if got := outputName("users/orders", exportConfig{}); got != "users/orders" {
+		t.Errorf("outputName() without prefix or suffix = %q, want users/orders", got)
+	}
+	cfg.dbPrefix = databaseFilePrefix("tenant-a")
+	if got := outputName("users/orders", cfg); got != "users/tenant-a_prod_orders_v2" {
+		t.Errorf("outputName() with a database = %q, want users/tenant-a_prod_orders_v2", got)
+	}
+}
+
+func TestOutputName_SanitizeNames(t *testing.T) {
+	cfg := exportConfig{sanitizeNames: true, filePrefix: "prod_"}
+	var got string
+	out := captureStderr(t, func() {
+		got = outputName("logs/a\\b\tc", cfg)
+		outputName("logs/a\\b\tc", cfg)
+	})
+	if want := "logs/prod_a_b_c"; got != want {
+		t.Errorf("outputName() = %q, want %q", got, want)
+	}
+	if n := strings.Count(out, "WARN"); n != 1 {
+		t.Errorf("got %d warnings, want one per collection: %q", n, out)
+	}
+	if got := outputName("users/orders", cfg); got != "users/prod_orders" {
+		t.Errorf("outputName() = %q, want safe names unchanged", got)
+	}
+
+	cfg.sanitizeNames = false
+	if got := outputName(`a\b`, cfg); got != `prod_a\b` {
+		t.Errorf("outputName() without --sanitize-names = %q, want the name as is", got)
+	}
+}
+
+func TestWriteCollection_FilePrefix(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "users/a/orders/o1", data: map[string]any{"total": int64(5)}}}
+	cfg := exportConfig{output: tmpDir, format: "jsonl", compression: "gzip", filePrefix: "prod_", fileSuffix: "_v2"}
+
+	filePath, err := writeCollection(docs, nil, "users/orders", cfg)
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if want := filepath.Join(tmpDir, "users", "prod_orders_v2.jsonl.gz"); filePath != want {
+		t.Errorf("filePath = %q, want %q", filePath, want)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Errorf("output file not written: %v", err)
+	}
+}
+
+func TestHeaderFields(t *testing.T) {
+	fieldSet := map[string]struct{}{"b": {}, "a": {}, "c": {}}
+	if got := headerFields(fieldSet, exportConfig{}); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("headerFields() = %v, want sorted union", got)
+	}
+	if got := headerFields(fieldSet, exportConfig{fields: []string{"c", "x"}}); !reflect.DeepEqual(got, []string{"c", "x"}) {
+		t.Errorf("headerFields() = %v, want --fields order", got)
+	}
+	cfg := exportConfig{fields: []string{"c", "a"}, rename: map[string]string{"a": "alpha"}}
+	if got := headerFields(fieldSet, cfg); !reflect.DeepEqual(got, []string{"c", "alpha"}) {
+		t.Errorf("headerFields() = %v, want --fields with --rename applied", got)
+	}
+	cfg = exportConfig{fields: []string{"c", "a"}, excludeFields: map[string]bool{"a": true}}
+	if got := headerFields(fieldSet, cfg); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("headerFields() = %v, want --fields without --exclude-fields", got)
+	}
+}
+
+func TestWriteCollectionCSV_QuoteAll(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{
+		{path: "col/doc1", data: map[string]any{"note": "say \"hi\";\nbye", "n": int64(5)}},
+		{path: "col/doc2", data: map[string]any{"n": int64(6)}},
+	}
+	fieldSet := map[string]struct{}{"note": {}, "n": {}}
+
+	filePath, err := writeCollectionCSV(docs, fieldSet, "col", exportConfig{output: tmpDir, delimiter: ';', quoteAll: true})
+	if err != nil {
+		t.Fatalf("writeCollectionCSV() error = %v", err)
+	}
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	want := "\"__path__\";\"n\";\"note\"\n" +
+		"\"col/doc1\";\"5\";\"say \"\"hi\"\";\nbye\"\n" +
+		"\"col/doc2\";\"6\";\"\"\n"
+	if string(raw) != want {
+		t.Errorf("file content = %q, want %q", string(raw), want)
+	}
+
+	r := csv.NewReader(strings.NewReader(string(raw)))
+	r.Comma = ';'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if got := records[1][2]; got != "say \"hi\";\nbye" {
+		t.Errorf("note cell = %q, want it to round-trip", got)
+	}
+}
+
+func TestWriteCollection_CRLF(t *testing.T) {
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice", "note": "one\ntwo"}}}
+	fieldSet := map[string]struct{}{"name": {}, "note": {}}
+
+	tests := []struct {
+		name string
+		cfg  exportConfig
+		want string
+	}{
+		{"csv", exportConfig{format: "csv"}, "__path__,name,note\r\nusers/a,Alice,\"one\r\ntwo\"\r\n"},
+		{"quote-all", exportConfig{format: "csv", quoteAll: true}, "\"__path__\",\"name\",\"note\"\r\n\"users/a\",\"Alice\",\"one\r\ntwo\"\r\n"},
+		{"tsv", exportConfig{format: "tsv"}, "__path__\tname\tnote\r\nusers/a\tAlice\tone\\ntwo\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.output = t.TempDir()
+			cfg.crlf = true
+			cfg.compression = "gzip"
+			filePath, err := writeCollection(docs, fieldSet, "users", cfg)
+			if err != nil {
+				t.Fatalf("writeCollection() error = %v", err)
+			}
+
+			f, err := os.Open(filePath)
+			if err != nil {
+				t.Fatalf("opening %s: %v", filePath, err)
+			}
+			defer f.Close()
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("gzip.NewReader() error = %v", err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("reading gzip stream: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCollection_Zstd(t *testing.T) {
+	tmpDir := t.TempDir()
+	docs := []docRecord{{path: "users/a", data: map[string]any{"name": "Alice"}}}
+	filePath, err := writeCollection(docs, map[string]struct{}{"name": {}}, "users", exportConfig{output: tmpDir, format: "csv", compression: "zstd"})
+	if err != nil {
+		t.Fatalf("writeCollection() error = %v", err)
+	}
+	if filePath != filepath.Join(tmpDir, "users.csv.zst") {
+		t.Errorf("filePath = %q, want users.csv.zst under output dir", filePath)
+	}
+
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filePath, err)
+	}
+	zr, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	got, err := zr.DecodeAll(raw, nil)
+	if err != nil {
+		t.Fatalf("decoding zstd stream: %v", err)
+	}
+	if want := "__path__,name\nusers/a,Alice\n"; string(got) != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}